@@ -0,0 +1,647 @@
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"sync"
+	"sync/atomic"
+)
+
+// OverflowPolicy decides what AsyncStorage.Store does when the queue is full.
+type OverflowPolicy int
+
+const (
+	// OverflowBlock waits for free space in the queue or for the Store context
+	// to be done. This is the default and never loses events.
+	OverflowBlock OverflowPolicy = iota
+
+	// OverflowDropOldest discards the oldest queued event to make room.
+	OverflowDropOldest
+
+	// OverflowDropNewest discards the event being stored.
+	OverflowDropNewest
+
+	// OverflowSpill appends events to AsyncOptions.SpillPath until the queue
+	// drains. Spilled events are written in order once the queue is empty, and
+	// events left in the file by a previous process are written after opening.
+	// Events leave the file only once the inner storage has accepted them. A
+	// rejected event stays at the front of the file together with the events
+	// behind it, new events are spilled after them, and the writer retries
+	// them on the next Store or the next process does after opening.
+	OverflowSpill
+)
+
+// Defaults used when AsyncOptions fields are zero.
+const (
+	defaultQueueSize = 1024
+	defaultBatchSize = 64
+)
+
+// AsyncOptions configures an AsyncStorage.
+type AsyncOptions struct {
+	// QueueSize bounds the number of events waiting in memory. Defaults to 1024.
+	QueueSize int
+
+	// BatchSize is the maximum number of events written per batch. Defaults to 64.
+	BatchSize int
+
+	// Overflow selects the behavior when the queue is full. Defaults to OverflowBlock.
+	Overflow OverflowPolicy
+
+	// SpillPath is the file used by OverflowSpill. Required with that policy.
+	SpillPath string
+
+	// OnError, if set, is called from the background writer for every event
+	// the inner storage fails to store.
+	OnError func(key string, event Event, err error)
+}
+
+// AsyncStats is a snapshot of AsyncStorage counters.
+type AsyncStats struct {
+	Queued  uint64 // events accepted by Store, including spilled ones
+	Written uint64 // events stored by the inner storage
+	Failed  uint64 // events the inner storage rejected
+	Dropped uint64 // events discarded by the overflow policy or on Close
+	Spilled uint64 // events written to the spill file
+	Pending int    // events accepted but not yet handed to the inner storage
+}
+
+// pendingEvent is a queued event with its acceptance order.
+type pendingEvent struct {
+	KeyedEvent
+
+	seq uint64
+}
+
+// AsyncStorage is a StorageV2 that moves writes off the caller's path.
+// Store enqueues the event and returns; a background goroutine writes queued
+// events to the inner storage in batches, in the order they were accepted.
+//
+// Reads (Get, Has) and Clear of a key first wait until every event accepted
+// for that key before the call has been written, so they observe the caller's
+// own writes without waiting for unrelated keys. Errors from
+// the inner storage are reported by the next Flush and to AsyncOptions.OnError.
+//
+// Dropping policies leave gaps in entity streams, which Verify reports as
+// broken hash chains; use OverflowBlock or OverflowSpill where that matters.
+type AsyncStorage struct {
+	inner StorageV2
+	opts  AsyncOptions
+
+	mu       sync.Mutex
+	queue    []pendingEvent
+	seq      uint64            // last accepted event
+	done     uint64            // last event handed to the inner storage
+	pending  map[string]uint64 // last accepted event of each key not yet handed over
+	leftover uint64            // last event left in the spill file by a previous process
+	spill    *os.File
+	spilled  int    // events waiting in the spill file
+	spillSeq uint64 // last spilled event
+	drained  int64  // bytes of the spill file taken by the writer and not yet removed
+	err      error  // first write error since the last Flush
+	closed   bool
+	space    chan struct{} // closed when the writer frees queue space
+	progress chan struct{} // closed when done advances
+
+	wake    chan struct{}
+	quit    chan struct{}
+	stopped chan struct{}
+
+	queued, written, failed, dropped, spilledTotal atomic.Uint64
+}
+
+// NewAsyncStorage starts a background writer for inner and returns the wrapper.
+// Call Close to write the remaining events and stop the writer.
+//
+// Example:
+//
+//	async, err := audit.NewAsyncStorage(store, audit.AsyncOptions{Overflow: audit.OverflowDropOldest})
+//	if err != nil {
+//	    return err
+//	}
+//	defer async.Close(context.Background())
+//	logger := audit.New(audit.WithStorageV2(async))
+func NewAsyncStorage(inner StorageV2, opts AsyncOptions) (*AsyncStorage, error) {
+	if opts.QueueSize <= 0 {
+		opts.QueueSize = defaultQueueSize
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = defaultBatchSize
+	}
+
+	s := &AsyncStorage{
+		inner:    inner,
+		opts:     opts,
+		queue:    make([]pendingEvent, 0, opts.QueueSize),
+		pending:  make(map[string]uint64),
+		space:    make(chan struct{}),
+		progress: make(chan struct{}),
+		wake:     make(chan struct{}, 1),
+		quit:     make(chan struct{}),
+		stopped:  make(chan struct{}),
+	}
+
+	if opts.Overflow == OverflowSpill {
+		if err := s.openSpill(); err != nil {
+			return nil, err
+		}
+	}
+
+	go s.run()
+	s.signal()
+	return s, nil
+}
+
+// openSpill opens the spill file and accounts for events left by a previous process.
+func (s *AsyncStorage) openSpill() error {
+	if s.opts.SpillPath == "" {
+		return errors.New("audit: OverflowSpill requires a SpillPath")
+	}
+	file, err := os.OpenFile(s.opts.SpillPath, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0o600)
+	if err != nil {
+		return fmt.Errorf("audit: open spill file: %w", err)
+	}
+	data, err := io.ReadAll(file)
+	if err != nil {
+		_ = file.Close()
+		return fmt.Errorf("audit: read spill file: %w", err)
+	}
+
+	if len(data) > 0 && data[len(data)-1] != '\n' {
+		// Terminate a torn record so that new events start on their own line.
+		if _, err := file.Write([]byte{'\n'}); err != nil {
+			_ = file.Close()
+			return fmt.Errorf("audit: repair spill file: %w", err)
+		}
+		data = append(data, '\n')
+	}
+
+	s.spill = file
+	s.spilled = bytes.Count(data, []byte("\n"))
+	s.seq = uint64(s.spilled) //nolint:gosec // a count is never negative.
+	s.spillSeq = s.seq
+	s.leftover = s.seq
+	return nil
+}
+
+// Store enqueues event for key. It returns once the event is queued or
+// spilled, or dropped by the overflow policy; it does not wait for the write.
+func (s *AsyncStorage) Store(ctx context.Context, key string, event Event) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for {
+		switch {
+		case s.closed:
+			return ErrStorageClosed
+		case s.spilled > 0:
+			// Keep spilling until the file is drained, so events stay in order.
+			return s.acceptSpillLocked(key, event)
+		case len(s.queue) < s.opts.QueueSize:
+			s.enqueueLocked(key, event)
+			return nil
+		}
+
+		switch s.opts.Overflow {
+		case OverflowDropNewest:
+			s.dropped.Add(1)
+			return nil
+		case OverflowDropOldest:
+			if oldest := s.queue[0]; s.pending[oldest.Key] == oldest.seq {
+				delete(s.pending, oldest.Key)
+			}
+			s.queue = slices.Delete(s.queue, 0, 1)
+			s.dropped.Add(1)
+			s.enqueueLocked(key, event)
+			return nil
+		case OverflowSpill:
+			return s.acceptSpillLocked(key, event)
+		default:
+			space := s.space
+			s.mu.Unlock()
+			select {
+			case <-space:
+			case <-ctx.Done():
+				s.mu.Lock()
+				return ctx.Err()
+			}
+			s.mu.Lock()
+		}
+	}
+}
+
+func (s *AsyncStorage) enqueueLocked(key string, event Event) {
+	s.seq++
+	s.pending[key] = s.seq
+	s.queue = append(s.queue, pendingEvent{KeyedEvent: KeyedEvent{Key: key, Event: event}, seq: s.seq})
+	s.queued.Add(1)
+	s.signal()
+}
+
+func (s *AsyncStorage) spillLocked(key string, event Event) error {
+	line, err := json.Marshal(KeyedEvent{Key: key, Event: event})
+	if err != nil {
+		return fmt.Errorf("audit: encode spilled event: %w", err)
+	}
+	if _, err := s.spill.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("audit: spill event: %w", err)
+	}
+	s.seq++
+	s.pending[key] = s.seq
+	s.spillSeq = s.seq
+	s.spilled++
+	s.signal()
+	return nil
+}
+
+// acceptSpillLocked spills an event passed to Store.
+func (s *AsyncStorage) acceptSpillLocked(key string, event Event) error {
+	if err := s.spillLocked(key, event); err != nil {
+		return err
+	}
+	s.queued.Add(1)
+	s.spilledTotal.Add(1)
+	return nil
+}
+
+// signal wakes the background writer without blocking.
+func (s *AsyncStorage) signal() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// run is the background writer loop.
+func (s *AsyncStorage) run() {
+	defer close(s.stopped)
+	for {
+		select {
+		case <-s.quit:
+			return
+		default:
+		}
+
+		batch, last, spilled, ok := s.next()
+		if !ok {
+			select {
+			case <-s.wake:
+				continue
+			case <-s.quit:
+				return
+			}
+		}
+		failed := s.write(batch, spilled)
+		s.releaseSpill(failed)
+		s.complete(batch, last)
+		if len(failed) > 0 && spilled {
+			// Wait for the next Store instead of retrying a failing storage in a loop.
+			select {
+			case <-s.wake:
+			case <-s.quit:
+				return
+			}
+		}
+	}
+}
+
+// next takes the next batch: queued events first, then the spill file once
+// the queue is empty. It reports whether the batch came from the spill file,
+// and false if there is nothing to write.
+func (s *AsyncStorage) next() (batch []KeyedEvent, last uint64, spilled, ok bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if n := min(len(s.queue), s.opts.BatchSize); n > 0 {
+		batch = make([]KeyedEvent, n)
+		for i, p := range s.queue[:n] {
+			batch[i] = p.KeyedEvent
+		}
+		last = s.queue[n-1].seq
+		s.queue = slices.Delete(s.queue, 0, n)
+		close(s.space)
+		s.space = make(chan struct{})
+		return batch, last, false, true
+	}
+
+	if s.spilled > 0 {
+		batch, drained, err := s.drainSpillLocked()
+		if err != nil && s.err == nil {
+			s.err = err
+		}
+		s.spilled = 0
+		s.drained = drained
+		return batch, s.spillSeq, true, true
+	}
+	return nil, 0, false, false
+}
+
+// drainSpillLocked reads every event from the spill file and reports how many
+// bytes it read. The file is left as is; trimSpillLocked removes the events
+// once they are safe elsewhere. Records that cannot be decoded, such as a torn
+// last line, are skipped.
+func (s *AsyncStorage) drainSpillLocked() ([]KeyedEvent, int64, error) {
+	if _, err := s.spill.Seek(0, io.SeekStart); err != nil {
+		return nil, 0, fmt.Errorf("audit: read spill file: %w", err)
+	}
+	var (
+		batch   []KeyedEvent
+		drained int64
+		errs    []error
+	)
+	reader := bufio.NewReader(s.spill)
+	for {
+		line, err := reader.ReadBytes('\n')
+		drained += int64(len(line))
+		if len(bytes.TrimSpace(line)) > 0 {
+			var e KeyedEvent
+			if decodeErr := unmarshalEvent(line, &e); decodeErr != nil {
+				s.dropped.Add(1)
+				errs = append(errs, fmt.Errorf("audit: decode spilled event: %w", decodeErr))
+			} else {
+				batch = append(batch, e)
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("audit: read spill file: %w", err))
+			break
+		}
+	}
+	return batch, drained, errors.Join(errs...)
+}
+
+// releaseSpill removes the events taken from the spill file by the last
+// drain, keeping failed ones, after the writer has handed them to the inner
+// storage. Kept events count as spilled again, so new events are spilled
+// behind them. It does nothing if the last batch came from the queue.
+func (s *AsyncStorage) releaseSpill(failed []KeyedEvent) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.drained == 0 {
+		return
+	}
+	if err := s.trimSpillLocked(s.drained, failed); err != nil && s.err == nil {
+		s.err = err
+	}
+	s.spilled += len(failed)
+	s.drained = 0
+}
+
+// trimSpillLocked replaces the first n bytes of the spill file with keep.
+// Events spilled after those bytes stay in place behind keep.
+func (s *AsyncStorage) trimSpillLocked(n int64, keep []KeyedEvent) error {
+	info, err := s.spill.Stat()
+	if err != nil {
+		return fmt.Errorf("audit: trim spill file: %w", err)
+	}
+	if len(keep) == 0 && info.Size() == n {
+		if err = s.spill.Truncate(0); err != nil {
+			return fmt.Errorf("audit: trim spill file: %w", err)
+		}
+		return nil
+	}
+
+	err = replaceFile(s.opts.SpillPath, 0o600, func(w io.Writer) error {
+		for _, e := range keep {
+			line, encodeErr := json.Marshal(e)
+			if encodeErr != nil {
+				return fmt.Errorf("encode spilled event: %w", encodeErr)
+			}
+			if _, writeErr := w.Write(append(line, '\n')); writeErr != nil {
+				return writeErr
+			}
+		}
+		_, copyErr := io.Copy(w, io.NewSectionReader(s.spill, n, info.Size()-n))
+		return copyErr
+	})
+	if err != nil {
+		return fmt.Errorf("audit: trim spill file: %w", err)
+	}
+	file, err := os.OpenFile(s.opts.SpillPath, os.O_RDWR|os.O_APPEND, 0o600)
+	if err != nil {
+		return fmt.Errorf("audit: reopen spill file: %w", err)
+	}
+	_ = s.spill.Close()
+	s.spill = file
+	return nil
+}
+
+// write stores batch in the inner storage, with one call if it implements
+// BatchStorer, and returns the events it rejected. With keep, which is set for
+// events that are retried, the first rejected event ends the write and the
+// events after it are returned as well, so they are not stored out of order.
+// Writes are detached from the contexts of the original Store calls, which
+// may have ended already.
+func (s *AsyncStorage) write(batch []KeyedEvent, keep bool) []KeyedEvent {
+	if batcher, ok := capability[BatchStorer](s.inner); ok {
+		if err := batcher.StoreBatch(context.Background(), batch); err != nil {
+			for _, e := range batch {
+				s.fail(e, err)
+			}
+			return batch
+		}
+		s.written.Add(uint64(len(batch)))
+		return nil
+	}
+
+	var failed []KeyedEvent
+	for i, e := range batch {
+		if err := s.inner.Store(context.Background(), e.Key, e.Event); err != nil {
+			s.fail(e, err)
+			if keep {
+				return batch[i:]
+			}
+			failed = append(failed, e)
+			continue
+		}
+		s.written.Add(1)
+	}
+	return failed
+}
+
+func (s *AsyncStorage) fail(e KeyedEvent, err error) {
+	s.failed.Add(1)
+	s.mu.Lock()
+	if s.err == nil {
+		s.err = err
+	}
+	s.mu.Unlock()
+	if s.opts.OnError != nil {
+		s.opts.OnError(e.Key, e.Event, err)
+	}
+}
+
+// complete records that every event up to last, the last one of batch, was handled.
+func (s *AsyncStorage) complete(batch []KeyedEvent, last uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.done = last
+	for _, e := range batch {
+		if s.pending[e.Key] <= last {
+			delete(s.pending, e.Key)
+		}
+	}
+	close(s.progress)
+	s.progress = make(chan struct{})
+}
+
+// Flush waits until every event accepted before the call has been handed to
+// the inner storage. It returns the first write error since the previous
+// Flush, or the context error if ctx is done first.
+func (s *AsyncStorage) Flush(ctx context.Context) error {
+	if err := s.wait(ctx); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	err := s.err
+	s.err = nil
+	return err
+}
+
+// wait blocks until the writer has handled every event accepted so far.
+func (s *AsyncStorage) wait(ctx context.Context) error {
+	s.mu.Lock()
+	target := s.seq
+	s.mu.Unlock()
+	return s.waitFor(ctx, target)
+}
+
+// waitKey blocks until the writer has handled every event accepted so far for
+// key. Events left in the spill file by a previous process are always waited
+// for, since their keys are not known until they are read.
+func (s *AsyncStorage) waitKey(ctx context.Context, key string) error {
+	s.mu.Lock()
+	target := max(s.pending[key], s.leftover)
+	s.mu.Unlock()
+	return s.waitFor(ctx, target)
+}
+
+// waitFor blocks until the writer has handled every event up to target.
+func (s *AsyncStorage) waitFor(ctx context.Context, target uint64) error {
+	s.mu.Lock()
+	for s.done < target {
+		progress := s.progress
+		s.mu.Unlock()
+		select {
+		case <-progress:
+		case <-s.stopped:
+			return ErrStorageClosed
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		s.mu.Lock()
+	}
+	s.mu.Unlock()
+	return nil
+}
+
+// Close stops accepting events, waits for queued events to be written and
+// stops the writer. If ctx is done first, events still in memory are spilled
+// with OverflowSpill and dropped otherwise. Close does not close the inner storage.
+func (s *AsyncStorage) Close(ctx context.Context) error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil
+	}
+	s.closed = true
+	close(s.space)
+	s.space = make(chan struct{})
+	s.mu.Unlock()
+
+	flushErr := s.Flush(ctx)
+	close(s.quit)
+	<-s.stopped
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return errors.Join(flushErr, s.saveLeftoverLocked())
+}
+
+// saveLeftoverLocked moves events the writer did not reach into the spill
+// file, ahead of the events already spilled, or drops them without one.
+func (s *AsyncStorage) saveLeftoverLocked() error {
+	leftover := make([]KeyedEvent, 0, len(s.queue))
+	for _, p := range s.queue {
+		leftover = append(leftover, p.KeyedEvent)
+	}
+	s.queue = nil
+	if s.spill == nil {
+		s.dropped.Add(uint64(len(leftover)))
+		return nil
+	}
+
+	s.spilledTotal.Add(uint64(len(leftover)))
+	var errs []error
+	if s.spilled > 0 && len(leftover) > 0 {
+		// Queued events are older than spilled ones, so they go in front.
+		spilled, drained, err := s.drainSpillLocked()
+		errs = append(errs, err)
+		if trimErr := s.trimSpillLocked(drained, append(leftover, spilled...)); trimErr != nil {
+			s.dropped.Add(uint64(len(leftover)))
+			errs = append(errs, trimErr)
+		}
+		leftover = nil
+	}
+	for _, e := range leftover {
+		if err := s.spillLocked(e.Key, e.Event); err != nil {
+			s.dropped.Add(1)
+			errs = append(errs, err)
+		}
+	}
+	errs = append(errs, s.spill.Close())
+	return errors.Join(errs...)
+}
+
+// Stats returns a snapshot of the storage counters.
+func (s *AsyncStorage) Stats() AsyncStats {
+	s.mu.Lock()
+	pending := len(s.queue) + s.spilled
+	s.mu.Unlock()
+	return AsyncStats{
+		Queued:  s.queued.Load(),
+		Written: s.written.Load(),
+		Failed:  s.failed.Load(),
+		Dropped: s.dropped.Load(),
+		Spilled: s.spilledTotal.Load(),
+		Pending: pending,
+	}
+}
+
+// Get waits for pending writes of key and returns the events stored for it.
+func (s *AsyncStorage) Get(ctx context.Context, key string) ([]Event, error) {
+	if err := s.waitKey(ctx, key); err != nil {
+		return nil, err
+	}
+	return s.inner.Get(ctx, key)
+}
+
+// Has waits for pending writes of key and reports whether events exist for it.
+func (s *AsyncStorage) Has(ctx context.Context, key string) (bool, error) {
+	if err := s.waitKey(ctx, key); err != nil {
+		return false, err
+	}
+	return s.inner.Has(ctx, key)
+}
+
+// Clear waits for pending writes of key and removes all events for it.
+func (s *AsyncStorage) Clear(ctx context.Context, key string) error {
+	if err := s.waitKey(ctx, key); err != nil {
+		return err
+	}
+	return s.inner.Clear(ctx, key)
+}
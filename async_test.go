@@ -0,0 +1,309 @@
+package audit_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/w0rng/audit"
+	"github.com/w0rng/audit/internal/be"
+)
+
+// gatedStorage holds every Store until gate is closed.
+type gatedStorage struct {
+	audit.StorageV2
+
+	gate    chan struct{}
+	entered chan struct{}
+}
+
+func newGatedStorage() *gatedStorage {
+	return &gatedStorage{
+		StorageV2: audit.AdaptStorage(audit.NewInMemoryStorage()),
+		gate:      make(chan struct{}),
+		entered:   make(chan struct{}, 64),
+	}
+}
+
+func (s *gatedStorage) Store(ctx context.Context, key string, event audit.Event) error {
+	s.entered <- struct{}{}
+	<-s.gate
+	return s.StorageV2.Store(ctx, key, event)
+}
+
+// descriptions returns the descriptions of the events stored for key in inner.
+func descriptions(t *testing.T, inner audit.StorageV2, key string) []string {
+	t.Helper()
+	events, err := inner.Get(t.Context(), key)
+	be.Err(t, err, nil)
+	result := make([]string, 0, len(events))
+	for _, e := range events {
+		result = append(result, e.Description)
+	}
+	return result
+}
+
+// fillAsync stores e0 and waits until the writer is blocked on it, then stores
+// the remaining descriptions so that they queue up behind it.
+func fillAsync(t *testing.T, async *audit.AsyncStorage, inner *gatedStorage, descs ...string) {
+	t.Helper()
+	be.Err(t, async.Store(t.Context(), "k", audit.Event{Description: "e0"}), nil)
+	<-inner.entered
+	for _, d := range descs {
+		be.Err(t, async.Store(t.Context(), "k", audit.Event{Description: d}), nil)
+	}
+}
+
+func TestAsyncStorage_WithLogger(t *testing.T) {
+	t.Parallel()
+	inner := audit.AdaptStorage(audit.NewInMemoryStorage())
+	async, err := audit.NewAsyncStorage(inner, audit.AsyncOptions{BatchSize: 3})
+	be.Err(t, err, nil)
+	logger := audit.New(audit.WithStorageV2(async))
+	ctx := t.Context()
+
+	for range 10 {
+		be.Err(t, logger.UpdateContext(ctx, "order:1", "alice", "Touched", map[string]audit.Value{}), nil)
+	}
+
+	// Reads wait for pending writes.
+	events, err := logger.EventsContext(ctx, "order:1")
+	be.Err(t, err, nil)
+	be.Equal(t, len(events), 10)
+	be.Err(t, logger.VerifyContext(ctx, "order:1"), nil)
+
+	be.Err(t, async.Close(ctx), nil)
+	be.Equal(t, async.Stats(), audit.AsyncStats{Queued: 10, Written: 10})
+	be.Err(t, async.Store(ctx, "order:1", audit.Event{}), audit.ErrStorageClosed)
+}
+
+func TestAsyncStorage_ReadsWaitOnlyForTheirKey(t *testing.T) {
+	t.Parallel()
+	inner := newGatedStorage()
+	async, err := audit.NewAsyncStorage(inner, audit.AsyncOptions{})
+	be.Err(t, err, nil)
+	logger := audit.New(audit.WithStorageV2(async))
+	be.Err(t, logger.CreateContext(t.Context(), "order:1", "alice", "Created", map[string]audit.Value{}), nil)
+	<-inner.entered
+
+	// The writer is stuck on order:1, which must not hold up a new key.
+	ctx, cancel := context.WithTimeout(t.Context(), time.Second)
+	defer cancel()
+	be.Err(t, logger.CreateContext(ctx, "order:2", "alice", "Created", map[string]audit.Value{}), nil)
+
+	short, cancelShort := context.WithTimeout(t.Context(), 10*time.Millisecond)
+	defer cancelShort()
+	_, err = async.Get(short, "order:1")
+	be.Err(t, err, context.DeadlineExceeded)
+
+	close(inner.gate)
+	for _, key := range []string{"order:1", "order:2"} {
+		events, err := async.Get(t.Context(), key)
+		be.Err(t, err, nil)
+		be.Equal(t, len(events), 1)
+		be.Err(t, logger.VerifyContext(t.Context(), key), nil)
+	}
+	be.Err(t, async.Close(t.Context()), nil)
+}
+
+func TestAsyncStorage_Overflow(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name    string
+		policy  audit.OverflowPolicy
+		want    []string
+		dropped uint64
+		spilled uint64
+	}{
+		{"drop newest", audit.OverflowDropNewest, []string{"e0", "e1", "e2"}, 1, 0},
+		{"drop oldest", audit.OverflowDropOldest, []string{"e0", "e2", "e3"}, 1, 0},
+		{"spill", audit.OverflowSpill, []string{"e0", "e1", "e2", "e3"}, 0, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			inner := newGatedStorage()
+			async, err := audit.NewAsyncStorage(inner, audit.AsyncOptions{
+				QueueSize: 2,
+				Overflow:  tt.policy,
+				SpillPath: filepath.Join(t.TempDir(), "spill.jsonl"),
+			})
+			be.Err(t, err, nil)
+
+			fillAsync(t, async, inner, "e1", "e2", "e3")
+			close(inner.gate)
+			be.Err(t, async.Flush(t.Context()), nil)
+
+			be.Equal(t, descriptions(t, inner, "k"), tt.want)
+			stats := async.Stats()
+			be.Equal(t, stats.Dropped, tt.dropped)
+			be.Equal(t, stats.Spilled, tt.spilled)
+			be.Equal(t, stats.Pending, 0)
+			be.Err(t, async.Close(t.Context()), nil)
+		})
+	}
+}
+
+func TestAsyncStorage_BlockHonorsContext(t *testing.T) {
+	t.Parallel()
+	inner := newGatedStorage()
+	async, err := audit.NewAsyncStorage(inner, audit.AsyncOptions{QueueSize: 1})
+	be.Err(t, err, nil)
+	fillAsync(t, async, inner, "e1")
+
+	ctx, cancel := context.WithTimeout(t.Context(), 10*time.Millisecond)
+	defer cancel()
+	be.Err(t, async.Store(ctx, "k", audit.Event{Description: "e2"}), context.DeadlineExceeded)
+
+	close(inner.gate)
+	be.Err(t, async.Close(t.Context()), nil)
+	be.Equal(t, descriptions(t, inner, "k"), []string{"e0", "e1"})
+}
+
+func TestAsyncStorage_SpillSurvivesClose(t *testing.T) {
+	t.Parallel()
+	path := filepath.Join(t.TempDir(), "spill.jsonl")
+	inner := newGatedStorage()
+	async, err := audit.NewAsyncStorage(inner, audit.AsyncOptions{
+		QueueSize: 1,
+		Overflow:  audit.OverflowSpill,
+		SpillPath: path,
+	})
+	be.Err(t, err, nil)
+	fillAsync(t, async, inner, "e1", "e2", "e3")
+
+	// The writer is stuck on e0: give up on the rest and release it later.
+	ctx, cancel := context.WithCancel(t.Context())
+	cancel()
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		close(inner.gate)
+	}()
+	be.Err(t, async.Close(ctx), context.Canceled)
+	be.Equal(t, descriptions(t, inner, "k"), []string{"e0"})
+
+	// A new writer picks up the spilled events in order.
+	next := audit.AdaptStorage(audit.NewInMemoryStorage())
+	async, err = audit.NewAsyncStorage(next, audit.AsyncOptions{Overflow: audit.OverflowSpill, SpillPath: path})
+	be.Err(t, err, nil)
+	be.Err(t, async.Flush(t.Context()), nil)
+	be.Equal(t, descriptions(t, next, "k"), []string{"e1", "e2", "e3"})
+	be.Err(t, async.Close(t.Context()), nil)
+}
+
+func TestAsyncStorage_SpillKeepsFailedEvents(t *testing.T) {
+	t.Parallel()
+	path := filepath.Join(t.TempDir(), "spill.jsonl")
+	var spill []byte
+	for _, d := range []string{"e1", "e2", "e3"} {
+		line, err := json.Marshal(audit.KeyedEvent{Key: "k", Event: audit.Event{Description: d}})
+		be.Err(t, err, nil)
+		spill = append(append(spill, line...), '\n')
+	}
+	be.Err(t, os.WriteFile(path, spill, 0o600), nil)
+
+	// The backend rejects the spilled events, so they must stay in the file.
+	errBackend := errors.New("backend unavailable")
+	opts := audit.AsyncOptions{Overflow: audit.OverflowSpill, SpillPath: path}
+	async, err := audit.NewAsyncStorage(failingStorage{err: errBackend}, opts)
+	be.Err(t, err, nil)
+	be.Err(t, async.Flush(t.Context()), errBackend)
+	be.Err(t, async.Close(t.Context()), nil)
+	data, err := os.ReadFile(path)
+	be.Err(t, err, nil)
+	be.Equal(t, string(data), string(spill))
+
+	// Once the backend accepts them, they are written and removed.
+	next := audit.AdaptStorage(audit.NewInMemoryStorage())
+	async, err = audit.NewAsyncStorage(next, opts)
+	be.Err(t, err, nil)
+	be.Err(t, async.Flush(t.Context()), nil)
+	be.Equal(t, descriptions(t, next, "k"), []string{"e1", "e2", "e3"})
+	be.Err(t, async.Close(t.Context()), nil)
+	data, err = os.ReadFile(path)
+	be.Err(t, err, nil)
+	be.Equal(t, len(data), 0)
+}
+
+// flakyStorage rejects the first Store of the event described as fail.
+type flakyStorage struct {
+	*gatedStorage
+
+	fail   string
+	err    error
+	failed atomic.Bool
+}
+
+func (s *flakyStorage) Store(ctx context.Context, key string, event audit.Event) error {
+	if event.Description == s.fail && s.failed.CompareAndSwap(false, true) {
+		return s.err
+	}
+	return s.gatedStorage.Store(ctx, key, event)
+}
+
+func TestAsyncStorage_SpillRetriesInOrder(t *testing.T) {
+	t.Parallel()
+	errBackend := errors.New("backend unavailable")
+	inner := &flakyStorage{gatedStorage: newGatedStorage(), fail: "e2", err: errBackend}
+	async, err := audit.NewAsyncStorage(inner, audit.AsyncOptions{
+		QueueSize: 1,
+		Overflow:  audit.OverflowSpill,
+		SpillPath: filepath.Join(t.TempDir(), "spill.jsonl"),
+	})
+	be.Err(t, err, nil)
+	logger := audit.New(audit.WithStorageV2(async))
+	ctx := t.Context()
+
+	// e1 is queued behind e0, e2 and e3 are spilled, and e2 is rejected once.
+	be.Err(t, logger.CreateContext(ctx, "k", "alice", "e0", map[string]audit.Value{}), nil)
+	<-inner.entered
+	for _, d := range []string{"e1", "e2", "e3"} {
+		be.Err(t, logger.UpdateContext(ctx, "k", "alice", d, map[string]audit.Value{}), nil)
+	}
+	close(inner.gate)
+	be.Err(t, async.Flush(ctx), errBackend)
+
+	// A later event must not overtake the kept ones.
+	be.Err(t, logger.UpdateContext(ctx, "k", "alice", "e4", map[string]audit.Value{}), nil)
+	be.Err(t, async.Flush(ctx), nil)
+	be.Equal(t, descriptions(t, inner, "k"), []string{"e0", "e1", "e2", "e3", "e4"})
+	be.Err(t, logger.VerifyContext(ctx, "k"), nil)
+	be.Equal(t, async.Stats().Pending, 0)
+	be.Err(t, async.Close(ctx), nil)
+}
+
+func TestAsyncStorage_WriteErrors(t *testing.T) {
+	t.Parallel()
+	errBackend := errors.New("backend unavailable")
+	var reported atomic.Int32
+	async, err := audit.NewAsyncStorage(failingStorage{err: errBackend}, audit.AsyncOptions{
+		OnError: func(key string, _ audit.Event, err error) {
+			if key == "k" && errors.Is(err, errBackend) {
+				reported.Add(1)
+			}
+		},
+	})
+	be.Err(t, err, nil)
+
+	be.Err(t, async.Store(t.Context(), "k", audit.Event{}), nil)
+	be.Err(t, async.Store(t.Context(), "k", audit.Event{}), nil)
+	be.Err(t, async.Flush(t.Context()), errBackend)
+	be.Err(t, async.Flush(t.Context()), nil)
+	be.Equal(t, reported.Load(), int32(2))
+	be.Equal(t, async.Stats().Failed, uint64(2))
+	be.Err(t, async.Close(t.Context()), nil)
+}
+
+func TestAsyncStorage_SpillRequiresPath(t *testing.T) {
+	t.Parallel()
+	_, err := audit.NewAsyncStorage(audit.AdaptStorage(audit.NewInMemoryStorage()), audit.AsyncOptions{
+		Overflow: audit.OverflowSpill,
+	})
+	be.Err(t, err, "SpillPath")
+}
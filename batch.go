@@ -0,0 +1,149 @@
+package audit
+
+import (
+	"context"
+)
+
+// BatchStorer is an optional interface for storages that can store many
+// events at once more cheaply than one Store call per event, for example
+// under a single lock, write or transaction. Events must be stored in order.
+type BatchStorer interface {
+	StoreBatch(ctx context.Context, events []KeyedEvent) error
+}
+
+// LogEntry describes one event passed to Logger.LogBatch.
+type LogEntry struct {
+	Key         string
+	Action      Action
+	Author      string
+	Description string
+	Payload     map[string]Value
+
+	// IdempotencyKey overrides the idempotency key stored in the context by
+	// WithIdempotencyKey for this entry.
+	IdempotencyKey string
+}
+
+// StoreBatch appends events under a single lock. It implements BatchStorer.
+func (s *InMemoryStorage) StoreBatch(ctx context.Context, events []KeyedEvent) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, e := range events {
+		s.appendLocked(e.Key, e.Event)
+	}
+	return nil
+}
+
+// LogBatch records entries in order, as LogChangeContext would, and appends
+// each one to its key's hash chain. All entries share the same timestamp.
+//
+// If the storage implements BatchStorer the events are stored with one
+// StoreBatch call; otherwise they are stored one by one and LogBatch stops at
+// the first error, leaving the entries before it stored. Use Begin to record
+// events atomically where the storage supports it.
+func (l *Logger) LogBatch(ctx context.Context, entries []LogEntry) error {
+	return l.logEntries(ctx, entries, "", false)
+}
+
+// logEntries builds, seals and stores events for entries, tagging them with
+// txID. With atomic set, a TxStorage is preferred over a BatchStorer.
+func (l *Logger) logEntries(ctx context.Context, entries []LogEntry, txID string, atomic bool) error {
+	if len(entries) == 0 {
+		return ctx.Err()
+	}
+
+	now := l.clock()
+	events := make([]KeyedEvent, len(entries))
+	for i, entry := range entries {
+		events[i] = KeyedEvent{Key: entry.Key, Event: Event{
+			ID:          l.newID(now),
+			Timestamp:   now,
+			Action:      entry.Action,
+			Author:      entry.Author,
+			Description: entry.Description,
+			Payload:     l.digestPayload(entry.Payload),
+			TxID:        txID,
+
+			IdempotencyKey: entry.IdempotencyKey,
+		}}
+		applyContext(ctx, &events[i].Event)
+	}
+	_, err := l.storeEvents(ctx, events, atomic)
+	return err
+}
+
+// storeEvents seals events into their keys' hash chains and stores them,
+// skipping duplicates of stored events, and returns how many were stored.
+// With atomic set, a TxStorage is preferred over a BatchStorer.
+func (l *Logger) storeEvents(ctx context.Context, events []KeyedEvent, atomic bool) (int, error) {
+	keys := make([]string, len(events))
+	for i := range events {
+		keys[i] = events[i].Key
+	}
+	unlock := l.chain.lockKeys(keys)
+	defer unlock()
+
+	events, err := l.withoutDuplicates(ctx, events)
+	if err != nil || len(events) == 0 {
+		return 0, err
+	}
+	if err = l.sealBatch(ctx, events); err != nil {
+		return 0, err
+	}
+
+	store := l.storeBatch
+	if txStorage, ok := capability[TxStorage](l.storage); ok && atomic {
+		store = func(ctx context.Context, events []KeyedEvent) error {
+			return storeTx(ctx, txStorage, events)
+		}
+	} else if batcher, ok := capability[BatchStorer](l.storage); ok {
+		store = batcher.StoreBatch
+	}
+
+	if err = store(ctx, events); err != nil {
+		// The storage may have kept part of the batch: reload heads on next use.
+		for _, key := range keys {
+			l.chain.forget(key)
+		}
+		return 0, err
+	}
+	for _, e := range events {
+		l.chain.advance(e.Key, chainHead{sequence: e.Sequence, hash: e.Hash})
+		l.maybeSnapshot(ctx, e.Key, e.Sequence)
+		l.subs.publish(e.Key, e.Event)
+	}
+	return len(events), nil
+}
+
+// storeBatch stores events one by one, stopping at the first error.
+func (l *Logger) storeBatch(ctx context.Context, events []KeyedEvent) error {
+	for _, e := range events {
+		if err := l.storage.Store(ctx, e.Key, e.Event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sealBatch links events into their keys' hash chains, in order.
+// Callers must hold the chain locks for all keys.
+func (l *Logger) sealBatch(ctx context.Context, events []KeyedEvent) error {
+	heads := make(map[string]chainHead)
+	for i := range events {
+		key := events[i].Key
+		head, ok := heads[key]
+		if !ok {
+			var err error
+			if head, err = l.head(ctx, key); err != nil {
+				return err
+			}
+		}
+		seal(&events[i].Event, head)
+		heads[key] = chainHead{sequence: events[i].Sequence, hash: events[i].Hash}
+	}
+	return nil
+}
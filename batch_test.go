@@ -0,0 +1,84 @@
+package audit_test
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/w0rng/audit"
+	"github.com/w0rng/audit/internal/be"
+)
+
+func batchEntries() []audit.LogEntry {
+	return []audit.LogEntry{
+		{Key: "order:1", Action: audit.ActionUpdate, Author: "importer", Description: "Imported",
+			Payload: map[string]audit.Value{"status": audit.PlainValue("paid")}},
+		{Key: "order:2", Action: audit.ActionCreate, Author: "importer", Description: "Imported",
+			Payload: map[string]audit.Value{"status": audit.PlainValue("new")}},
+		{Key: "order:1", Action: audit.ActionUpdate, Author: "importer", Description: "Imported",
+			Payload: map[string]audit.Value{"status": audit.PlainValue("shipped")}},
+	}
+}
+
+func TestLogger_LogBatch(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name    string
+		storage func(t *testing.T) audit.StorageV2
+	}{
+		{"in memory batch", func(*testing.T) audit.StorageV2 {
+			return audit.AdaptStorage(audit.NewInMemoryStorage())
+		}},
+		{"file batch", func(t *testing.T) audit.StorageV2 {
+			return openFileStorage(t, filepath.Join(t.TempDir(), "audit.jsonl"), audit.FileStorageOptions{})
+		}},
+		{"per event fallback", func(*testing.T) audit.StorageV2 {
+			return audit.AdaptStorage(newMockStorage())
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			logger := audit.New(audit.WithStorageV2(tt.storage(t)))
+			ctx := t.Context()
+
+			be.Err(t, logger.CreateContext(ctx, "order:1", "alice", "Created", map[string]audit.Value{
+				"status": audit.PlainValue("new"),
+			}), nil)
+			be.Err(t, logger.LogBatch(ctx, batchEntries()), nil)
+			be.Err(t, logger.UpdateContext(ctx, "order:1", "alice", "Delivered", map[string]audit.Value{
+				"status": audit.PlainValue("delivered"),
+			}), nil)
+
+			events, err := logger.EventsContext(ctx, "order:1")
+			be.Err(t, err, nil)
+			be.Equal(t, len(events), 4)
+			be.Equal(t, events[2].Sequence, uint64(3))
+			be.Equal(t, events[2].Payload["status"].Data, any("shipped"))
+			be.Err(t, logger.VerifyContext(ctx, "order:1"), nil)
+			be.Err(t, logger.VerifyContext(ctx, "order:2"), nil)
+		})
+	}
+}
+
+func TestLogger_LogBatch_FallbackStoresEachEvent(t *testing.T) {
+	t.Parallel()
+	mock := newMockStorage()
+	logger := audit.New(audit.WithStorage(mock))
+
+	be.Err(t, logger.LogBatch(t.Context(), batchEntries()), nil)
+	be.Equal(t, mock.calls["Store"], 3)
+
+	var _ audit.BatchStorer = (*audit.InMemoryStorage)(nil)
+	var _ audit.BatchStorer = (*audit.FileStorage)(nil)
+}
+
+func TestLogger_LogBatch_Errors(t *testing.T) {
+	t.Parallel()
+	errBackend := errors.New("backend unavailable")
+	logger := audit.New(audit.WithStorageV2(failingStorage{err: errBackend}))
+
+	be.Err(t, logger.LogBatch(t.Context(), batchEntries()), errBackend)
+	be.Err(t, logger.LogBatch(t.Context(), nil), nil)
+}
@@ -0,0 +1,380 @@
+package audit
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"sync"
+	"time"
+)
+
+// ErrChainBroken is matched by errors returned from Verify when an entity's
+// event stream has been modified, reordered or truncated.
+var ErrChainBroken = errors.New("audit: hash chain broken")
+
+// ChainError describes the first broken link found by Verify.
+type ChainError struct {
+	// Key is the entity whose stream failed verification.
+	Key string
+
+	// Index is the position of the offending event in the stream.
+	Index int
+
+	// Sequence is the sequence number recorded on the offending event.
+	Sequence uint64
+
+	// Reason explains which check failed.
+	Reason string
+}
+
+func (e *ChainError) Error() string {
+	return fmt.Sprintf("audit: hash chain broken for %q at event %d (sequence %d): %s",
+		e.Key, e.Index, e.Sequence, e.Reason)
+}
+
+// Unwrap makes errors.Is(err, ErrChainBroken) report true.
+func (e *ChainError) Unwrap() error {
+	return ErrChainBroken
+}
+
+// saltSize is the length of the random salt generated when WithHashSalt is not used.
+const saltSize = 32
+
+// WithHashSalt sets the secret used to digest hidden values created with HiddenValueOf.
+// Use a stable salt when digests must be comparable across process restarts.
+// If not specified, a random salt is generated for each Logger.
+func WithHashSalt(salt []byte) Option {
+	return func(l *Logger) {
+		l.salt = bytes.Clone(salt)
+	}
+}
+
+// HiddenValueOf creates a hidden Value that still records a fingerprint of v.
+// The Logger replaces v with a salted digest before the event is stored, so the
+// hash chain covers the secret without revealing it.
+func HiddenValueOf(v any) Value {
+	return Value{Data: v, Hidden: true}
+}
+
+// chainHead is the last link of an entity's hash chain.
+type chainHead struct {
+	sequence uint64
+	hash     string
+}
+
+// chainStripes is the number of key-striped locks serializing chain appends.
+const chainStripes = 64
+
+// DefaultHeadCacheSize is the number of chain heads a Logger caches unless
+// configured otherwise with WithHeadCacheSize.
+const DefaultHeadCacheSize = 10000
+
+// WithHeadCacheSize sets how many entities' chain heads the Logger keeps in
+// memory. The least recently used head is evicted first and reloaded from
+// storage on the entity's next event. A size of zero or less disables the
+// cache. Defaults to DefaultHeadCacheSize.
+func WithHeadCacheSize(size int) Option {
+	return func(l *Logger) {
+		l.chain.limit = size
+	}
+}
+
+// chain tracks the head of each entity's hash chain and serializes appends per key.
+type chain struct {
+	locks [chainStripes]sync.Mutex
+
+	mu    sync.Mutex
+	limit int                      // maximum number of cached heads
+	heads map[string]*list.Element // values are *cachedHead
+	order list.List                // most recently used first
+}
+
+// cachedHead is an entry of the chain head cache.
+type cachedHead struct {
+	key  string
+	head chainHead
+}
+
+// cached returns the entry held by elem.
+func cached(elem *list.Element) *cachedHead {
+	entry, _ := elem.Value.(*cachedHead)
+	return entry
+}
+
+// stripe returns the index of the lock guarding key.
+func stripe(key string) int {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(key))
+	return int(h.Sum32() % chainStripes)
+}
+
+// lock acquires the stripe lock for key and returns its unlock function.
+func (c *chain) lock(key string) func() {
+	m := &c.locks[stripe(key)]
+	m.Lock()
+	return m.Unlock
+}
+
+// lockKeys acquires the stripe locks for all keys, in stripe order to avoid
+// deadlocks, and returns a function that releases them.
+func (c *chain) lockKeys(keys []string) func() {
+	var held [chainStripes]bool
+	for _, key := range keys {
+		held[stripe(key)] = true
+	}
+	for i := range held {
+		if held[i] {
+			c.locks[i].Lock()
+		}
+	}
+	return func() {
+		for i := range held {
+			if held[i] {
+				c.locks[i].Unlock()
+			}
+		}
+	}
+}
+
+// head returns the cached chain head for key.
+func (c *chain) head(key string) (chainHead, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	elem, ok := c.heads[key]
+	if !ok {
+		return chainHead{}, false
+	}
+	c.order.MoveToFront(elem)
+	return cached(elem).head, true
+}
+
+// advance records a new chain head for key, evicting the least recently
+// used head when the cache is full.
+func (c *chain) advance(key string, head chainHead) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.limit <= 0 {
+		return
+	}
+	if elem, ok := c.heads[key]; ok {
+		cached(elem).head = head
+		c.order.MoveToFront(elem)
+		return
+	}
+	if c.heads == nil {
+		c.heads = make(map[string]*list.Element)
+	}
+	c.heads[key] = c.order.PushFront(&cachedHead{key: key, head: head})
+	if c.order.Len() > c.limit {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.heads, cached(oldest).key)
+	}
+}
+
+// forget drops the cached chain head for key, so that it is reloaded from storage.
+func (c *chain) forget(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.heads[key]; ok {
+		c.order.Remove(elem)
+		delete(c.heads, key)
+	}
+}
+
+// head returns the chain head for key, loading it from storage on first use.
+// Callers must hold the chain lock for key.
+func (l *Logger) head(ctx context.Context, key string) (chainHead, error) {
+	if head, ok := l.chain.head(key); ok {
+		return head, nil
+	}
+
+	// Has is usually much cheaper than Get, and new keys are the common case.
+	has, err := l.storage.Has(ctx, key)
+	if err != nil {
+		return chainHead{}, err
+	}
+	if !has {
+		// Every event may have been pruned; the chain then continues from the checkpoint.
+		head, _, err := l.checkpoint(ctx, key)
+		return head, err
+	}
+	events, err := l.storage.Get(ctx, key)
+	if err != nil {
+		return chainHead{}, err
+	}
+	if len(events) == 0 {
+		return chainHead{}, nil
+	}
+	last := events[len(events)-1]
+	return chainHead{sequence: max(last.Sequence, uint64(len(events))), hash: last.Hash}, nil
+}
+
+// seal links event to head and computes its hash.
+func seal(event *Event, head chainHead) {
+	event.Sequence = head.sequence + 1
+	event.PrevHash = head.hash
+	event.Hash = event.computeHash()
+}
+
+// digestPayload returns a copy of payload where hidden values carrying data
+// are replaced by their salted digest.
+func (l *Logger) digestPayload(payload map[string]Value) map[string]Value {
+	result := make(map[string]Value, len(payload))
+	for field, val := range payload {
+		if val.Hidden && val.Data != nil {
+			mac := hmac.New(sha256.New, l.salt)
+			_, _ = mac.Write(canonicalJSON(val.Data))
+			val = Value{Hidden: true, Digest: hex.EncodeToString(mac.Sum(nil))}
+		}
+		result[field] = val
+	}
+	return result
+}
+
+// newSalt returns a random salt for hidden value digests.
+func newSalt() []byte {
+	salt := make([]byte, saltSize)
+	_, _ = rand.Read(salt)
+	return salt
+}
+
+// canonicalEvent is the hashed representation of an Event. Field order is fixed
+// and map keys are sorted by encoding/json, so the encoding is deterministic.
+// Metadata added later is omitted when empty, keeping hashes of older events valid.
+type canonicalEvent struct {
+	Sequence       uint64                    `json:"seq"`
+	PrevHash       string                    `json:"prev"`
+	Timestamp      string                    `json:"ts"`
+	Action         Action                    `json:"action"`
+	Author         string                    `json:"author"`
+	Description    string                    `json:"description"`
+	Payload        map[string]canonicalValue `json:"payload"`
+	Actor          *Actor                    `json:"actor,omitempty"`
+	RequestID      string                    `json:"request_id,omitempty"`
+	TxID           string                    `json:"tx_id,omitempty"`
+	ID             string                    `json:"id,omitempty"`
+	IdempotencyKey string                    `json:"idempotency_key,omitempty"`
+}
+
+// canonicalValue is the hashed representation of a Value.
+// Hidden values contribute only their digest.
+type canonicalValue struct {
+	Data   json.RawMessage `json:"data,omitempty"`
+	Hidden bool            `json:"hidden,omitempty"`
+	Digest string          `json:"digest,omitempty"`
+}
+
+// computeHash returns the hex-encoded SHA-256 of the event's canonical encoding.
+func (e *Event) computeHash() string {
+	c := canonicalEvent{
+		Sequence:       e.Sequence,
+		PrevHash:       e.PrevHash,
+		Timestamp:      e.Timestamp.UTC().Format(time.RFC3339Nano),
+		Action:         e.Action,
+		Author:         e.Author,
+		Description:    e.Description,
+		Payload:        make(map[string]canonicalValue, len(e.Payload)),
+		Actor:          e.Actor,
+		RequestID:      e.RequestID,
+		TxID:           e.TxID,
+		ID:             e.ID,
+		IdempotencyKey: e.IdempotencyKey,
+	}
+	for field, val := range e.Payload {
+		cv := canonicalValue{Hidden: val.Hidden, Digest: val.Digest}
+		if !val.Hidden && val.Data != nil {
+			cv.Data = canonicalJSON(val.Data)
+		}
+		c.Payload[field] = cv
+	}
+
+	// Every field is either a basic type or valid raw JSON, so this cannot fail.
+	data, _ := json.Marshal(c)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// canonicalJSON encodes v so that it hashes the same before and after a JSON
+// round trip through storage: structs become objects with sorted keys and
+// numbers keep their textual form. Values that cannot be encoded as JSON
+// (channels, functions) fall back to their fmt representation.
+func canonicalJSON(v any) []byte {
+	data, err := json.Marshal(v)
+	if err != nil {
+		data, _ = json.Marshal(fmt.Sprint(v))
+		return data
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var generic any
+	if err := dec.Decode(&generic); err != nil {
+		return data
+	}
+	data, _ = json.Marshal(generic)
+	return data
+}
+
+// Verify walks the events stored for key and checks the hash chain: sequence
+// numbers must increase by one, each event must reference the previous hash
+// and each hash must match the event's content. It returns nil if the chain is
+// intact and a *ChainError describing the first broken link otherwise.
+//
+// If old events were removed by a Pruner, the first remaining event must
+// continue from the storage's Checkpoint for key.
+//
+// Storage errors are returned as is; use VerifyContext to pass a context.
+func (l *Logger) Verify(key string) error {
+	return l.VerifyContext(context.Background(), key)
+}
+
+// VerifyContext is like Verify but honors ctx.
+func (l *Logger) VerifyContext(ctx context.Context, key string) error {
+	events, err := l.storage.Get(ctx, key)
+	if err != nil {
+		return err
+	}
+
+	var prev chainHead
+	pruned := false
+	if len(events) > 0 && events[0].Sequence != 1 {
+		if prev, pruned, err = l.checkpoint(ctx, key); err != nil {
+			return err
+		}
+	}
+	for i, e := range events {
+		if reason := checkLink(e, prev, i == 0 && !pruned); reason != "" {
+			return &ChainError{Key: key, Index: i, Sequence: e.Sequence, Reason: reason}
+		}
+		prev = chainHead{sequence: e.Sequence, hash: e.Hash}
+	}
+	return nil
+}
+
+// checkLink validates a single event against the previous chain head.
+func checkLink(e Event, prev chainHead, first bool) string {
+	switch {
+	case e.Hash == "":
+		return "event is not sealed"
+	case first && (e.Sequence != 1 || e.PrevHash != ""):
+		return "stream does not start at sequence 1"
+	case !first && e.Sequence != prev.sequence+1:
+		return fmt.Sprintf("expected sequence %d", prev.sequence+1)
+	case !first && e.PrevHash != prev.hash:
+		return "previous hash mismatch"
+	}
+
+	if e.computeHash() != e.Hash {
+		return "content hash mismatch"
+	}
+	return ""
+}
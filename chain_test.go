@@ -0,0 +1,294 @@
+package audit_test
+
+import (
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/w0rng/audit"
+	"github.com/w0rng/audit/internal/be"
+)
+
+func TestLogger_HashChain(t *testing.T) {
+	t.Parallel()
+	logger := audit.New()
+
+	logger.Create("order:1", "alice", "Created", map[string]audit.Value{
+		"status": audit.PlainValue("pending"),
+	})
+	logger.Update("order:1", "bob", "Approved", map[string]audit.Value{
+		"status": audit.PlainValue("approved"),
+	})
+	logger.Create("order:2", "alice", "Created", map[string]audit.Value{})
+
+	events := logger.Events("order:1")
+	be.Equal(t, len(events), 2)
+	be.Equal(t, events[0].Sequence, uint64(1))
+	be.Equal(t, events[0].PrevHash, "")
+	be.Equal(t, len(events[0].Hash), 64)
+	be.Equal(t, events[1].Sequence, uint64(2))
+	be.Equal(t, events[1].PrevHash, events[0].Hash)
+
+	// Chains are per key.
+	be.Equal(t, logger.Events("order:2")[0].Sequence, uint64(1))
+
+	be.Err(t, logger.Verify("order:1"), nil)
+	be.Err(t, logger.Verify("order:2"), nil)
+	be.Err(t, logger.Verify("missing"), nil)
+}
+
+func TestLogger_Verify_DetectsTampering(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		tamper    func(storage *audit.InMemoryStorage)
+		wantIndex int
+		wantMsg   string
+	}{
+		{
+			name: "edited author",
+			tamper: func(storage *audit.InMemoryStorage) {
+				storage.Get("doc:1")[1].Author = "mallory"
+			},
+			wantIndex: 1,
+			wantMsg:   "content hash mismatch",
+		},
+		{
+			name: "edited payload",
+			tamper: func(storage *audit.InMemoryStorage) {
+				storage.Get("doc:1")[0].Payload["title"] = audit.PlainValue("forged")
+			},
+			wantIndex: 0,
+			wantMsg:   "content hash mismatch",
+		},
+		{
+			name: "removed event",
+			tamper: func(storage *audit.InMemoryStorage) {
+				events := storage.Get("doc:1")
+				storage.Clear("doc:1")
+				storage.Store("doc:1", events[0])
+				storage.Store("doc:1", events[2])
+			},
+			wantIndex: 1,
+			wantMsg:   "expected sequence 2",
+		},
+		{
+			name: "removed first event",
+			tamper: func(storage *audit.InMemoryStorage) {
+				events := storage.Get("doc:1")
+				storage.Clear("doc:1")
+				storage.Store("doc:1", events[1])
+				storage.Store("doc:1", events[2])
+			},
+			wantIndex: 0,
+			wantMsg:   "does not start at sequence 1",
+		},
+		{
+			name: "unsealed event",
+			tamper: func(storage *audit.InMemoryStorage) {
+				storage.Store("doc:1", audit.Event{Author: "mallory"})
+			},
+			wantIndex: 3,
+			wantMsg:   "not sealed",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			storage := audit.NewInMemoryStorage()
+			logger := audit.New(audit.WithStorage(storage))
+			for _, title := range []string{"draft", "review", "final"} {
+				logger.Update("doc:1", "alice", "Edited", map[string]audit.Value{
+					"title": audit.PlainValue(title),
+				})
+			}
+			be.Err(t, logger.Verify("doc:1"), nil)
+
+			tt.tamper(storage)
+
+			err := logger.Verify("doc:1")
+			be.Err(t, err, audit.ErrChainBroken)
+			be.Err(t, err, tt.wantMsg)
+
+			var chainErr *audit.ChainError
+			be.True(t, errors.As(err, &chainErr))
+			be.Equal(t, chainErr.Key, "doc:1")
+			be.Equal(t, chainErr.Index, tt.wantIndex)
+		})
+	}
+}
+
+func TestHiddenValueOf(t *testing.T) {
+	t.Parallel()
+	salt := []byte("pepper")
+	logger := audit.New(audit.WithHashSalt(salt))
+
+	logger.Create("user:1", "admin", "Created", map[string]audit.Value{
+		"password": audit.HiddenValueOf("hunter2"),
+		"token":    audit.HiddenValue(),
+	})
+	logger.Update("user:1", "admin", "Password unchanged", map[string]audit.Value{
+		"password": audit.HiddenValueOf("hunter2"),
+	})
+	logger.Update("user:1", "admin", "Password changed", map[string]audit.Value{
+		"password": audit.HiddenValueOf("correct horse"),
+	})
+
+	events := logger.Events("user:1")
+	first := events[0].Payload["password"]
+	be.True(t, first.Hidden)
+	be.Equal(t, first.Data, nil)
+	be.Equal(t, len(first.Digest), 64)
+	be.Equal(t, events[0].Payload["token"].Digest, "")
+	be.Equal(t, events[1].Payload["password"].Digest, first.Digest)
+	be.True(t, events[2].Payload["password"].Digest != first.Digest)
+	be.Err(t, logger.Verify("user:1"), nil)
+
+	// A different salt yields a different digest for the same secret.
+	other := audit.New(audit.WithHashSalt([]byte("salt")))
+	other.Create("user:1", "admin", "Created", map[string]audit.Value{
+		"password": audit.HiddenValueOf("hunter2"),
+	})
+	be.True(t, other.Events("user:1")[0].Payload["password"].Digest != first.Digest)
+
+	// Logs never reveal hidden values.
+	for _, field := range logger.Logs("user:1")[2].Fields {
+		be.Equal(t, field.From, any(audit.HideText))
+		be.Equal(t, field.To, any(audit.HideText))
+	}
+}
+
+func TestLogger_Verify_AfterJSONRoundTrip(t *testing.T) {
+	t.Parallel()
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	storage, err := audit.OpenFileStorage(path, audit.FileStorageOptions{Sync: audit.SyncNever})
+	be.Err(t, err, nil)
+	t.Cleanup(func() { _ = storage.Close() })
+
+	type address struct {
+		Street string
+		City   string
+	}
+	logger := audit.New(audit.WithStorageV2(storage))
+	be.Err(t, logger.CreateContext(t.Context(), "customer:1", "admin", "Created", map[string]audit.Value{
+		"age":     audit.PlainValue(42),
+		"score":   audit.PlainValue(int64(1) << 40),
+		"big":     audit.PlainValue(int64(9007199254740993)),
+		"ratio":   audit.PlainValue(0.25),
+		"address": audit.PlainValue(address{Street: "Main", City: "Springfield"}),
+		"tags":    audit.PlainValue([]string{"vip", "beta"}),
+		"secret":  audit.HiddenValueOf(map[string]int{"pin": 1234}),
+	}), nil)
+
+	events, err := storage.Get(t.Context(), "customer:1")
+	be.Err(t, err, nil)
+	be.Equal(t, reflect.TypeOf(events[0].Payload["age"].Data), reflect.TypeFor[json.Number]())
+	be.Equal(t, events[0].Payload["big"].Data, any(json.Number("9007199254740993")))
+	be.Err(t, logger.Verify("customer:1"), nil)
+
+	// The values also survive the index rebuild when the file is reopened.
+	be.Err(t, storage.Close(), nil)
+	storage = openFileStorage(t, path, audit.FileStorageOptions{})
+	logger = audit.New(audit.WithStorageV2(storage))
+	be.Err(t, logger.Verify("customer:1"), nil)
+}
+
+func TestLogger_Clear(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name    string
+		storage func(t *testing.T) audit.StorageV2
+	}{
+		{"memory", func(*testing.T) audit.StorageV2 { return audit.AdaptStorage(audit.NewInMemoryStorage()) }},
+		{"file", func(t *testing.T) audit.StorageV2 {
+			return openFileStorage(t, filepath.Join(t.TempDir(), "audit.jsonl"), audit.FileStorageOptions{})
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			logger := audit.New(audit.WithStorageV2(tt.storage(t)))
+			logN(logger, "order:1", 3)
+			logN(logger, "order:2", 1)
+
+			be.Err(t, logger.Clear(t.Context(), "order:1"), nil)
+			be.Equal(t, len(logger.Events("order:1")), 0)
+			logN(logger, "order:1", 1)
+
+			events := logger.Events("order:1")
+			be.Equal(t, len(events), 1)
+			be.Equal(t, events[0].Sequence, uint64(1))
+			be.Equal(t, events[0].PrevHash, "")
+			be.Err(t, logger.Verify("order:1"), nil)
+			be.Equal(t, len(logger.Events("order:2")), 1)
+		})
+	}
+}
+
+func TestLogger_HeadCacheSize(t *testing.T) {
+	t.Parallel()
+	mock := newMockStorage()
+	logger := audit.New(audit.WithStorage(mock), audit.WithHeadCacheSize(2))
+	for _, key := range []string{"a", "b", "c"} {
+		logN(logger, key, 1)
+	}
+	be.Equal(t, mock.calls["Has"], 3)
+
+	// "a" was evicted, so its head is reloaded; "c" is still cached.
+	logN(logger, "a", 1)
+	be.Equal(t, mock.calls["Has"], 4)
+	logN(logger, "c", 1)
+	be.Equal(t, mock.calls["Has"], 4)
+
+	for _, key := range []string{"a", "b", "c"} {
+		be.Err(t, logger.Verify(key), nil)
+	}
+	be.Equal(t, logger.Events("a")[1].Sequence, uint64(2))
+}
+
+func TestLogger_HashChain_Concurrency(t *testing.T) {
+	t.Parallel()
+	logger := audit.New()
+	const goroutines = 20
+	const eventsPerGoroutine = 10
+
+	var wg sync.WaitGroup
+	for range goroutines {
+		wg.Go(func() {
+			for j := range eventsPerGoroutine {
+				logger.Update("shared", "writer", "Write", map[string]audit.Value{
+					"count": audit.PlainValue(j),
+				})
+			}
+		})
+	}
+	wg.Wait()
+
+	events := logger.Events("shared")
+	be.Equal(t, len(events), goroutines*eventsPerGoroutine)
+	be.Equal(t, events[len(events)-1].Sequence, uint64(goroutines*eventsPerGoroutine))
+	be.Err(t, logger.Verify("shared"), nil)
+}
+
+func TestLogger_HashChain_ResumesFromStorage(t *testing.T) {
+	t.Parallel()
+	storage := audit.NewInMemoryStorage()
+
+	first := audit.New(audit.WithStorage(storage))
+	first.Create("item:1", "alice", "Created", map[string]audit.Value{})
+
+	// A new Logger over the same storage continues the existing chain.
+	second := audit.New(audit.WithStorage(storage))
+	second.Update("item:1", "bob", "Updated", map[string]audit.Value{})
+
+	events := second.Events("item:1")
+	be.Equal(t, events[1].Sequence, uint64(2))
+	be.Equal(t, events[1].PrevHash, events[0].Hash)
+	be.Err(t, second.Verify("item:1"), nil)
+}
@@ -0,0 +1,35 @@
+// Command audit inspects audit stores from the command line.
+//
+// Usage:
+//
+//	audit [-store path] [-driver jsonl|sqlite] <command> [flags] [args]
+//
+// Commands:
+//
+//	events <key>    print the events of an entity
+//	log <key>       print the field-level change history of an entity
+//	keys            list entity keys, optionally with -prefix
+//	export          write all events as CSV, JSON or NDJSON to stdout
+//	import <file>   append the events of a CSV, JSON or NDJSON export
+//	verify [key]    check the hash chains of the given or all entities
+//
+// The store is a JSON Lines file written by audit.FileStorage or an SQLite
+// database written by sqlstore. Only import writes to it; the other commands
+// open it read-only. Do not open a JSON Lines file that another process is
+// writing to.
+package main
+
+import (
+	"context"
+	"os"
+	"os/signal"
+
+	"github.com/w0rng/audit/internal/cli"
+)
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	code := cli.Run(ctx, os.Args[1:], os.Stdout, os.Stderr)
+	stop()
+	os.Exit(code)
+}
@@ -0,0 +1,276 @@
+package audit
+
+import (
+	"encoding/json"
+	"math"
+	"math/big"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Comparator reports whether two payload values are equal.
+// Logs uses it to decide whether a field changed between events.
+type Comparator func(a, b any) bool
+
+// WithComparator sets the function used by Logs to compare payload values.
+// If not specified, Equal is used.
+func WithComparator(c Comparator) Option {
+	return func(l *Logger) {
+		l.compare = c
+	}
+}
+
+// maxCompareDepth bounds recursion into nested values, which also stops cycles.
+const maxCompareDepth = 64
+
+// timeType is the reflect.Type of time.Time.
+var timeType = reflect.TypeFor[time.Time]() //nolint:gochecknoglobals // immutable type descriptor.
+
+// Equal reports whether a and b are deeply equal payload values. Unlike ==
+// it never panics, and unlike reflect.DeepEqual it is tolerant of the type
+// changes values go through when they are logged and stored:
+//   - numbers compare by value, so int(5), int64(5), float64(5) and json.Number("5") are equal
+//   - time.Time values compare with Time.Equal
+//   - pointers compare by the values they point to
+//   - slices, arrays, maps and structs compare element by element, even when
+//     their static types differ ([]any{"a"} equals []string{"a"})
+//   - strings and bools compare by value regardless of their named type, so a
+//     json.Number also equals a string with the same text
+func Equal(a, b any) bool {
+	return equalValue(reflect.ValueOf(a), reflect.ValueOf(b), 0)
+}
+
+func equalValue(a, b reflect.Value, depth int) bool {
+	if depth > maxCompareDepth {
+		return false
+	}
+	a, b = indirect(a), indirect(b)
+
+	switch {
+	case !a.IsValid() || !b.IsValid():
+		return !a.IsValid() && !b.IsValid()
+	case a.Type() == timeType && b.Type() == timeType && a.CanInterface() && b.CanInterface():
+		return a.Interface().(time.Time).Equal(b.Interface().(time.Time)) //nolint:forcetypeassert // checked above.
+	}
+
+	// Only two numbers compare by value. A json.Number compared with a plain
+	// string falls through to the string comparison, whichever side it is on.
+	x, aNumber := number(a)
+	y, bNumber := number(b)
+	if aNumber && bNumber {
+		return x != nil && y != nil && x.Cmp(y) == 0
+	}
+
+	switch a.Kind() {
+	case reflect.String:
+		return b.Kind() == reflect.String && a.String() == b.String()
+	case reflect.Bool:
+		return b.Kind() == reflect.Bool && a.Bool() == b.Bool()
+	case reflect.Slice, reflect.Array:
+		return equalSeq(a, b, depth)
+	case reflect.Map:
+		return equalMap(a, b, depth)
+	case reflect.Struct:
+		return equalStruct(a, b, depth)
+	case reflect.Complex64, reflect.Complex128:
+		return (b.Kind() == reflect.Complex64 || b.Kind() == reflect.Complex128) && a.Complex() == b.Complex()
+	default:
+		// Channels, functions and unsafe pointers: only identical values are equal.
+		return a.Kind() == b.Kind() && a.Type() == b.Type() && a.Pointer() == b.Pointer()
+	}
+}
+
+// indirect unwraps interfaces and non-nil pointers. Nil pointers and
+// interfaces become the invalid Value, so they compare equal to nil.
+func indirect(v reflect.Value) reflect.Value {
+	for v.IsValid() && (v.Kind() == reflect.Interface || v.Kind() == reflect.Pointer) {
+		if v.IsNil() {
+			return reflect.Value{}
+		}
+		v = v.Elem()
+	}
+	return v
+}
+
+// number converts numeric kinds and json.Number to an exact big.Float.
+// It returns (nil, true) for NaN, which is a number but equal to nothing.
+func number(v reflect.Value) (*big.Float, bool) {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return new(big.Float).SetInt64(v.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return new(big.Float).SetUint64(v.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		if math.IsNaN(v.Float()) {
+			return nil, true
+		}
+		return new(big.Float).SetFloat64(v.Float()), true
+	case reflect.String:
+		if v.Type() != reflect.TypeFor[json.Number]() {
+			return nil, false
+		}
+		f, _, err := big.ParseFloat(v.String(), 10, big.MaxPrec, big.ToNearestEven)
+		return f, err == nil
+	default:
+		return nil, false
+	}
+}
+
+func equalSeq(a, b reflect.Value, depth int) bool {
+	if b.Kind() != reflect.Slice && b.Kind() != reflect.Array {
+		return false
+	}
+	if a.Len() != b.Len() {
+		return false
+	}
+	for i := range a.Len() {
+		if !equalValue(a.Index(i), b.Index(i), depth+1) {
+			return false
+		}
+	}
+	return true
+}
+
+func equalMap(a, b reflect.Value, depth int) bool {
+	if b.Kind() == reflect.Struct {
+		return equalStruct(b, a, depth)
+	}
+	if b.Kind() != reflect.Map || a.Len() != b.Len() {
+		return false
+	}
+	if a.Type().Key() == b.Type().Key() {
+		for _, k := range a.MapKeys() {
+			bv := b.MapIndex(k)
+			if !bv.IsValid() || !equalValue(a.MapIndex(k), bv, depth+1) {
+				return false
+			}
+		}
+		return true
+	}
+
+	// Different key types can only match when both are string-like.
+	am, aok := stringKeyed(a)
+	bm, bok := stringKeyed(b)
+	if !aok || !bok {
+		return false
+	}
+	for k, av := range am {
+		bv, ok := bm[k]
+		if !ok || !equalValue(av, bv, depth+1) {
+			return false
+		}
+	}
+	return true
+}
+
+func equalStruct(a, b reflect.Value, depth int) bool {
+	if a.Type() != b.Type() {
+		// Compare a struct with its map form, e.g. after a JSON round trip.
+		am, aok := fieldsOf(a)
+		bm, bok := fieldsOf(b)
+		return aok && bok && equalValue(reflect.ValueOf(am), reflect.ValueOf(bm), depth+1)
+	}
+	for i := range a.NumField() {
+		if !equalValue(a.Field(i), b.Field(i), depth+1) {
+			return false
+		}
+	}
+	return true
+}
+
+// stringKeyed returns the entries of a map whose keys are strings.
+func stringKeyed(m reflect.Value) (map[string]reflect.Value, bool) {
+	if m.Type().Key().Kind() != reflect.String {
+		return nil, false
+	}
+	result := make(map[string]reflect.Value, m.Len())
+	iter := m.MapRange()
+	for iter.Next() {
+		result[iter.Key().String()] = iter.Value()
+	}
+	return result, true
+}
+
+// fieldsOf returns the named sub-values of a map with string keys or of a
+// struct, keyed like encoding/json would. time.Time is treated as a scalar.
+func fieldsOf(v reflect.Value) (map[string]any, bool) {
+	v = indirect(v)
+	if !v.IsValid() || !v.CanInterface() {
+		return nil, false
+	}
+
+	switch {
+	case v.Kind() == reflect.Map:
+		entries, ok := stringKeyed(v)
+		if !ok {
+			return nil, false
+		}
+		result := make(map[string]any, len(entries))
+		for k, e := range entries {
+			result[k] = e.Interface()
+		}
+		return result, true
+	case v.Kind() == reflect.Struct && v.Type() != timeType:
+		result := make(map[string]any, v.NumField())
+		for i := range v.NumField() {
+			f := v.Type().Field(i)
+			name, ok := jsonName(f)
+			if !ok {
+				continue
+			}
+			result[name] = v.Field(i).Interface()
+		}
+		return result, len(result) > 0
+	default:
+		return nil, false
+	}
+}
+
+// jsonName returns the key encoding/json uses for an exported struct field.
+func jsonName(f reflect.StructField) (string, bool) {
+	if !f.IsExported() {
+		return "", false
+	}
+	tag := f.Tag.Get("json")
+	if tag == "-" {
+		return "", false
+	}
+	if name, _, _ := strings.Cut(tag, ","); name != "" {
+		return name, true
+	}
+	return f.Name, true
+}
+
+// diffValue returns the changes between old and current stored under path.
+// When both values are maps or structs, it recurses and reports the changed
+// sub-paths (for example "address.city") instead of the whole value.
+func diffValue(path string, old, current any, equal Comparator, depth int) []ChangeField {
+	if equal(old, current) {
+		return nil
+	}
+
+	oldFields, oldOK := fieldsOf(reflect.ValueOf(old))
+	newFields, newOK := fieldsOf(reflect.ValueOf(current))
+	if !oldOK || !newOK || depth >= maxCompareDepth {
+		return []ChangeField{{Field: path, From: old, To: current}}
+	}
+
+	keys := make([]string, 0, len(oldFields)+len(newFields))
+	for k := range oldFields {
+		keys = append(keys, k)
+	}
+	for k := range newFields {
+		if _, ok := oldFields[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	slices.Sort(keys)
+
+	var changes []ChangeField
+	for _, k := range keys {
+		changes = append(changes, diffValue(path+"."+k, oldFields[k], newFields[k], equal, depth+1)...)
+	}
+	return changes
+}
@@ -0,0 +1,127 @@
+package audit_test
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/w0rng/audit"
+	"github.com/w0rng/audit/internal/be"
+)
+
+func TestEqual(t *testing.T) {
+	t.Parallel()
+	now := time.Now()
+	five := 5
+	alsoFive := 5
+	type named string
+
+	tests := []struct {
+		name string
+		a, b any
+		want bool
+	}{
+		{"nil", nil, nil, true},
+		{"nil and value", nil, 0, false},
+		{"nil pointer and nil", (*int)(nil), nil, true},
+		{"int and int64", 5, int64(5), true},
+		{"int and float64", 5, float64(5), true},
+		{"uint and json number", uint8(5), json.Number("5"), true},
+		{"different numbers", 5, 6, false},
+		{"NaN", math.NaN(), math.NaN(), false},
+		{"number and string", 5, "5", false},
+		{"json number and string", json.Number("5"), "5", true},
+		{"json number and other string", json.Number("5"), "5.0", false},
+		{"invalid json number and string", json.Number("n/a"), "n/a", true},
+		{"invalid json number and number", json.Number("n/a"), 5, false},
+		{"named string", named("a"), "a", true},
+		{"time in other zone", now, now.UTC(), true},
+		{"different times", now, now.Add(time.Second), false},
+		{"pointers to equal values", &five, &alsoFive, true},
+		{"equal slices", []string{"a", "b"}, []string{"a", "b"}, true},
+		{"slice and any slice", []string{"a"}, []any{"a"}, true},
+		{"different slices", []string{"a"}, []string{"a", "b"}, false},
+		{"equal maps", map[string]int{"a": 1}, map[string]any{"a": float64(1)}, true},
+		{"different maps", map[string]int{"a": 1}, map[string]int{"b": 1}, false},
+		{"struct and its map form", struct {
+			City string `json:"city"`
+		}{"Paris"}, map[string]any{"city": "Paris"}, true},
+		{"slice and map", []string{}, map[string]string{}, false},
+		{"complex", complex(1, 2), complex64(complex(1, 2)), true},
+		{"unexported fields", struct{ at time.Time }{now}, struct{ at time.Time }{now}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			be.Equal(t, audit.Equal(tt.a, tt.b), tt.want)
+			be.Equal(t, audit.Equal(tt.b, tt.a), tt.want)
+		})
+	}
+}
+
+func TestLogger_Logs_UncomparableValues(t *testing.T) {
+	t.Parallel()
+	logger := audit.New()
+
+	logger.Create("doc:1", "alice", "Created", map[string]audit.Value{
+		"tags": audit.PlainValue([]string{"a"}),
+		"meta": audit.PlainValue(map[string]any{"k": []int{1}}),
+	})
+	logger.Update("doc:1", "bob", "Retagged", map[string]audit.Value{
+		"tags": audit.PlainValue([]string{"a"}),
+		"meta": audit.PlainValue(map[string]any{"k": []int{1, 2}}),
+	})
+
+	changes := logger.Logs("doc:1")
+	be.Equal(t, len(changes), 2)
+	be.Equal(t, changes[1].Fields, []audit.ChangeField{
+		{Field: "meta.k", From: []int{1}, To: []int{1, 2}},
+	})
+}
+
+func TestLogger_Logs_NumericNormalization(t *testing.T) {
+	t.Parallel()
+	logger := audit.New()
+
+	logger.Create("item:1", "alice", "Created", map[string]audit.Value{"qty": audit.PlainValue(5)})
+	logger.Update("item:1", "alice", "Touched", map[string]audit.Value{"qty": audit.PlainValue(int64(5))})
+
+	be.Equal(t, len(logger.Logs("item:1")[1].Fields), 0)
+}
+
+func TestLogger_Logs_NestedPaths(t *testing.T) {
+	t.Parallel()
+	type address struct {
+		City   string `json:"city"`
+		Street string `json:"street"`
+	}
+	logger := audit.New()
+
+	logger.Create("user:1", "alice", "Created", map[string]audit.Value{
+		"address": audit.PlainValue(address{City: "Paris", Street: "Rue A"}),
+	})
+	logger.Update("user:1", "alice", "Moved", map[string]audit.Value{
+		"address": audit.PlainValue(&address{City: "Lyon", Street: "Rue A"}),
+	})
+
+	changes := logger.Logs("user:1")
+	be.Equal(t, len(changes[0].Fields), 1)
+	be.Equal(t, changes[0].Fields[0].Field, "address")
+	be.Equal(t, changes[1].Fields, []audit.ChangeField{
+		{Field: "address.city", From: "Paris", To: "Lyon"},
+	})
+}
+
+func TestLogger_WithComparator(t *testing.T) {
+	t.Parallel()
+	logger := audit.New(audit.WithComparator(func(a, b any) bool { return true }))
+
+	logger.Create("item:1", "alice", "Created", map[string]audit.Value{"name": audit.PlainValue("a")})
+	logger.Update("item:1", "alice", "Renamed", map[string]audit.Value{"name": audit.PlainValue("b")})
+
+	for _, c := range logger.Logs("item:1") {
+		be.Equal(t, len(c.Fields), 0)
+	}
+}
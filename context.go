@@ -0,0 +1,73 @@
+package audit
+
+import "context"
+
+// Actor identifies who performed an audited action.
+type Actor struct {
+	ID        string `json:"id,omitempty"`
+	Name      string `json:"name,omitempty"`
+	Type      string `json:"type,omitempty"` // e.g. "user", "service", "system"
+	IP        string `json:"ip,omitempty"`
+	UserAgent string `json:"user_agent,omitempty"`
+}
+
+type (
+	actorKey          struct{}
+	requestIDKey      struct{}
+	idempotencyKeyKey struct{}
+)
+
+// WithActor returns a copy of ctx carrying actor. Events logged with the
+// returned context record the actor, and use its ID as the author when the
+// author argument is empty.
+func WithActor(ctx context.Context, actor Actor) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// ActorFrom returns the actor stored in ctx by WithActor.
+func ActorFrom(ctx context.Context) (Actor, bool) {
+	actor, ok := ctx.Value(actorKey{}).(Actor)
+	return actor, ok
+}
+
+// WithRequestID returns a copy of ctx carrying the ID of the request being served.
+// Events logged with the returned context record it.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestIDFrom returns the request ID stored in ctx by WithRequestID.
+func RequestIDFrom(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(requestIDKey{}).(string)
+	return id, ok
+}
+
+// WithIdempotencyKey returns a copy of ctx carrying the idempotency key of the
+// write being performed, such as the ID of the message being consumed. Events
+// logged with the returned context record it, and an event whose key already
+// has an event with the same idempotency key is skipped. See Deduplicator.
+func WithIdempotencyKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, idempotencyKeyKey{}, key)
+}
+
+// IdempotencyKeyFrom returns the idempotency key stored in ctx by WithIdempotencyKey.
+func IdempotencyKeyFrom(ctx context.Context) (string, bool) {
+	key, ok := ctx.Value(idempotencyKeyKey{}).(string)
+	return key, ok
+}
+
+// applyContext fills the event metadata carried by ctx.
+func applyContext(ctx context.Context, event *Event) {
+	if actor, ok := ActorFrom(ctx); ok {
+		event.Actor = &actor
+		if event.Author == "" {
+			event.Author = actor.ID
+		}
+	}
+	if id, ok := RequestIDFrom(ctx); ok {
+		event.RequestID = id
+	}
+	if key, ok := IdempotencyKeyFrom(ctx); ok && event.IdempotencyKey == "" {
+		event.IdempotencyKey = key
+	}
+}
@@ -0,0 +1,67 @@
+package audit_test
+
+import (
+	"testing"
+
+	"github.com/w0rng/audit"
+	"github.com/w0rng/audit/internal/be"
+)
+
+func TestActorAndRequestIDFromContext(t *testing.T) {
+	t.Parallel()
+	_, ok := audit.ActorFrom(t.Context())
+	be.Equal(t, ok, false)
+	_, ok = audit.RequestIDFrom(t.Context())
+	be.Equal(t, ok, false)
+
+	actor := audit.Actor{ID: "u-1", Name: "Alice", Type: "user", IP: "10.0.0.1", UserAgent: "curl/8"}
+	ctx := audit.WithRequestID(audit.WithActor(t.Context(), actor), "req-1")
+
+	got, ok := audit.ActorFrom(ctx)
+	be.True(t, ok)
+	be.Equal(t, got, actor)
+	id, ok := audit.RequestIDFrom(ctx)
+	be.True(t, ok)
+	be.Equal(t, id, "req-1")
+}
+
+func TestLogger_ContextMetadata(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name       string
+		author     string
+		wantAuthor string
+	}{
+		{"author from actor", "", "u-1"},
+		{"explicit author wins", "admin", "admin"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			logger := audit.New()
+			actor := audit.Actor{ID: "u-1", Name: "Alice"}
+			ctx := audit.WithRequestID(audit.WithActor(t.Context(), actor), "req-1")
+
+			be.Err(t, logger.CreateContext(ctx, "user:1", tt.author, "Created", map[string]audit.Value{}), nil)
+
+			events := logger.Events("user:1")
+			be.Equal(t, len(events), 1)
+			be.Equal(t, events[0].Author, tt.wantAuthor)
+			be.Equal(t, *events[0].Actor, actor)
+			be.Equal(t, events[0].RequestID, "req-1")
+			be.Err(t, logger.VerifyContext(ctx, "user:1"), nil)
+		})
+	}
+}
+
+func TestLogger_ContextMetadata_Absent(t *testing.T) {
+	t.Parallel()
+	logger := audit.New()
+
+	logger.Create("user:1", "admin", "Created", map[string]audit.Value{})
+
+	events := logger.Events("user:1")
+	be.Equal(t, events[0].Actor, nil)
+	be.Equal(t, events[0].RequestID, "")
+}
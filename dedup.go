@@ -0,0 +1,156 @@
+package audit
+
+import "context"
+
+// DefaultIdempotencyWindow is the number of idempotency keys remembered by
+// InMemoryStorage and FileStorage unless configured otherwise.
+const DefaultIdempotencyWindow = 10000
+
+// Deduplicator is an optional interface for storages that remember the
+// idempotency keys of the events they store. Before writing an event with an
+// idempotency key, the Logger asks the storage whether an event with the same
+// key and idempotency key was already stored, and skips it if so.
+type Deduplicator interface {
+	// HasIdempotencyKey reports whether an event with idempotencyKey was stored
+	// under key. Storages may forget old idempotency keys, for example beyond a
+	// bounded window.
+	HasIdempotencyKey(ctx context.Context, key, idempotencyKey string) (bool, error)
+}
+
+// InMemoryOption configures an InMemoryStorage.
+type InMemoryOption func(*InMemoryStorage)
+
+// WithIdempotencyWindow sets how many idempotency keys an InMemoryStorage
+// remembers. Older keys are forgotten first. A size of zero or less disables
+// deduplication. Defaults to DefaultIdempotencyWindow.
+func WithIdempotencyWindow(size int) InMemoryOption {
+	return func(s *InMemoryStorage) {
+		s.dedup = newDedupWindow(size)
+	}
+}
+
+// HasIdempotencyKey reports whether an event with idempotencyKey is stored
+// under key and still within the idempotency window. It implements Deduplicator.
+func (s *InMemoryStorage) HasIdempotencyKey(ctx context.Context, key, idempotencyKey string) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.dedup.has(key, idempotencyKey), nil
+}
+
+// HasIdempotencyKey reports whether an event with idempotencyKey is stored
+// under key and still within the idempotency window. It implements Deduplicator.
+func (s *FileStorage) HasIdempotencyKey(ctx context.Context, key, idempotencyKey string) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.closed {
+		return false, ErrStorageClosed
+	}
+	return s.dedup.has(key, idempotencyKey), nil
+}
+
+// duplicate reports whether an event with idempotencyKey is already stored
+// under key. Without an idempotency key or a Deduplicator, nothing is a duplicate.
+func (l *Logger) duplicate(ctx context.Context, key, idempotencyKey string) (bool, error) {
+	if idempotencyKey == "" {
+		return false, nil
+	}
+	dedup, ok := capability[Deduplicator](l.storage)
+	if !ok {
+		return false, nil
+	}
+	return dedup.HasIdempotencyKey(ctx, key, idempotencyKey)
+}
+
+// withoutDuplicates returns the events that are not duplicates of stored
+// events. Events of the batch are not compared with each other, so a retried
+// batch is skipped as a whole while the first attempt keeps all its events.
+func (l *Logger) withoutDuplicates(ctx context.Context, events []KeyedEvent) ([]KeyedEvent, error) {
+	kept := make([]KeyedEvent, 0, len(events))
+	for _, e := range events {
+		dup, err := l.duplicate(ctx, e.Key, e.IdempotencyKey)
+		if err != nil {
+			return nil, err
+		}
+		if !dup {
+			kept = append(kept, e)
+		}
+	}
+	return kept, nil
+}
+
+// dedupKey identifies an idempotent write to an entity.
+type dedupKey struct {
+	key            string
+	idempotencyKey string
+}
+
+// dedupWindow remembers the most recent idempotency keys, up to a fixed
+// number. It is not safe for concurrent use; storages guard it with their lock.
+// A nil window remembers nothing.
+type dedupWindow struct {
+	seen  map[dedupKey]int // position of each remembered key in ring
+	ring  []dedupKey
+	next  int
+	limit int
+}
+
+func newDedupWindow(size int) *dedupWindow {
+	if size <= 0 {
+		return nil
+	}
+	return &dedupWindow{seen: make(map[dedupKey]int), limit: size}
+}
+
+func (w *dedupWindow) has(key, idempotencyKey string) bool {
+	if w == nil {
+		return false
+	}
+	_, ok := w.seen[dedupKey{key, idempotencyKey}]
+	return ok
+}
+
+// add remembers the idempotency key of event, evicting the oldest key when
+// the window is full.
+func (w *dedupWindow) add(key string, event Event) {
+	if w == nil || event.IdempotencyKey == "" {
+		return
+	}
+	k := dedupKey{key, event.IdempotencyKey}
+	if _, ok := w.seen[k]; ok {
+		return
+	}
+
+	pos := len(w.ring)
+	if pos < w.limit {
+		w.ring = append(w.ring, k)
+	} else {
+		pos = w.next
+		old := w.ring[pos]
+		// A forgotten key may have been remembered again at another position.
+		if p, ok := w.seen[old]; ok && p == pos {
+			delete(w.seen, old)
+		}
+		w.ring[pos] = k
+		w.next = (pos + 1) % w.limit
+	}
+	w.seen[k] = pos
+}
+
+// forget drops the idempotency keys remembered for key, so events logged
+// after Clear are not mistaken for retries.
+func (w *dedupWindow) forget(key string) {
+	if w == nil {
+		return
+	}
+	for k := range w.seen {
+		if k.key == key {
+			delete(w.seen, k)
+		}
+	}
+}
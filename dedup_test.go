@@ -0,0 +1,142 @@
+package audit_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/w0rng/audit"
+	"github.com/w0rng/audit/internal/be"
+)
+
+func TestLogger_IdempotencyKey(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name    string
+		storage func(t *testing.T) audit.StorageV2
+	}{
+		{"memory", func(*testing.T) audit.StorageV2 { return audit.AdaptStorage(audit.NewInMemoryStorage()) }},
+		{"file", func(t *testing.T) audit.StorageV2 {
+			return openFileStorage(t, filepath.Join(t.TempDir(), "audit.jsonl"), audit.FileStorageOptions{})
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			logger := audit.New(audit.WithStorageV2(tt.storage(t)))
+			ctx := audit.WithIdempotencyKey(t.Context(), "msg-1")
+			key, ok := audit.IdempotencyKeyFrom(ctx)
+			be.True(t, ok)
+			be.Equal(t, key, "msg-1")
+
+			payload := map[string]audit.Value{"status": audit.PlainValue("paid")}
+			for range 3 {
+				be.Err(t, logger.UpdateContext(ctx, "order:1", "alice", "Paid", payload), nil)
+			}
+			be.Err(t, logger.UpdateContext(ctx, "order:2", "alice", "Paid", payload), nil)
+			be.Err(t, logger.UpdateContext(audit.WithIdempotencyKey(ctx, "msg-2"), "order:1", "alice", "Paid", payload), nil)
+			be.Err(t, logger.UpdateContext(t.Context(), "order:1", "alice", "Paid", payload), nil)
+			be.Err(t, logger.UpdateContext(t.Context(), "order:1", "alice", "Paid", payload), nil)
+
+			events := logger.Events("order:1")
+			be.Equal(t, len(events), 4)
+			be.Equal(t, events[0].IdempotencyKey, "msg-1")
+			be.Equal(t, events[1].IdempotencyKey, "msg-2")
+			be.Equal(t, events[2].IdempotencyKey, "")
+			be.Equal(t, len(logger.Events("order:2")), 1)
+			be.Err(t, logger.Verify("order:1"), nil)
+		})
+	}
+}
+
+func TestLogger_IdempotencyKey_Batches(t *testing.T) {
+	t.Parallel()
+	logger := audit.New()
+	ctx := audit.WithIdempotencyKey(t.Context(), "msg-1")
+
+	// A retried transaction is skipped as a whole, but its first attempt keeps
+	// every event, even several for the same key.
+	for range 2 {
+		tx := logger.Begin(ctx)
+		tx.Update("order:1", "alice", "Paid", map[string]audit.Value{})
+		tx.Update("order:1", "alice", "Shipped", map[string]audit.Value{})
+		tx.Create("payment:1", "alice", "Captured", map[string]audit.Value{})
+		be.Err(t, tx.Commit(), nil)
+	}
+	be.Equal(t, len(logger.Events("order:1")), 2)
+	be.Equal(t, len(logger.Events("payment:1")), 1)
+
+	// LogEntry.IdempotencyKey overrides the context.
+	entries := []audit.LogEntry{
+		{Key: "order:1", Action: audit.ActionUpdate, Author: "bob", IdempotencyKey: "msg-2"},
+		{Key: "order:1", Action: audit.ActionUpdate, Author: "bob"},
+	}
+	be.Err(t, logger.LogBatch(ctx, entries), nil)
+	be.Err(t, logger.LogBatch(ctx, entries), nil)
+	events := logger.Events("order:1")
+	be.Equal(t, len(events), 3)
+	be.Equal(t, events[2].IdempotencyKey, "msg-2")
+	be.Err(t, logger.Verify("order:1"), nil)
+}
+
+func TestInMemoryStorage_IdempotencyWindow(t *testing.T) {
+	t.Parallel()
+	storage := audit.NewInMemoryStorage(audit.WithIdempotencyWindow(2))
+	logger := audit.New(audit.WithStorage(storage))
+	log := func(key, idempotencyKey string) {
+		ctx := audit.WithIdempotencyKey(t.Context(), idempotencyKey)
+		be.Err(t, logger.UpdateContext(ctx, key, "alice", "updated", map[string]audit.Value{}), nil)
+	}
+
+	log("order:1", "a")
+	log("order:1", "b")
+	log("order:1", "a")
+	be.Equal(t, len(storage.Get("order:1")), 2)
+
+	// "a" falls out of the window once two newer keys are stored.
+	log("order:1", "c")
+	log("order:1", "a")
+	be.Equal(t, len(storage.Get("order:1")), 4)
+	has, err := storage.HasIdempotencyKey(t.Context(), "order:1", "b")
+	be.Err(t, err, nil)
+	be.True(t, !has)
+
+	// Clear forgets the key's idempotency keys.
+	storage.Clear("order:1")
+	log("order:1", "a")
+	be.Equal(t, len(storage.Get("order:1")), 1)
+
+	disabled := audit.New(audit.WithStorage(audit.NewInMemoryStorage(audit.WithIdempotencyWindow(0))))
+	ctx := audit.WithIdempotencyKey(t.Context(), "a")
+	for range 2 {
+		be.Err(t, disabled.UpdateContext(ctx, "order:1", "alice", "updated", map[string]audit.Value{}), nil)
+	}
+	be.Equal(t, len(disabled.Events("order:1")), 2)
+}
+
+func TestFileStorage_IdempotencyKeysSurviveReopen(t *testing.T) {
+	t.Parallel()
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	ctx := audit.WithIdempotencyKey(t.Context(), "msg-1")
+
+	storage, err := audit.OpenFileStorage(path, audit.FileStorageOptions{})
+	be.Err(t, err, nil)
+	logger := audit.New(audit.WithStorageV2(storage))
+	be.Err(t, logger.UpdateContext(ctx, "order:1", "alice", "Paid", map[string]audit.Value{}), nil)
+	be.Err(t, storage.Close(), nil)
+
+	storage = openFileStorage(t, path, audit.FileStorageOptions{})
+	logger = audit.New(audit.WithStorageV2(storage))
+	be.Err(t, logger.UpdateContext(ctx, "order:1", "alice", "Paid", map[string]audit.Value{}), nil)
+	be.Equal(t, len(logger.Events("order:1")), 1)
+
+	storage = openFileStorage(t, path, audit.FileStorageOptions{IdempotencyWindow: -1})
+	has, err := storage.HasIdempotencyKey(t.Context(), "order:1", "msg-1")
+	be.Err(t, err, nil)
+	be.True(t, !has)
+}
+
+func TestDeduplicatorInterface(t *testing.T) {
+	var _ audit.Deduplicator = (*audit.InMemoryStorage)(nil)
+	var _ audit.Deduplicator = (*audit.FileStorage)(nil)
+}
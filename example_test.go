@@ -210,3 +210,19 @@ func Example() {
 	// Total changes: 2
 	// Last change: Order approved
 }
+
+func ExampleLogger_CurrentState() {
+	logger := audit.New()
+
+	logger.Create("order:1", "user", "Order created", map[string]audit.Value{
+		"status": audit.PlainValue("pending"),
+		"total":  audit.PlainValue(100),
+	})
+	logger.Update("order:1", "admin", "Order paid", map[string]audit.Value{
+		"status": audit.PlainValue("paid"),
+	})
+
+	state := logger.CurrentState("order:1")
+	fmt.Printf("status=%v total=%v\n", state["status"].Data, state["total"].Data)
+	// Output: status=paid total=100
+}
@@ -1,7 +1,9 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"sync"
@@ -10,8 +12,9 @@ import (
 )
 
 // JSONFileStorage is a custom storage implementation that persists events to a JSON file.
-// This is a simple example - production implementations should handle errors and
-// implement proper file locking.
+// It implements audit.StorageV2, so write failures are reported to the caller.
+// This is a simple example - production implementations should implement proper
+// file locking and avoid rewriting the whole file on every Store.
 type JSONFileStorage struct {
 	mu       sync.RWMutex
 	filepath string
@@ -19,70 +22,81 @@ type JSONFileStorage struct {
 }
 
 // NewJSONFileStorage creates a new JSON file-based storage.
-func NewJSONFileStorage(filepath string) *JSONFileStorage {
+func NewJSONFileStorage(filepath string) (*JSONFileStorage, error) {
 	storage := &JSONFileStorage{
 		filepath: filepath,
 		events:   make(map[string][]audit.Event),
 	}
-	storage.load()
-	return storage
+	if err := storage.load(); err != nil {
+		return nil, err
+	}
+	return storage, nil
 }
 
 // Store appends an event and persists to file.
-func (s *JSONFileStorage) Store(key string, event audit.Event) {
+func (s *JSONFileStorage) Store(ctx context.Context, key string, event audit.Event) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	s.events[key] = append(s.events[key], event)
-	s.save()
+	return s.save()
 }
 
 // Get retrieves all events for a key.
-func (s *JSONFileStorage) Get(key string) []audit.Event {
+func (s *JSONFileStorage) Get(_ context.Context, key string) ([]audit.Event, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 	events := s.events[key]
 	if events == nil {
-		return []audit.Event{}
+		return []audit.Event{}, nil
 	}
-	return events
+	return events, nil
 }
 
 // Has checks if events exist for a key.
-func (s *JSONFileStorage) Has(key string) bool {
+func (s *JSONFileStorage) Has(_ context.Context, key string) (bool, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 	_, ok := s.events[key]
-	return ok
+	return ok, nil
 }
 
 // Clear removes all events for a key and persists.
-func (s *JSONFileStorage) Clear(key string) {
+func (s *JSONFileStorage) Clear(_ context.Context, key string) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	delete(s.events, key)
-	s.save()
+	return s.save()
 }
 
 // load reads events from the JSON file.
-func (s *JSONFileStorage) load() {
+func (s *JSONFileStorage) load() error {
 	data, err := os.ReadFile(s.filepath)
-	if err != nil {
+	if errors.Is(err, os.ErrNotExist) {
 		// File doesn't exist yet, start with empty events
-		return
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("read %s: %w", s.filepath, err)
 	}
-	json.Unmarshal(data, &s.events)
+	if err := json.Unmarshal(data, &s.events); err != nil {
+		return fmt.Errorf("decode %s: %w", s.filepath, err)
+	}
+	return nil
 }
 
 // save writes events to the JSON file.
-func (s *JSONFileStorage) save() {
+func (s *JSONFileStorage) save() error {
 	data, err := json.MarshalIndent(s.events, "", "  ")
 	if err != nil {
-		fmt.Printf("Error marshaling events: %v\n", err)
-		return
+		return fmt.Errorf("marshal events: %w", err)
 	}
 	if err := os.WriteFile(s.filepath, data, 0644); err != nil {
-		fmt.Printf("Error writing file: %v\n", err)
+		return fmt.Errorf("write %s: %w", s.filepath, err)
 	}
+	return nil
 }
 
 func main() {
@@ -92,16 +106,26 @@ func main() {
 
 	// Create logger with custom JSON file storage using options pattern
 	filepath := "audit_events.json"
-	storage := NewJSONFileStorage(filepath)
-	logger := audit.New(audit.WithStorage(storage))
+	storage, err := NewJSONFileStorage(filepath)
+	if err != nil {
+		fmt.Printf("Error opening storage: %v\n", err)
+		os.Exit(1)
+	}
+	logger := audit.New(audit.WithStorageV2(storage))
 
 	fmt.Printf("Using JSON file storage: %s\n\n", filepath)
 
 	// Create some audit events
-	logger.Create("user:1", "admin", "User account created", map[string]audit.Value{
+	// The *Context variants report storage failures instead of dropping them
+	ctx := context.Background()
+	err = logger.CreateContext(ctx, "user:1", "admin", "User account created", map[string]audit.Value{
 		"email": audit.PlainValue("alice@example.com"),
 		"role":  audit.PlainValue("editor"),
 	})
+	if err != nil {
+		fmt.Printf("Error logging event: %v\n", err)
+		os.Exit(1)
+	}
 
 	logger.Update("user:1", "admin", "Role updated", map[string]audit.Value{
 		"role": audit.PlainValue("admin"),
@@ -0,0 +1,85 @@
+package export
+
+import (
+	"io"
+
+	"github.com/w0rng/audit"
+)
+
+// changeHeader lists the CSV columns written by ChangeWriter.
+func changeHeader() []string {
+	return []string{"key", "timestamp", "author", "description", "field", "from", "to"}
+}
+
+// changeRecord is the JSON form of a change.
+type changeRecord struct {
+	Key         string              `json:"key,omitempty"`
+	Timestamp   string              `json:"timestamp"`
+	Author      string              `json:"author"`
+	Description string              `json:"description"`
+	Fields      []audit.ChangeField `json:"fields"`
+}
+
+// ChangeWriter writes the changes returned by Logger.Logs one at a time.
+type ChangeWriter struct {
+	w *writer
+}
+
+// NewChangeWriter returns a ChangeWriter encoding to w in format.
+// It returns ErrUnknownFormat for an unsupported format.
+func NewChangeWriter(w io.Writer, format Format, opts Options) (*ChangeWriter, error) {
+	out, err := newWriter(w, format, opts, changeHeader())
+	if err != nil {
+		return nil, err
+	}
+	return &ChangeWriter{w: out}, nil
+}
+
+// Write encodes change of the entity stored under key. In CSV it produces
+// one row per ChangeField, or a single row for a change without fields.
+func (cw *ChangeWriter) Write(key string, change audit.Change) error {
+	timestamp := cw.w.opts.formatTime(change.Timestamp)
+	if cw.w.format != CSV {
+		fields := change.Fields
+		if fields == nil {
+			fields = []audit.ChangeField{}
+		}
+		return cw.w.write(nil, changeRecord{
+			Key:         key,
+			Timestamp:   timestamp,
+			Author:      change.Author,
+			Description: change.Description,
+			Fields:      fields,
+		})
+	}
+
+	if len(change.Fields) == 0 {
+		return cw.w.write([][]string{{key, timestamp, change.Author, change.Description, "", "", ""}}, nil)
+	}
+	rows := make([][]string, 0, len(change.Fields))
+	for _, f := range change.Fields {
+		rows = append(rows, []string{
+			key, timestamp, change.Author, change.Description, f.Field, formatValue(f.From), formatValue(f.To),
+		})
+	}
+	return cw.w.write(rows, nil)
+}
+
+// Close finishes the output; it must be called once all changes are written.
+func (cw *ChangeWriter) Close() error {
+	return cw.w.close()
+}
+
+// WriteChanges writes the changes of key to w in format.
+func WriteChanges(w io.Writer, format Format, key string, changes []audit.Change, opts Options) error {
+	cw, err := NewChangeWriter(w, format, opts)
+	if err != nil {
+		return err
+	}
+	for _, c := range changes {
+		if err := cw.Write(key, c); err != nil {
+			return err
+		}
+	}
+	return cw.Close()
+}
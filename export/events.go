@@ -0,0 +1,117 @@
+package export
+
+import (
+	"io"
+	"maps"
+	"slices"
+	"strconv"
+
+	"github.com/w0rng/audit"
+)
+
+// eventHeader lists the CSV columns written by EventWriter.
+func eventHeader() []string {
+	return []string{
+		"key", "id", "sequence", "timestamp", "action", "author", "actor",
+		"request_id", "tx_id", "idempotency_key", "description", "field", "value",
+	}
+}
+
+// eventRecord is the JSON form of an event.
+type eventRecord struct {
+	Key            string         `json:"key,omitempty"`
+	ID             string         `json:"id,omitempty"`
+	Sequence       uint64         `json:"sequence,omitempty"`
+	Timestamp      string         `json:"timestamp"`
+	Action         audit.Action   `json:"action"`
+	Author         string         `json:"author"`
+	Actor          *audit.Actor   `json:"actor,omitempty"`
+	RequestID      string         `json:"request_id,omitempty"`
+	TxID           string         `json:"tx_id,omitempty"`
+	IdempotencyKey string         `json:"idempotency_key,omitempty"`
+	Description    string         `json:"description"`
+	Payload        map[string]any `json:"payload"`
+	Hash           string         `json:"hash,omitempty"`
+}
+
+// EventWriter writes events one at a time, so exports of any size can be streamed.
+type EventWriter struct {
+	w *writer
+}
+
+// NewEventWriter returns an EventWriter encoding to w in format.
+// It returns ErrUnknownFormat for an unsupported format.
+func NewEventWriter(w io.Writer, format Format, opts Options) (*EventWriter, error) {
+	out, err := newWriter(w, format, opts, eventHeader())
+	if err != nil {
+		return nil, err
+	}
+	return &EventWriter{w: out}, nil
+}
+
+// Write encodes event, stored under key. In CSV it produces one row per
+// payload field, in sorted order, or a single row for an empty payload.
+func (ew *EventWriter) Write(key string, event audit.Event) error {
+	payload := make(map[string]any, len(event.Payload))
+	for field, v := range event.Payload {
+		if v.Hidden {
+			payload[field] = audit.HideText
+		} else {
+			payload[field] = v.Data
+		}
+	}
+
+	if ew.w.format != CSV {
+		return ew.w.write(nil, eventRecord{
+			Key:            key,
+			ID:             event.ID,
+			Sequence:       event.Sequence,
+			Timestamp:      ew.w.opts.formatTime(event.Timestamp),
+			Action:         event.Action,
+			Author:         event.Author,
+			Actor:          event.Actor,
+			RequestID:      event.RequestID,
+			TxID:           event.TxID,
+			IdempotencyKey: event.IdempotencyKey,
+			Description:    event.Description,
+			Payload:        payload,
+			Hash:           event.Hash,
+		})
+	}
+
+	actor := ""
+	if event.Actor != nil {
+		actor = event.Actor.ID
+	}
+	prefix := []string{
+		key, event.ID, strconv.FormatUint(event.Sequence, 10), ew.w.opts.formatTime(event.Timestamp), string(event.Action),
+		event.Author, actor, event.RequestID, event.TxID, event.IdempotencyKey, event.Description,
+	}
+	if len(payload) == 0 {
+		return ew.w.write([][]string{append(prefix, "", "")}, nil)
+	}
+	rows := make([][]string, 0, len(payload))
+	for _, field := range slices.Sorted(maps.Keys(payload)) {
+		rows = append(rows, append(slices.Clone(prefix), field, formatValue(payload[field])))
+	}
+	return ew.w.write(rows, nil)
+}
+
+// Close finishes the output; it must be called once all events are written.
+func (ew *EventWriter) Close() error {
+	return ew.w.close()
+}
+
+// WriteEvents writes the events of key to w in format.
+func WriteEvents(w io.Writer, format Format, key string, events []audit.Event, opts Options) error {
+	ew, err := NewEventWriter(w, format, opts)
+	if err != nil {
+		return err
+	}
+	for _, e := range events {
+		if err := ew.Write(key, e); err != nil {
+			return err
+		}
+	}
+	return ew.Close()
+}
@@ -0,0 +1,164 @@
+// Package export serializes audit events and change histories for people
+// and tools outside Go: CSV for spreadsheets, pretty JSON and NDJSON streams.
+//
+// Column and field order is stable, so exports can be diffed and loaded into
+// fixed spreadsheet templates. Hidden values are always written as
+// audit.HideText, never as their data or digest.
+//
+// Example:
+//
+//	changes, err := logger.LogsContext(ctx, "order:123")
+//	if err != nil {
+//	    return err
+//	}
+//	err = export.WriteChanges(os.Stdout, export.CSV, "order:123", changes, export.Options{})
+package export
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// ErrUnknownFormat is returned for a Format other than CSV, JSON and NDJSON.
+var ErrUnknownFormat = errors.New("export: unknown format")
+
+// Format selects the output encoding.
+type Format string
+
+const (
+	// CSV writes a header row and one row per payload field or ChangeField.
+	CSV Format = "csv"
+
+	// JSON writes an indented JSON array of records.
+	JSON Format = "json"
+
+	// NDJSON writes one compact JSON record per line.
+	NDJSON Format = "ndjson"
+)
+
+// Options configures a writer.
+type Options struct {
+	// TimeFormat is the layout of timestamps. Defaults to time.RFC3339Nano.
+	TimeFormat string
+}
+
+func (o Options) formatTime(t time.Time) string {
+	if o.TimeFormat == "" {
+		return t.Format(time.RFC3339Nano)
+	}
+	return t.Format(o.TimeFormat)
+}
+
+// writer holds the encoding state shared by EventWriter and ChangeWriter.
+type writer struct {
+	w      io.Writer
+	format Format
+	opts   Options
+	header []string
+	csv    *csv.Writer
+	count  int
+	closed bool
+}
+
+func newWriter(w io.Writer, format Format, opts Options, header []string) (*writer, error) {
+	switch format {
+	case CSV, JSON, NDJSON:
+	default:
+		return nil, fmt.Errorf("%w %q", ErrUnknownFormat, format)
+	}
+	out := &writer{w: w, format: format, opts: opts, header: header}
+	if format == CSV {
+		out.csv = csv.NewWriter(w)
+	}
+	return out, nil
+}
+
+// write encodes one record: rows for CSV, rec for the JSON formats.
+func (w *writer) write(rows [][]string, rec any) error {
+	if w.closed {
+		return errors.New("export: write after Close")
+	}
+	first := w.count == 0
+	w.count++
+
+	switch w.format {
+	case CSV:
+		if first {
+			if err := w.csv.Write(w.header); err != nil {
+				return err
+			}
+		}
+		if err := w.csv.WriteAll(rows); err != nil {
+			return fmt.Errorf("export: write csv: %w", err)
+		}
+		return nil
+	case JSON:
+		data, err := json.MarshalIndent(rec, "  ", "  ")
+		if err != nil {
+			return fmt.Errorf("export: encode record: %w", err)
+		}
+		sep := ",\n  "
+		if first {
+			sep = "[\n  "
+		}
+		_, err = fmt.Fprintf(w.w, "%s%s", sep, data)
+		return err
+	default:
+		data, err := json.Marshal(rec)
+		if err != nil {
+			return fmt.Errorf("export: encode record: %w", err)
+		}
+		_, err = fmt.Fprintf(w.w, "%s\n", data)
+		return err
+	}
+}
+
+// close finishes the output. An empty CSV export still has its header and
+// an empty JSON export is an empty array.
+func (w *writer) close() error {
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+
+	switch w.format {
+	case CSV:
+		if w.count == 0 {
+			if err := w.csv.Write(w.header); err != nil {
+				return err
+			}
+		}
+		w.csv.Flush()
+		return w.csv.Error()
+	case JSON:
+		closing := "\n]\n"
+		if w.count == 0 {
+			closing = "[]\n"
+		}
+		_, err := io.WriteString(w.w, closing)
+		return err
+	default:
+		return nil
+	}
+}
+
+// formatValue renders a field value for a CSV cell: strings as is, nil as
+// an empty cell and everything else as JSON.
+func formatValue(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	default:
+		data, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Sprint(v)
+		}
+		return string(data)
+	}
+}
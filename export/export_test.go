@@ -0,0 +1,179 @@
+package export_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/w0rng/audit"
+	"github.com/w0rng/audit/export"
+	"github.com/w0rng/audit/internal/be"
+)
+
+func testEvents() []audit.Event {
+	base := time.Date(2025, 3, 1, 14, 0, 0, 0, time.UTC)
+	return []audit.Event{
+		{
+			ID: "01JNBY8Z00ABCDEFGHJKMNPQRS", Timestamp: base, Action: audit.ActionCreate, Author: "alice",
+			Description: "Order created", Sequence: 1,
+			Actor: &audit.Actor{ID: "u-1"}, RequestID: "req-1", IdempotencyKey: "msg-1",
+			Payload: map[string]audit.Value{
+				"total":  audit.PlainValue(100),
+				"status": audit.PlainValue("pending"),
+				"card":   {Hidden: true, Digest: "d1", Data: "4111"},
+			},
+		},
+		{Timestamp: base.Add(time.Hour), Action: audit.ActionDelete, Author: "bob", Description: "Order deleted", Sequence: 2},
+	}
+}
+
+func TestWriteEvents(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		format export.Format
+		want   string
+	}{
+		{export.CSV, `key,id,sequence,timestamp,action,author,actor,request_id,tx_id,idempotency_key,description,field,value
+order:1,01JNBY8Z00ABCDEFGHJKMNPQRS,1,2025-03-01 14:00,create,alice,u-1,req-1,,msg-1,Order created,card,***
+order:1,01JNBY8Z00ABCDEFGHJKMNPQRS,1,2025-03-01 14:00,create,alice,u-1,req-1,,msg-1,Order created,status,pending
+order:1,01JNBY8Z00ABCDEFGHJKMNPQRS,1,2025-03-01 14:00,create,alice,u-1,req-1,,msg-1,Order created,total,100
+order:1,,2,2025-03-01 15:00,delete,bob,,,,,Order deleted,,
+`},
+		{export.NDJSON, `{"key":"order:1","id":"01JNBY8Z00ABCDEFGHJKMNPQRS","sequence":1,` +
+			`"timestamp":"2025-03-01 14:00","action":"create","author":"alice",` +
+			`"actor":{"id":"u-1"},"request_id":"req-1","idempotency_key":"msg-1",` +
+			`"description":"Order created","payload":{"card":"***","status":"pending","total":100}}
+{"key":"order:1","sequence":2,"timestamp":"2025-03-01 15:00","action":"delete","author":"bob","description":"Order deleted","payload":{}}
+`},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.format), func(t *testing.T) {
+			t.Parallel()
+			var buf bytes.Buffer
+			err := export.WriteEvents(&buf, tt.format, "order:1", testEvents(), export.Options{TimeFormat: "2006-01-02 15:04"})
+			be.Err(t, err, nil)
+			be.Equal(t, buf.String(), tt.want)
+		})
+	}
+}
+
+func TestWriteEvents_JSON(t *testing.T) {
+	t.Parallel()
+	var buf bytes.Buffer
+	be.Err(t, export.WriteEvents(&buf, export.JSON, "order:1", testEvents(), export.Options{}), nil)
+	be.True(t, strings.HasPrefix(buf.String(), "[\n  {\n    \"key\": \"order:1\","))
+	be.True(t, !strings.Contains(buf.String(), "4111"))
+
+	var records []map[string]any
+	be.Err(t, json.Unmarshal(buf.Bytes(), &records), nil)
+	be.Equal(t, len(records), 2)
+	be.Equal(t, records[0]["timestamp"], any("2025-03-01T14:00:00Z"))
+	be.Equal(t, records[0]["payload"], any(map[string]any{"card": "***", "status": "pending", "total": float64(100)}))
+}
+
+func TestWriteChanges(t *testing.T) {
+	t.Parallel()
+	logger := audit.New()
+	for _, e := range testEvents() {
+		logger.LogChange("order:1", e.Action, e.Author, e.Description, e.Payload)
+	}
+	changes := logger.Logs("order:1")
+	for i := range changes {
+		changes[i].Timestamp = testEvents()[i].Timestamp
+	}
+
+	tests := []struct {
+		format export.Format
+		want   string
+	}{
+		{export.CSV, `key,timestamp,author,description,field,from,to
+order:1,2025-03-01T14:00:00Z,alice,Order created,card,***,***
+order:1,2025-03-01T14:00:00Z,alice,Order created,status,,pending
+order:1,2025-03-01T14:00:00Z,alice,Order created,total,,100
+order:1,2025-03-01T15:00:00Z,bob,Order deleted,,,
+`},
+		{export.NDJSON, `{"key":"order:1","timestamp":"2025-03-01T14:00:00Z","author":"alice","description":"Order created",` +
+			`"fields":[{"field":"card","from":"***","to":"***"},` +
+			`{"field":"status","from":null,"to":"pending"},{"field":"total","from":null,"to":100}]}
+{"key":"order:1","timestamp":"2025-03-01T15:00:00Z","author":"bob","description":"Order deleted","fields":[]}
+`},
+		{export.JSON, `[
+  {
+    "key": "order:1",
+    "timestamp": "2025-03-01T14:00:00Z",
+    "author": "alice",
+    "description": "Order created",
+    "fields": [
+      {
+        "field": "card",
+        "from": "***",
+        "to": "***"
+      },
+      {
+        "field": "status",
+        "from": null,
+        "to": "pending"
+      },
+      {
+        "field": "total",
+        "from": null,
+        "to": 100
+      }
+    ]
+  },
+  {
+    "key": "order:1",
+    "timestamp": "2025-03-01T15:00:00Z",
+    "author": "bob",
+    "description": "Order deleted",
+    "fields": []
+  }
+]
+`},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.format), func(t *testing.T) {
+			t.Parallel()
+			var buf bytes.Buffer
+			be.Err(t, export.WriteChanges(&buf, tt.format, "order:1", changes, export.Options{}), nil)
+			be.Equal(t, buf.String(), tt.want)
+		})
+	}
+}
+
+func TestWriter_Empty(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		format export.Format
+		want   string
+	}{
+		{export.CSV, "key,timestamp,author,description,field,from,to\n"},
+		{export.JSON, "[]\n"},
+		{export.NDJSON, ""},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.format), func(t *testing.T) {
+			t.Parallel()
+			var buf bytes.Buffer
+			be.Err(t, export.WriteChanges(&buf, tt.format, "k", nil, export.Options{}), nil)
+			be.Equal(t, buf.String(), tt.want)
+		})
+	}
+}
+
+func TestWriter_Errors(t *testing.T) {
+	t.Parallel()
+	_, err := export.NewEventWriter(&bytes.Buffer{}, "xml", export.Options{})
+	be.Err(t, err, export.ErrUnknownFormat)
+	be.Err(t, export.WriteChanges(&bytes.Buffer{}, "xlsx", "k", nil, export.Options{}), export.ErrUnknownFormat)
+
+	w, err := export.NewEventWriter(&bytes.Buffer{}, export.NDJSON, export.Options{})
+	be.Err(t, err, nil)
+	be.Err(t, w.Close(), nil)
+	be.Err(t, w.Close(), nil)
+	be.Err(t, w.Write("k", audit.Event{}), "write after Close")
+}
@@ -0,0 +1,596 @@
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrStorageClosed is returned by storage operations after Close.
+var ErrStorageClosed = errors.New("audit: storage closed")
+
+// ErrReadOnly is returned by writes to a FileStorage opened with ReadOnly.
+var ErrReadOnly = errors.New("audit: storage is read-only")
+
+// SyncPolicy controls when FileStorage flushes appended records to stable storage.
+type SyncPolicy int
+
+const (
+	// SyncAlways calls fsync after every Store. This is the safest and the default.
+	SyncAlways SyncPolicy = iota
+
+	// SyncInterval calls fsync in the background at most once per
+	// FileStorageOptions.SyncInterval. A crash may lose the last interval of events.
+	SyncInterval
+
+	// SyncNever leaves flushing to the operating system.
+	SyncNever
+)
+
+// defaultSyncInterval is used with SyncInterval when no interval is configured.
+const defaultSyncInterval = time.Second
+
+// FileStorageOptions configures a FileStorage.
+type FileStorageOptions struct {
+	// Sync selects the fsync policy. Defaults to SyncAlways.
+	Sync SyncPolicy
+
+	// SyncInterval is the flush period used with SyncInterval.
+	// Defaults to one second.
+	SyncInterval time.Duration
+
+	// Perm is the permission used when the file is created.
+	// Defaults to 0o600.
+	Perm os.FileMode
+
+	// IdempotencyWindow is the number of idempotency keys remembered for
+	// deduplication, including ones read back when the file is opened.
+	// Defaults to DefaultIdempotencyWindow; a negative value disables it.
+	IdempotencyWindow int
+
+	// ReadOnly opens an existing file without write access. The file is never
+	// created or repaired: a partially written trailing record is ignored
+	// instead of truncated, and writes return ErrReadOnly.
+	ReadOnly bool
+}
+
+// fileRecord is a single JSON Lines record in a FileStorage file.
+type fileRecord struct {
+	Key        string      `json:"key"`
+	Op         string      `json:"op,omitempty"`
+	Event      *Event      `json:"event,omitempty"`
+	Checkpoint *Checkpoint `json:"checkpoint,omitempty"`
+}
+
+// Record operations other than storing an event.
+const (
+	// opClear marks a record that removes all previous events for its key.
+	opClear = "clear"
+
+	// opCheckpoint marks a record holding the checkpoint Prune left for its key.
+	opCheckpoint = "checkpoint"
+)
+
+// recordRef locates an encoded event within the file.
+type recordRef struct {
+	offset int64
+	length int
+}
+
+// sharedFile is an open storage file that reads may keep using after Prune
+// has replaced it. A retired file is closed once its last reader is done.
+type sharedFile struct {
+	*os.File
+
+	mu      sync.Mutex
+	readers int
+	retired bool
+}
+
+func (f *sharedFile) acquire() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.readers++
+}
+
+func (f *sharedFile) release() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.readers--
+	if f.retired && f.readers == 0 {
+		_ = f.Close()
+	}
+}
+
+// retire closes the file now if nobody is reading it, or after the last read.
+func (f *sharedFile) retire() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.retired = true
+	if f.readers == 0 {
+		_ = f.Close()
+	}
+}
+
+// FileStorage is an append-only StorageV2 that writes each event as one
+// JSON Lines record. A per-key offset index is rebuilt when the file is opened,
+// so Store costs one append and Get reads only the records of the requested key.
+//
+// Payload data goes through encoding/json, so values read back have JSON types
+// (json.Number for numbers, map[string]any for objects).
+//
+// Clear appends a tombstone record instead of rewriting the file; Prune
+// rewrites it without the removed events and records checkpoints in it.
+// Reads run concurrently with each other and with appends.
+// A FileStorage must not be shared between processes.
+type FileStorage struct {
+	mu     sync.RWMutex
+	file   *sharedFile
+	path   string
+	size   int64
+	index  map[string][]recordRef
+	dedup  *dedupWindow
+	opts   FileStorageOptions
+	dirty  bool
+	closed bool
+
+	pruneMu     sync.Mutex            // serializes Prune
+	checkpoints map[string]Checkpoint // last pruned event per key
+	generation  uint64                // incremented by Clear, so Prune can detect it
+
+	stop     chan struct{}
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+// OpenFileStorage opens or creates the JSON Lines file at path and rebuilds the index.
+// A partially written trailing record, as left by a crash, is truncated unless
+// opts.ReadOnly is set.
+//
+// Example:
+//
+//	storage, err := audit.OpenFileStorage("audit.jsonl", audit.FileStorageOptions{})
+//	if err != nil {
+//	    return err
+//	}
+//	defer storage.Close()
+//	logger := audit.New(audit.WithStorageV2(storage))
+func OpenFileStorage(path string, opts FileStorageOptions) (*FileStorage, error) {
+	if opts.Perm == 0 {
+		opts.Perm = 0o600
+	}
+	if opts.SyncInterval <= 0 {
+		opts.SyncInterval = defaultSyncInterval
+	}
+	if opts.IdempotencyWindow == 0 {
+		opts.IdempotencyWindow = DefaultIdempotencyWindow
+	}
+
+	flag := os.O_RDWR | os.O_CREATE
+	if opts.ReadOnly {
+		flag = os.O_RDONLY
+	}
+	file, err := os.OpenFile(path, flag, opts.Perm)
+	if err != nil {
+		return nil, fmt.Errorf("audit: open %s: %w", path, err)
+	}
+
+	s := &FileStorage{
+		file:        &sharedFile{File: file},
+		path:        path,
+		index:       make(map[string][]recordRef),
+		dedup:       newDedupWindow(opts.IdempotencyWindow),
+		opts:        opts,
+		checkpoints: make(map[string]Checkpoint),
+	}
+	if err := s.rebuild(); err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+
+	if opts.Sync == SyncInterval && !opts.ReadOnly {
+		s.stop = make(chan struct{})
+		s.done = make(chan struct{})
+		go s.syncLoop()
+	}
+
+	return s, nil
+}
+
+// rebuild scans the file, populating the index and truncating a torn tail.
+func (s *FileStorage) rebuild() error {
+	reader := bufio.NewReader(s.file)
+	var offset int64
+	for {
+		line, err := reader.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			if len(line) > 0 {
+				// Incomplete record from an interrupted write.
+				return s.truncate(offset)
+			}
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("audit: read %s: %w", s.path, err)
+		}
+
+		var rec fileRecord
+		if err := unmarshalEvent(line, &rec); err != nil {
+			if _, peekErr := reader.Peek(1); errors.Is(peekErr, io.EOF) {
+				return s.truncate(offset)
+			}
+			return fmt.Errorf("audit: corrupt record at offset %d in %s: %w", offset, s.path, err)
+		}
+		s.apply(rec, recordRef{offset: offset, length: len(line)})
+		offset += int64(len(line))
+	}
+
+	s.size = offset
+	return nil
+}
+
+// unmarshalEvent decodes stored event data into v. Numbers are decoded as
+// json.Number, so payload values keep their exact text and hashes still match;
+// float64 would change integers above 2^53.
+func unmarshalEvent(data []byte, v any) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(v); err != nil {
+		return err
+	}
+	if dec.More() {
+		return errors.New("unexpected data after record")
+	}
+	return nil
+}
+
+// truncate drops everything after offset and positions the file there. A
+// read-only storage leaves the file alone and only ignores what follows offset.
+func (s *FileStorage) truncate(offset int64) error {
+	if s.opts.ReadOnly {
+		s.size = offset
+		return nil
+	}
+	if err := s.file.Truncate(offset); err != nil {
+		return fmt.Errorf("audit: truncate %s: %w", s.path, err)
+	}
+	s.size = offset
+	return nil
+}
+
+// replaceFile atomically replaces the file at path with the data written by
+// write: it writes a temporary file next to it, syncs it and renames it over path.
+func replaceFile(path string, perm os.FileMode, write func(w io.Writer) error) error {
+	tmp := path + ".tmp"
+	file, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(file)
+	err = write(w)
+	if err == nil {
+		err = w.Flush()
+	}
+	if err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp, path)
+	}
+	if err != nil {
+		_ = os.Remove(tmp)
+	}
+	return err
+}
+
+// apply updates the index with a decoded record.
+func (s *FileStorage) apply(rec fileRecord, ref recordRef) {
+	switch rec.Op {
+	case opClear:
+		delete(s.index, rec.Key)
+		delete(s.checkpoints, rec.Key)
+		s.dedup.forget(rec.Key)
+		return
+	case opCheckpoint:
+		if rec.Checkpoint != nil {
+			s.checkpoints[rec.Key] = *rec.Checkpoint
+		}
+		return
+	}
+	s.index[rec.Key] = append(s.index[rec.Key], ref)
+	if rec.Event != nil {
+		s.dedup.add(rec.Key, *rec.Event)
+	}
+}
+
+// append writes encoded records at the end of the file with a single write.
+// Either all records are written or none. Callers must hold s.mu.
+func (s *FileStorage) append(recs ...fileRecord) ([]recordRef, error) {
+	if s.closed {
+		return nil, ErrStorageClosed
+	}
+	if s.opts.ReadOnly {
+		return nil, ErrReadOnly
+	}
+
+	var buf bytes.Buffer
+	refs := make([]recordRef, 0, len(recs))
+	for _, rec := range recs {
+		line, err := json.Marshal(rec)
+		if err != nil {
+			return nil, fmt.Errorf("audit: encode event: %w", err)
+		}
+		line = append(line, '\n')
+		refs = append(refs, recordRef{offset: s.size + int64(buf.Len()), length: len(line)})
+		buf.Write(line)
+	}
+
+	if _, err := s.file.WriteAt(buf.Bytes(), s.size); err != nil {
+		// Drop whatever part of the records made it to disk.
+		_ = s.file.Truncate(s.size)
+		return nil, fmt.Errorf("audit: write %s: %w", s.path, err)
+	}
+
+	switch s.opts.Sync {
+	case SyncAlways:
+		if err := s.file.Sync(); err != nil {
+			// The records were not stored, so they must not turn up after a reopen.
+			_ = s.file.Truncate(s.size)
+			return nil, fmt.Errorf("audit: sync %s: %w", s.path, err)
+		}
+	case SyncInterval:
+		s.dirty = true
+	case SyncNever:
+	}
+
+	s.size += int64(buf.Len())
+	return refs, nil
+}
+
+// Store appends an event to the file for the given key.
+func (s *FileStorage) Store(ctx context.Context, key string, event Event) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rec := fileRecord{Key: key, Event: &event}
+	refs, err := s.append(rec)
+	if err != nil {
+		return err
+	}
+	s.apply(rec, refs[0])
+	return nil
+}
+
+// StoreBatch appends events with a single write and, with SyncAlways, a single fsync.
+// It implements BatchStorer.
+func (s *FileStorage) StoreBatch(ctx context.Context, events []KeyedEvent) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	recs := make([]fileRecord, len(events))
+	for i := range events {
+		recs[i] = fileRecord{Key: events[i].Key, Event: &events[i].Event}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	refs, err := s.append(recs...)
+	if err != nil {
+		return err
+	}
+	for i, rec := range recs {
+		s.apply(rec, refs[i])
+	}
+	return nil
+}
+
+// Get reads all events for a given key in insertion order.
+// Returns an empty slice if the key doesn't exist.
+func (s *FileStorage) Get(ctx context.Context, key string) ([]Event, error) {
+	file, refs, err := s.refs(key)
+	if err != nil {
+		return nil, err
+	}
+	defer file.release()
+
+	events := make([]Event, 0, len(refs))
+	for _, ref := range refs {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		event, err := s.read(file, ref)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+	return events, nil
+}
+
+// refs returns a snapshot of the index entries for key and the file they
+// point into. The caller must release the file when done reading.
+func (s *FileStorage) refs(key string) (*sharedFile, []recordRef, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.closed {
+		return nil, nil, ErrStorageClosed
+	}
+	// Entries are only ever appended, so the prefix we capture stays valid
+	// until Prune replaces the file, which the caller keeps open meanwhile.
+	s.file.acquire()
+	return s.file, s.index[key], nil
+}
+
+// read decodes the event stored at ref in file.
+func (s *FileStorage) read(file *sharedFile, ref recordRef) (Event, error) {
+	buf := make([]byte, ref.length)
+	if _, err := file.ReadAt(buf, ref.offset); err != nil {
+		if errors.Is(err, os.ErrClosed) {
+			return Event{}, ErrStorageClosed
+		}
+		return Event{}, fmt.Errorf("audit: read %s: %w", s.path, err)
+	}
+
+	var rec fileRecord
+	if err := unmarshalEvent(bytes.TrimSpace(buf), &rec); err != nil || rec.Event == nil {
+		return Event{}, fmt.Errorf("audit: corrupt record at offset %d in %s", ref.offset, s.path)
+	}
+	return *rec.Event, nil
+}
+
+// Has checks if any events exist for a given key.
+func (s *FileStorage) Has(ctx context.Context, key string) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.closed {
+		return false, ErrStorageClosed
+	}
+	return len(s.index[key]) > 0, nil
+}
+
+// Keys iterates over a snapshot of the keys starting with prefix.
+// It implements KeyLister. Iteration stops early if the storage is closed.
+func (s *FileStorage) Keys(prefix string) iter.Seq[string] {
+	return func(yield func(string) bool) {
+		keys, _, _ := s.KeysPage(context.Background(), prefix, "", 0)
+		for _, key := range keys {
+			if !yield(key) {
+				return
+			}
+		}
+	}
+}
+
+// KeysPage returns a page of keys starting with prefix. It implements KeyLister.
+// The index is not kept sorted, so each call sorts the matching keys.
+func (s *FileStorage) KeysPage(ctx context.Context, prefix, cursor string, limit int) ([]string, string, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, "", err
+	}
+
+	s.mu.RLock()
+	if s.closed {
+		s.mu.RUnlock()
+		return nil, "", ErrStorageClosed
+	}
+	matching := make([]string, 0, len(s.index))
+	for key := range s.index {
+		if strings.HasPrefix(key, prefix) {
+			matching = append(matching, key)
+		}
+	}
+	s.mu.RUnlock()
+
+	slices.Sort(matching)
+	keys, next := pageKeys(matching, prefix, cursor, limit)
+	return keys, next, nil
+}
+
+// Clear removes all events for a given key by appending a tombstone record.
+func (s *FileStorage) Clear(ctx context.Context, key string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, hasEvents := s.index[key]
+	_, hasCheckpoint := s.checkpoints[key]
+	if !hasEvents && !hasCheckpoint {
+		return nil
+	}
+	rec := fileRecord{Key: key, Op: opClear}
+	refs, err := s.append(rec)
+	if err != nil {
+		return err
+	}
+	s.apply(rec, refs[0])
+	s.generation++
+	return nil
+}
+
+// Sync flushes appended records to stable storage.
+func (s *FileStorage) Sync() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.sync()
+}
+
+// sync flushes the file if needed. Callers must hold s.mu.
+func (s *FileStorage) sync() error {
+	if s.closed {
+		return ErrStorageClosed
+	}
+	if s.opts.ReadOnly {
+		return nil
+	}
+	if err := s.file.Sync(); err != nil {
+		return fmt.Errorf("audit: sync %s: %w", s.path, err)
+	}
+	s.dirty = false
+	return nil
+}
+
+// syncLoop periodically flushes the file for SyncInterval.
+func (s *FileStorage) syncLoop() {
+	defer close(s.done)
+	ticker := time.NewTicker(s.opts.SyncInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+			s.mu.Lock()
+			if s.dirty {
+				_ = s.sync()
+			}
+			s.mu.Unlock()
+		}
+	}
+}
+
+// Close flushes pending records and closes the file.
+// Subsequent operations return ErrStorageClosed.
+func (s *FileStorage) Close() error {
+	if s.stop != nil {
+		s.stopOnce.Do(func() {
+			close(s.stop)
+			<-s.done
+		})
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return nil
+	}
+
+	syncErr := s.sync()
+	s.closed = true
+	return errors.Join(syncErr, s.file.Close())
+}
@@ -0,0 +1,206 @@
+package audit_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/w0rng/audit"
+	"github.com/w0rng/audit/internal/be"
+)
+
+func openFileStorage(t *testing.T, path string, opts audit.FileStorageOptions) *audit.FileStorage {
+	t.Helper()
+	storage, err := audit.OpenFileStorage(path, opts)
+	be.Err(t, err, nil)
+	t.Cleanup(func() { _ = storage.Close() })
+	return storage
+}
+
+func TestFileStorage_StoreGet(t *testing.T) {
+	t.Parallel()
+	storage := openFileStorage(t, filepath.Join(t.TempDir(), "audit.jsonl"), audit.FileStorageOptions{})
+	ctx := t.Context()
+
+	be.Err(t, storage.Store(ctx, "user:1", audit.Event{
+		Timestamp: time.Now(),
+		Action:    audit.ActionCreate,
+		Author:    "admin",
+		Payload: map[string]audit.Value{
+			"email":    audit.PlainValue("user@example.com"),
+			"password": audit.HiddenValue(),
+		},
+	}), nil)
+	be.Err(t, storage.Store(ctx, "user:2", audit.Event{Author: "other"}), nil)
+	be.Err(t, storage.Store(ctx, "user:1", audit.Event{Action: audit.ActionUpdate, Author: "bob"}), nil)
+
+	events, err := storage.Get(ctx, "user:1")
+	be.Err(t, err, nil)
+	be.Equal(t, len(events), 2)
+	be.Equal(t, events[0].Author, "admin")
+	be.Equal(t, events[0].Payload["email"].Data, any("user@example.com"))
+	be.True(t, events[0].Payload["password"].Hidden)
+	be.Equal(t, events[1].Action, audit.ActionUpdate)
+
+	events, err = storage.Get(ctx, "missing")
+	be.Err(t, err, nil)
+	be.Equal(t, len(events), 0)
+
+	has, err := storage.Has(ctx, "user:2")
+	be.Err(t, err, nil)
+	be.True(t, has)
+}
+
+func TestFileStorage_Reopen(t *testing.T) {
+	t.Parallel()
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	ctx := t.Context()
+
+	storage, err := audit.OpenFileStorage(path, audit.FileStorageOptions{Sync: audit.SyncNever})
+	be.Err(t, err, nil)
+	for i := range 3 {
+		be.Err(t, storage.Store(ctx, "order:1", audit.Event{Author: fmt.Sprintf("user%d", i)}), nil)
+	}
+	be.Err(t, storage.Store(ctx, "order:2", audit.Event{Author: "cleared"}), nil)
+	be.Err(t, storage.Clear(ctx, "order:2"), nil)
+	be.Err(t, storage.Close(), nil)
+
+	storage = openFileStorage(t, path, audit.FileStorageOptions{})
+	events, err := storage.Get(ctx, "order:1")
+	be.Err(t, err, nil)
+	be.Equal(t, len(events), 3)
+	be.Equal(t, events[2].Author, "user2")
+
+	has, err := storage.Has(ctx, "order:2")
+	be.Err(t, err, nil)
+	be.True(t, !has)
+}
+
+func TestFileStorage_TruncatesTornRecord(t *testing.T) {
+	t.Parallel()
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	ctx := t.Context()
+
+	storage, err := audit.OpenFileStorage(path, audit.FileStorageOptions{})
+	be.Err(t, err, nil)
+	be.Err(t, storage.Store(ctx, "key", audit.Event{Author: "first"}), nil)
+	be.Err(t, storage.Close(), nil)
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+	be.Err(t, err, nil)
+	_, err = f.WriteString(`{"key":"key","event":{"auth`)
+	be.Err(t, err, nil)
+	be.Err(t, f.Close(), nil)
+
+	storage = openFileStorage(t, path, audit.FileStorageOptions{})
+	be.Err(t, storage.Store(ctx, "key", audit.Event{Author: "second"}), nil)
+
+	events, err := storage.Get(ctx, "key")
+	be.Err(t, err, nil)
+	be.Equal(t, len(events), 2)
+	be.Equal(t, events[1].Author, "second")
+}
+
+func TestFileStorage_ReadOnly(t *testing.T) {
+	t.Parallel()
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	ctx := t.Context()
+
+	_, err := audit.OpenFileStorage(path, audit.FileStorageOptions{ReadOnly: true})
+	be.Err(t, err, os.ErrNotExist)
+
+	storage, err := audit.OpenFileStorage(path, audit.FileStorageOptions{})
+	be.Err(t, err, nil)
+	be.Err(t, storage.Store(ctx, "key", audit.Event{Author: "first"}), nil)
+	be.Err(t, storage.Close(), nil)
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+	be.Err(t, err, nil)
+	_, err = f.WriteString(`{"key":"key","event":{"auth`)
+	be.Err(t, err, nil)
+	be.Err(t, f.Close(), nil)
+	before, err := os.ReadFile(path)
+	be.Err(t, err, nil)
+
+	storage = openFileStorage(t, path, audit.FileStorageOptions{ReadOnly: true, Sync: audit.SyncInterval})
+	events, err := storage.Get(ctx, "key")
+	be.Err(t, err, nil)
+	be.Equal(t, len(events), 1)
+	be.Err(t, storage.Store(ctx, "key", audit.Event{Author: "second"}), audit.ErrReadOnly)
+	be.Err(t, storage.Clear(ctx, "key"), audit.ErrReadOnly)
+	be.Err(t, storage.Close(), nil)
+
+	after, err := os.ReadFile(path)
+	be.Err(t, err, nil)
+	be.Equal(t, string(after), string(before))
+}
+
+func TestFileStorage_CorruptRecord(t *testing.T) {
+	t.Parallel()
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	data := "not json\n" + `{"key":"key","event":{"author":"ok"}}` + "\n"
+	be.Err(t, os.WriteFile(path, []byte(data), 0o600), nil)
+
+	_, err := audit.OpenFileStorage(path, audit.FileStorageOptions{})
+	be.Err(t, err, "corrupt record at offset 0")
+}
+
+func TestFileStorage_Closed(t *testing.T) {
+	t.Parallel()
+	storage, err := audit.OpenFileStorage(
+		filepath.Join(t.TempDir(), "audit.jsonl"),
+		audit.FileStorageOptions{Sync: audit.SyncInterval, SyncInterval: time.Millisecond},
+	)
+	be.Err(t, err, nil)
+	ctx := t.Context()
+
+	be.Err(t, storage.Store(ctx, "key", audit.Event{}), nil)
+	be.Err(t, storage.Close(), nil)
+	be.Err(t, storage.Close(), nil)
+
+	be.Err(t, storage.Store(ctx, "key", audit.Event{}), audit.ErrStorageClosed)
+	_, err = storage.Get(ctx, "key")
+	be.Err(t, err, audit.ErrStorageClosed)
+	be.Err(t, storage.Sync(), audit.ErrStorageClosed)
+}
+
+func TestFileStorage_Concurrency(t *testing.T) {
+	t.Parallel()
+	storage := openFileStorage(t, filepath.Join(t.TempDir(), "audit.jsonl"), audit.FileStorageOptions{
+		Sync: audit.SyncInterval,
+	})
+	ctx := t.Context()
+	const goroutines = 20
+	const eventsPerGoroutine = 10
+
+	var wg sync.WaitGroup
+	for i := range goroutines {
+		wg.Go(func() {
+			key := fmt.Sprintf("key:%d", i)
+			for j := range eventsPerGoroutine {
+				err := storage.Store(ctx, key, audit.Event{
+					Payload: map[string]audit.Value{"value": audit.PlainValue(j)},
+				})
+				if err != nil {
+					t.Error(err)
+				}
+				if _, err := storage.Get(ctx, key); err != nil {
+					t.Error(err)
+				}
+			}
+		})
+	}
+	wg.Wait()
+
+	for i := range goroutines {
+		events, err := storage.Get(ctx, fmt.Sprintf("key:%d", i))
+		be.Err(t, err, nil)
+		be.Equal(t, len(events), eventsPerGoroutine)
+	}
+}
+
+func TestFileStorageInterface(t *testing.T) {
+	var _ audit.StorageV2 = (*audit.FileStorage)(nil)
+}
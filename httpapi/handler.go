@@ -0,0 +1,199 @@
+// Package httpapi exposes an audit.Logger over HTTP for dashboards and tooling.
+//
+// The handler serves three read-only endpoints:
+//
+//	GET /entities/{key}/events   events of an entity as JSON, optionally narrowed with ?field=
+//	GET /entities/{key}/changes  field-level change history as JSON, optionally bounded with ?from= and ?to=
+//	GET /stream?prefix=          new events as Server-Sent Events
+//
+// Keys containing a slash must be escaped in the path (%2F). Mount the handler
+// under a path prefix with http.StripPrefix and wrap it with your own
+// authentication middleware:
+//
+//	mux.Handle("/audit/", http.StripPrefix("/audit", httpapi.NewHandler(logger, httpapi.Options{})))
+package httpapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/w0rng/audit"
+)
+
+// defaultHeartbeat is used when Options.Heartbeat is not set.
+const defaultHeartbeat = 30 * time.Second
+
+// Options configures a Handler.
+type Options struct {
+	// Heartbeat is the interval of the comment lines sent on idle streams,
+	// which keep proxies from closing the connection. Defaults to 30 seconds.
+	Heartbeat time.Duration
+
+	// Buffer is the number of events buffered for each stream client.
+	// A client that falls further behind is disconnected and should
+	// reconnect. Defaults to the audit.Filter default.
+	Buffer int
+}
+
+// Handler serves the audit HTTP API. Its methods can also be registered
+// individually on a custom router; they read the key from the "key" path value.
+type Handler struct {
+	logger *audit.Logger
+	opts   Options
+	mux    *http.ServeMux
+}
+
+// NewHandler creates a Handler serving the events of logger.
+//
+// Example:
+//
+//	http.ListenAndServe(":8080", httpapi.NewHandler(logger, httpapi.Options{}))
+func NewHandler(logger *audit.Logger, opts Options) *Handler {
+	if opts.Heartbeat <= 0 {
+		opts.Heartbeat = defaultHeartbeat
+	}
+
+	h := &Handler{logger: logger, opts: opts, mux: http.NewServeMux()}
+	h.mux.HandleFunc("GET /entities/{key}/events", h.Events)
+	h.mux.HandleFunc("GET /entities/{key}/changes", h.Changes)
+	h.mux.HandleFunc("GET /stream", h.Stream)
+	return h
+}
+
+// ServeHTTP implements http.Handler.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mux.ServeHTTP(w, r)
+}
+
+// Events responds with the events of the entity as a JSON array. Repeated
+// field query parameters keep only events with those payload fields, like
+// Logger.Events.
+func (h *Handler) Events(w http.ResponseWriter, r *http.Request) {
+	events, err := h.logger.EventsContext(r.Context(), r.PathValue("key"), r.URL.Query()["field"]...)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if events == nil {
+		events = []audit.Event{}
+	}
+	writeJSON(w, http.StatusOK, events)
+}
+
+// Changes responds with the change history of the entity as a JSON array.
+// The optional from and to query parameters are RFC 3339 timestamps that
+// bound the history like Logger.LogsRange.
+func (h *Handler) Changes(w http.ResponseWriter, r *http.Request) {
+	from, err := parseTime(r, "from")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	to, err := parseTime(r, "to")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	changes, err := h.logger.LogsRangeContext(r.Context(), r.PathValue("key"), from, to)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if changes == nil {
+		changes = []audit.Change{}
+	}
+	writeJSON(w, http.StatusOK, changes)
+}
+
+// Stream sends the events logged from now on as Server-Sent Events, until
+// the client disconnects. The optional prefix query parameter restricts the
+// stream to keys starting with it.
+//
+// Each event is sent with the event type set to its action and the data set
+// to the JSON encoding of the audit.KeyedEvent.
+func (h *Handler) Stream(w http.ResponseWriter, r *http.Request) {
+	rc := http.NewResponseController(w)
+	events, cancel := h.logger.Subscribe(audit.Filter{
+		KeyPrefix: r.URL.Query().Get("prefix"),
+		Buffer:    h.opts.Buffer,
+		Slow:      audit.SlowUnsubscribe,
+	})
+	defer cancel()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	if err := rc.Flush(); err != nil {
+		return
+	}
+
+	heartbeat := time.NewTicker(h.opts.Heartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": heartbeat\n\n"); err != nil {
+				return
+			}
+		case e, ok := <-events:
+			if !ok {
+				return
+			}
+			if err := writeEvent(w, e); err != nil {
+				return
+			}
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+// writeEvent writes e as one Server-Sent Event.
+func writeEvent(w http.ResponseWriter, e audit.KeyedEvent) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("httpapi: encode event: %w", err)
+	}
+	event := string(e.Action)
+	if strings.ContainsAny(event, "\r\n") {
+		// A line break would end the field; fall back to the default event type.
+		event = "message"
+	}
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, data)
+	return err
+}
+
+// parseTime reads an optional RFC 3339 timestamp from the query parameter name.
+func parseTime(r *http.Request, name string) (time.Time, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("httpapi: invalid %s: %w", name, err)
+	}
+	return t, nil
+}
+
+// errorResponse is the JSON body of error responses.
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, errorResponse{Error: err.Error()})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,169 @@
+package httpapi_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/w0rng/audit"
+	"github.com/w0rng/audit/httpapi"
+	"github.com/w0rng/audit/internal/be"
+)
+
+func get(t *testing.T, h http.Handler, target string) *httptest.ResponseRecorder {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequestWithContext(t.Context(), http.MethodGet, target, nil))
+	return rec
+}
+
+func TestHandler_Events(t *testing.T) {
+	t.Parallel()
+	logger := audit.New()
+	logger.Create("order/1", "alice", "created", map[string]audit.Value{"status": audit.PlainValue("new")})
+	logger.Update("order/1", "bob", "noted", map[string]audit.Value{"note": audit.PlainValue("rush")})
+	h := httpapi.NewHandler(logger, httpapi.Options{})
+
+	tests := []struct {
+		name    string
+		target  string
+		authors []string
+	}{
+		{"all", "/entities/" + url.PathEscape("order/1") + "/events", []string{"alice", "bob"}},
+		{"fields", "/entities/order%2F1/events?field=note", []string{"bob"}},
+		{"missing", "/entities/missing/events", []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			rec := get(t, h, tt.target)
+			be.Equal(t, rec.Code, http.StatusOK)
+			be.Equal(t, rec.Header().Get("Content-Type"), "application/json")
+
+			var events []audit.Event
+			be.Err(t, json.Unmarshal(rec.Body.Bytes(), &events), nil)
+			authors := []string{}
+			for _, e := range events {
+				authors = append(authors, e.Author)
+			}
+			be.Equal(t, authors, tt.authors)
+		})
+	}
+}
+
+func TestHandler_Changes(t *testing.T) {
+	t.Parallel()
+	logger := audit.New()
+	logger.Create("order:1", "alice", "created", map[string]audit.Value{"status": audit.PlainValue("new")})
+	logger.Update("order:1", "bob", "paid", map[string]audit.Value{"status": audit.PlainValue("paid")})
+	second := logger.Events("order:1")[1].Timestamp
+	h := httpapi.NewHandler(logger, httpapi.Options{})
+
+	rec := get(t, h, "/entities/order:1/changes")
+	be.Equal(t, rec.Code, http.StatusOK)
+	var changes []audit.Change
+	be.Err(t, json.Unmarshal(rec.Body.Bytes(), &changes), nil)
+	be.Equal(t, len(changes), 2)
+	be.Equal(t, changes[1].Fields, []audit.ChangeField{{Field: "status", From: "new", To: "paid"}})
+
+	rec = get(t, h, "/entities/order:1/changes?from="+url.QueryEscape(second.Format(time.RFC3339Nano)))
+	be.Equal(t, rec.Code, http.StatusOK)
+	be.Err(t, json.Unmarshal(rec.Body.Bytes(), &changes), nil)
+	be.Equal(t, len(changes), 1)
+	be.Equal(t, changes[0].Author, "bob")
+
+	rec = get(t, h, "/entities/order:1/changes?to=yesterday")
+	be.Equal(t, rec.Code, http.StatusBadRequest)
+	be.True(t, strings.Contains(rec.Body.String(), "invalid to"))
+}
+
+func TestHandler_Errors(t *testing.T) {
+	t.Parallel()
+	logger := audit.New(audit.WithStorageV2(failingStorage{}))
+	h := httpapi.NewHandler(logger, httpapi.Options{})
+
+	for _, target := range []string{"/entities/k/events", "/entities/k/changes"} {
+		rec := get(t, h, target)
+		be.Equal(t, rec.Code, http.StatusInternalServerError)
+		be.Equal(t, rec.Body.String(), `{"error":"backend unavailable"}`+"\n")
+	}
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequestWithContext(t.Context(), http.MethodPost, "/entities/k/events", nil))
+	be.Equal(t, rec.Code, http.StatusMethodNotAllowed)
+}
+
+func TestHandler_Stream(t *testing.T) {
+	t.Parallel()
+	logger := audit.New()
+	server := httptest.NewServer(httpapi.NewHandler(logger, httpapi.Options{}))
+	defer server.Close()
+
+	req, err := http.NewRequestWithContext(t.Context(), http.MethodGet, server.URL+"/stream?prefix=order:", nil)
+	be.Err(t, err, nil)
+	resp, err := server.Client().Do(req)
+	be.Err(t, err, nil)
+	defer resp.Body.Close()
+	be.Equal(t, resp.Header.Get("Content-Type"), "text/event-stream")
+
+	logger.Create("user:1", "alice", "ignored", map[string]audit.Value{})
+	logger.Create("order:1", "alice", "created", map[string]audit.Value{})
+	logger.Delete("order:1", "bob", "deleted", map[string]audit.Value{})
+
+	reader := bufio.NewReader(resp.Body)
+	for _, want := range []struct {
+		action audit.Action
+		desc   string
+	}{{audit.ActionCreate, "created"}, {audit.ActionDelete, "deleted"}} {
+		line, err := reader.ReadString('\n')
+		be.Err(t, err, nil)
+		be.Equal(t, line, "event: "+string(want.action)+"\n")
+
+		line, err = reader.ReadString('\n')
+		be.Err(t, err, nil)
+		var e audit.KeyedEvent
+		be.Err(t, json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &e), nil)
+		be.Equal(t, e.Key, "order:1")
+		be.Equal(t, e.Description, want.desc)
+
+		line, err = reader.ReadString('\n')
+		be.Err(t, err, nil)
+		be.Equal(t, line, "\n")
+	}
+}
+
+func TestHandler_Stream_Heartbeat(t *testing.T) {
+	t.Parallel()
+	server := httptest.NewServer(httpapi.NewHandler(audit.New(), httpapi.Options{Heartbeat: time.Millisecond}))
+	defer server.Close()
+
+	req, err := http.NewRequestWithContext(t.Context(), http.MethodGet, server.URL+"/stream", nil)
+	be.Err(t, err, nil)
+	resp, err := server.Client().Do(req)
+	be.Err(t, err, nil)
+	defer resp.Body.Close()
+
+	line, err := bufio.NewReader(resp.Body).ReadString('\n')
+	be.Err(t, err, nil)
+	be.Equal(t, line, ": heartbeat\n")
+}
+
+// failingStorage is a StorageV2 that fails every call.
+type failingStorage struct{}
+
+var errBackend = errors.New("backend unavailable")
+
+func (failingStorage) Store(context.Context, string, audit.Event) error { return errBackend }
+
+func (failingStorage) Get(context.Context, string) ([]audit.Event, error) { return nil, errBackend }
+
+func (failingStorage) Has(context.Context, string) (bool, error) { return false, errBackend }
+
+func (failingStorage) Clear(context.Context, string) error { return errBackend }
@@ -0,0 +1,251 @@
+// Package httpmw provides net/http middleware that records an audit event for
+// every mutating request.
+//
+// POST, PUT, PATCH and DELETE requests are logged as ActionCreate,
+// ActionUpdate, ActionUpdate and ActionDelete. The entity key is derived from
+// the route's path values after the request was routed, so the middleware
+// works with Go 1.22 ServeMux patterns:
+//
+//	mux := http.NewServeMux()
+//	mux.HandleFunc("PUT /orders/{id}", updateOrder)
+//	handler := httpmw.Middleware(logger, httpmw.Options{
+//	    Key: httpmw.KeyTemplate("order:{id}"),
+//	})(mux)
+package httpmw
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/w0rng/audit"
+)
+
+// Payload fields recorded for each audited request.
+const (
+	FieldMethod  = "http_method"
+	FieldRoute   = "http_route"
+	FieldStatus  = "http_status"
+	FieldLatency = "latency_ms"
+)
+
+// Default header names.
+const (
+	DefaultActorHeader     = "X-User-ID"
+	DefaultRequestIDHeader = "X-Request-ID"
+)
+
+// Options configures the middleware.
+type Options struct {
+	// Actor identifies the caller. If nil, the actor's ID is read from
+	// ActorHeader. The IP and user agent are filled in from the request when
+	// Actor leaves them empty.
+	Actor func(r *http.Request) (audit.Actor, bool)
+
+	// ActorHeader names the header holding the actor's ID when Actor is nil.
+	// Defaults to DefaultActorHeader.
+	ActorHeader string
+
+	// RequestIDHeader names the header holding the request ID.
+	// Defaults to DefaultRequestIDHeader.
+	RequestIDHeader string
+
+	// Key returns the entity key of a routed request; an empty key skips the
+	// request. If nil, the request path is used.
+	Key func(r *http.Request) string
+
+	// ShouldAudit decides whether a request that completed with status is
+	// recorded. If nil, every mutating request is recorded, including failed ones.
+	ShouldAudit func(r *http.Request, status int) bool
+
+	// OnError, if set, is called when the audit event cannot be stored.
+	OnError func(r *http.Request, err error)
+}
+
+// Middleware returns a middleware that records mutating requests to logger.
+//
+// Before calling the next handler it stores the actor and request ID in the
+// request context with audit.WithActor and audit.WithRequestID, so handlers
+// logging to any Logger with the *Context methods record them too. After the
+// handler returns, the request is logged under the key returned by opts.Key,
+// with the method, route pattern, status code and latency as payload.
+func Middleware(logger *audit.Logger, opts Options) func(http.Handler) http.Handler {
+	if opts.ActorHeader == "" {
+		opts.ActorHeader = DefaultActorHeader
+	}
+	if opts.RequestIDHeader == "" {
+		opts.RequestIDHeader = DefaultRequestIDHeader
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := r.Context()
+			if actor, ok := opts.actor(r); ok {
+				ctx = audit.WithActor(ctx, actor)
+			}
+			if id := r.Header.Get(opts.RequestIDHeader); id != "" {
+				ctx = audit.WithRequestID(ctx, id)
+			}
+			// The mux records the pattern and path values on this request.
+			r = r.WithContext(ctx)
+
+			start := time.Now()
+			rec := &statusRecorder{ResponseWriter: w}
+			next.ServeHTTP(rec, r)
+
+			action, ok := actionOf(r.Method)
+			if !ok {
+				return
+			}
+			opts.record(logger, r, action, rec.statusCode(), time.Since(start))
+		})
+	}
+}
+
+// record logs the completed request.
+func (o Options) record(logger *audit.Logger, r *http.Request, action audit.Action, status int, latency time.Duration) {
+	if o.ShouldAudit != nil && !o.ShouldAudit(r, status) {
+		return
+	}
+	key := r.URL.Path
+	if o.Key != nil {
+		key = o.Key(r)
+	}
+	if key == "" {
+		return
+	}
+
+	route := r.Pattern
+	if route == "" {
+		route = r.Method + " " + r.URL.Path
+	}
+	payload := map[string]audit.Value{
+		FieldMethod:  audit.PlainValue(r.Method),
+		FieldRoute:   audit.PlainValue(route),
+		FieldStatus:  audit.PlainValue(status),
+		FieldLatency: audit.PlainValue(latency.Milliseconds()),
+	}
+
+	// The client may be gone already; the event is recorded regardless.
+	ctx := context.WithoutCancel(r.Context())
+	if err := logger.LogChangeContext(ctx, key, action, "", route, payload); err != nil && o.OnError != nil {
+		o.OnError(r, err)
+	}
+}
+
+// actor identifies the caller of r.
+func (o Options) actor(r *http.Request) (audit.Actor, bool) {
+	var actor audit.Actor
+	if o.Actor != nil {
+		var ok bool
+		if actor, ok = o.Actor(r); !ok {
+			return audit.Actor{}, false
+		}
+	} else {
+		actor.ID = r.Header.Get(o.ActorHeader)
+		if actor.ID == "" {
+			return audit.Actor{}, false
+		}
+	}
+
+	if actor.IP == "" {
+		actor.IP = remoteIP(r)
+	}
+	if actor.UserAgent == "" {
+		actor.UserAgent = r.UserAgent()
+	}
+	return actor, true
+}
+
+// KeyTemplate returns a Key function that replaces each {name} in template
+// with the request's path value of that name. It returns an empty key, which
+// skips the request, if any path value is missing.
+//
+// Example:
+//
+//	mux.HandleFunc("PUT /orders/{id}", updateOrder)
+//	opts := httpmw.Options{Key: httpmw.KeyTemplate("order:{id}")}
+func KeyTemplate(template string) func(r *http.Request) string {
+	return func(r *http.Request) string {
+		var b strings.Builder
+		rest := template
+		for {
+			start := strings.IndexByte(rest, '{')
+			if start < 0 {
+				b.WriteString(rest)
+				return b.String()
+			}
+			end := strings.IndexByte(rest[start:], '}')
+			if end < 0 {
+				b.WriteString(rest)
+				return b.String()
+			}
+			value := r.PathValue(rest[start+1 : start+end])
+			if value == "" {
+				return ""
+			}
+			b.WriteString(rest[:start])
+			b.WriteString(value)
+			rest = rest[start+end+1:]
+		}
+	}
+}
+
+// actionOf maps mutating HTTP methods to audit actions.
+func actionOf(method string) (audit.Action, bool) {
+	switch method {
+	case http.MethodPost:
+		return audit.ActionCreate, true
+	case http.MethodPut, http.MethodPatch:
+		return audit.ActionUpdate, true
+	case http.MethodDelete:
+		return audit.ActionDelete, true
+	default:
+		return "", false
+	}
+}
+
+// remoteIP returns the host part of the request's remote address.
+func remoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// statusRecorder captures the status code written by the next handler.
+type statusRecorder struct {
+	http.ResponseWriter
+
+	status int
+}
+
+func (s *statusRecorder) WriteHeader(code int) {
+	if s.status == 0 {
+		s.status = code
+	}
+	s.ResponseWriter.WriteHeader(code)
+}
+
+func (s *statusRecorder) Write(b []byte) (int, error) {
+	if s.status == 0 {
+		s.status = http.StatusOK
+	}
+	return s.ResponseWriter.Write(b)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (s *statusRecorder) Unwrap() http.ResponseWriter {
+	return s.ResponseWriter
+}
+
+// statusCode returns the recorded status; handlers that write nothing respond 200.
+func (s *statusRecorder) statusCode() int {
+	if s.status == 0 {
+		return http.StatusOK
+	}
+	return s.status
+}
@@ -0,0 +1,158 @@
+package httpmw_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/w0rng/audit"
+	"github.com/w0rng/audit/httpmw"
+	"github.com/w0rng/audit/internal/be"
+)
+
+// newMux returns a mux whose handlers log a domain event through the request context.
+func newMux(logger *audit.Logger) *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /orders", func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+	})
+	mux.HandleFunc("PUT /orders/{id}", func(w http.ResponseWriter, r *http.Request) {
+		_ = logger.UpdateContext(r.Context(), "domain:"+r.PathValue("id"), "", "handler", map[string]audit.Value{})
+		_, _ = w.Write([]byte("ok"))
+	})
+	mux.HandleFunc("DELETE /orders/{id}", func(w http.ResponseWriter, _ *http.Request) {
+		http.Error(w, "locked", http.StatusConflict)
+	})
+	mux.HandleFunc("GET /orders/{id}", func(http.ResponseWriter, *http.Request) {})
+	return mux
+}
+
+func serve(h http.Handler, method, target string, header map[string]string) int {
+	req := httptest.NewRequest(method, target, nil)
+	req.RemoteAddr = "192.0.2.1:1234"
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec.Code
+}
+
+func TestMiddleware(t *testing.T) {
+	t.Parallel()
+	logger := audit.New()
+	h := httpmw.Middleware(logger, httpmw.Options{Key: httpmw.KeyTemplate("order:{id}")})(newMux(logger))
+
+	be.Equal(t, serve(h, http.MethodPut, "/orders/42", map[string]string{
+		"X-User-ID":    "u-1",
+		"X-Request-ID": "req-1",
+		"User-Agent":   "test-agent",
+	}), http.StatusOK)
+	be.Equal(t, serve(h, http.MethodDelete, "/orders/42", nil), http.StatusConflict)
+	be.Equal(t, serve(h, http.MethodGet, "/orders/42", nil), http.StatusOK)
+	// POST /orders has no {id}: the key is empty and the request is skipped.
+	be.Equal(t, serve(h, http.MethodPost, "/orders", nil), http.StatusCreated)
+
+	events := logger.Events("order:42")
+	be.Equal(t, len(events), 2)
+
+	update := events[0]
+	be.Equal(t, update.Action, audit.ActionUpdate)
+	be.Equal(t, update.Author, "u-1")
+	be.Equal(t, update.RequestID, "req-1")
+	be.Equal(t, *update.Actor, audit.Actor{ID: "u-1", IP: "192.0.2.1", UserAgent: "test-agent"})
+	be.Equal(t, update.Description, "PUT /orders/{id}")
+	be.Equal(t, update.Payload[httpmw.FieldMethod].Data, any(http.MethodPut))
+	be.Equal(t, update.Payload[httpmw.FieldRoute].Data, any("PUT /orders/{id}"))
+	be.Equal(t, update.Payload[httpmw.FieldStatus].Data, any(http.StatusOK))
+	_, ok := update.Payload[httpmw.FieldLatency]
+	be.True(t, ok)
+
+	remove := events[1]
+	be.Equal(t, remove.Action, audit.ActionDelete)
+	be.Equal(t, remove.Author, "")
+	be.True(t, remove.Actor == nil)
+	be.Equal(t, remove.Payload[httpmw.FieldStatus].Data, any(http.StatusConflict))
+
+	// The handler's own event carries the actor from the context.
+	domain := logger.Events("domain:42")
+	be.Equal(t, len(domain), 1)
+	be.Equal(t, domain[0].Author, "u-1")
+	be.Equal(t, domain[0].RequestID, "req-1")
+}
+
+func TestMiddleware_Options(t *testing.T) {
+	t.Parallel()
+	logger := audit.New()
+	var errs []error
+	h := httpmw.Middleware(logger, httpmw.Options{
+		Actor: func(r *http.Request) (audit.Actor, bool) {
+			token := r.Header.Get("Authorization")
+			return audit.Actor{ID: "token:" + token, Type: "service", IP: "203.0.113.9"}, token != ""
+		},
+		ShouldAudit: func(_ *http.Request, status int) bool { return status < http.StatusBadRequest },
+		OnError:     func(_ *http.Request, err error) { errs = append(errs, err) },
+	})(newMux(logger))
+
+	serve(h, http.MethodPost, "/orders", map[string]string{"Authorization": "abc"})
+	serve(h, http.MethodDelete, "/orders/1", map[string]string{"Authorization": "abc"})
+
+	events := logger.Events("/orders")
+	be.Equal(t, len(events), 1)
+	be.Equal(t, events[0].Action, audit.ActionCreate)
+	be.Equal(t, *events[0].Actor, audit.Actor{ID: "token:abc", Type: "service", IP: "203.0.113.9"})
+	be.Equal(t, len(logger.Events("/orders/1")), 0)
+	be.Equal(t, len(errs), 0)
+}
+
+func TestMiddleware_OnError(t *testing.T) {
+	t.Parallel()
+	errBackend := errors.New("backend unavailable")
+	logger := audit.New(audit.WithStorageV2(failingStorage{err: errBackend}))
+	var errs []error
+	h := httpmw.Middleware(logger, httpmw.Options{
+		OnError: func(_ *http.Request, err error) { errs = append(errs, err) },
+	})(newMux(audit.New()))
+
+	be.Equal(t, serve(h, http.MethodPost, "/orders", nil), http.StatusCreated)
+	be.Equal(t, len(errs), 1)
+	be.Err(t, errs[0], errBackend)
+}
+
+func TestKeyTemplate(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		template string
+		want     string
+	}{
+		{"order:{id}", "order:7"},
+		{"{tenant}/order:{id}", "acme/order:7"},
+		{"static", "static"},
+		{"order:{missing}", ""},
+		{"order:{id", "order:{id"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.template, func(t *testing.T) {
+			t.Parallel()
+			req := httptest.NewRequest(http.MethodPut, "/acme/orders/7", nil)
+			req.SetPathValue("tenant", "acme")
+			req.SetPathValue("id", "7")
+			be.Equal(t, httpmw.KeyTemplate(tt.template)(req), tt.want)
+		})
+	}
+}
+
+// failingStorage is a StorageV2 that fails every call with err.
+type failingStorage struct {
+	err error
+}
+
+func (f failingStorage) Store(context.Context, string, audit.Event) error { return f.err }
+
+func (f failingStorage) Get(context.Context, string) ([]audit.Event, error) { return nil, f.err }
+
+func (f failingStorage) Has(context.Context, string) (bool, error) { return false, f.err }
+
+func (f failingStorage) Clear(context.Context, string) error { return f.err }
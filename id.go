@@ -0,0 +1,101 @@
+package audit
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"sync"
+	"time"
+)
+
+// IDGenerator returns a unique ID for an event recorded at t.
+type IDGenerator func(t time.Time) string
+
+// WithClock sets the function that stamps events with the current time.
+// It makes timestamps deterministic in tests and replays. Defaults to time.Now.
+func WithClock(clock func() time.Time) Option {
+	return func(l *Logger) {
+		l.clock = clock
+	}
+}
+
+// WithIDGenerator sets the function that assigns Event.ID and transaction IDs.
+// Defaults to NewIDGenerator().
+func WithIDGenerator(gen IDGenerator) Option {
+	return func(l *Logger) {
+		l.newID = gen
+	}
+}
+
+const (
+	// ulidLen is the length of an encoded ULID.
+	ulidLen = 26
+	// ulidTimeBits is the size of the millisecond timestamp of a ULID.
+	ulidTimeBits = 48
+	// ulidEntropySize is the number of random bytes of a ULID.
+	ulidEntropySize = 10
+	// base32Bits is the number of bits encoded by one base32 character.
+	base32Bits = 5
+	// crockford is the Crockford base32 alphabet used by ULIDs.
+	crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+	// base32Mask selects the bits of one base32 character.
+	base32Mask = 1<<base32Bits - 1
+)
+
+// NewIDGenerator returns an IDGenerator producing ULIDs: 26-character
+// Crockford base32 strings made of a 48-bit millisecond timestamp followed by
+// 80 random bits. IDs sort lexicographically by time, and IDs generated for the
+// same millisecond increase monotonically. It is safe for concurrent use.
+func NewIDGenerator() IDGenerator {
+	var (
+		mu      sync.Mutex
+		last    uint64
+		entropy [ulidEntropySize]byte
+	)
+	return func(t time.Time) string {
+		ms := uint64(max(t.UnixMilli(), 0)) //nolint:gosec // clamped to non-negative.
+
+		mu.Lock()
+		defer mu.Unlock()
+		if ms != last || !increment(entropy[:]) {
+			_, _ = rand.Read(entropy[:])
+			last = ms
+		}
+		return encodeULID(ms, entropy)
+	}
+}
+
+// increment adds one to the big-endian number b. It reports false on overflow.
+func increment(b []byte) bool {
+	for i := len(b) - 1; i >= 0; i-- {
+		b[i]++
+		if b[i] != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// encodeULID encodes the 128-bit value ms:entropy in Crockford base32.
+func encodeULID(ms uint64, entropy [ulidEntropySize]byte) string {
+	hi := ms<<(64-ulidTimeBits) | uint64(binary.BigEndian.Uint16(entropy[:2]))
+	lo := binary.BigEndian.Uint64(entropy[2:])
+
+	var out [ulidLen]byte
+	for i := range out {
+		shift := uint((ulidLen - 1 - i) * base32Bits)
+		out[i] = crockford[shift128(hi, lo, shift)&base32Mask]
+	}
+	return string(out[:])
+}
+
+// shift128 returns the low 64 bits of the 128-bit value hi:lo shifted right by n.
+func shift128(hi, lo uint64, n uint) uint64 {
+	switch {
+	case n == 0:
+		return lo
+	case n < 64:
+		return lo>>n | hi<<(64-n)
+	default:
+		return hi >> (n - 64)
+	}
+}
@@ -0,0 +1,120 @@
+package audit_test
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/w0rng/audit"
+	"github.com/w0rng/audit/internal/be"
+)
+
+func TestNewIDGenerator(t *testing.T) {
+	t.Parallel()
+	gen := audit.NewIDGenerator()
+	at := time.UnixMilli(1469918176385)
+
+	ids := make([]string, 1000)
+	for i := range ids {
+		ids[i] = gen(at)
+	}
+	for _, id := range ids {
+		be.Equal(t, len(id), 26)
+		be.Equal(t, id[:10], "01ARYZ6S41")
+		be.Equal(t, strings.Trim(id, "0123456789ABCDEFGHJKMNPQRSTVWXYZ"), "")
+	}
+	be.True(t, slices.IsSorted(ids))
+	be.Equal(t, len(slices.Compact(slices.Clone(ids))), len(ids))
+
+	later := gen(at.Add(time.Millisecond))
+	be.True(t, later > ids[len(ids)-1])
+	be.True(t, gen(at.Add(-time.Hour)) < ids[0])
+	be.True(t, strings.HasPrefix(gen(time.Unix(-1, 0)), "0000000000"))
+}
+
+func TestNewIDGenerator_Concurrent(t *testing.T) {
+	t.Parallel()
+	gen := audit.NewIDGenerator()
+	now := time.Now()
+
+	var (
+		mu   sync.Mutex
+		seen = make(map[string]bool)
+		wg   sync.WaitGroup
+	)
+	for range 8 {
+		wg.Go(func() {
+			for range 500 {
+				id := gen(now)
+				mu.Lock()
+				be.True(t, !seen[id])
+				seen[id] = true
+				mu.Unlock()
+			}
+		})
+	}
+	wg.Wait()
+	be.Equal(t, len(seen), 4000)
+}
+
+func TestWithClockAndIDGenerator(t *testing.T) {
+	t.Parallel()
+	at := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	counter := func() audit.IDGenerator {
+		n := 0
+		return func(ts time.Time) string {
+			n++
+			return fmt.Sprintf("%s-%d", ts.Format(time.DateOnly), n)
+		}
+	}
+	newLogger := func(gen audit.IDGenerator) *audit.Logger {
+		return audit.New(
+			audit.WithClock(func() time.Time { return at }),
+			audit.WithIDGenerator(gen),
+			audit.WithHashSalt([]byte("salt")),
+		)
+	}
+
+	logger := newLogger(counter())
+	logger.Create("order:1", "alice", "created", map[string]audit.Value{})
+	tx := logger.Begin(t.Context())
+	tx.Update("order:1", "bob", "paid", map[string]audit.Value{})
+	be.Err(t, tx.Commit(), nil)
+
+	events := logger.Events("order:1")
+	be.Equal(t, len(events), 2)
+	be.Equal(t, events[0].ID, "2024-01-02-1")
+	be.Equal(t, tx.ID(), "2024-01-02-2")
+	be.Equal(t, events[1].ID, "2024-01-02-3")
+	for _, e := range events {
+		be.True(t, e.Timestamp.Equal(at))
+	}
+	be.Err(t, logger.Verify("order:1"), nil)
+
+	// The ID is covered by the hash.
+	other := newLogger(func(time.Time) string { return "other" })
+	other.Create("order:1", "alice", "created", map[string]audit.Value{})
+	be.True(t, other.Events("order:1")[0].Hash != events[0].Hash)
+}
+
+func TestLogger_DefaultIDs(t *testing.T) {
+	t.Parallel()
+	logger := audit.New()
+	for range 3 {
+		logger.Update("order:1", "alice", "updated", map[string]audit.Value{})
+	}
+	be.Err(t, logger.LogBatch(t.Context(), []audit.LogEntry{
+		{Key: "order:1", Action: audit.ActionUpdate, Author: "alice"},
+	}), nil)
+
+	events := logger.Events("order:1")
+	ids := make([]string, len(events))
+	for i, e := range events {
+		be.Equal(t, len(e.ID), 26)
+		ids[i] = e.ID
+	}
+	be.True(t, slices.IsSorted(ids))
+}
@@ -0,0 +1,109 @@
+package audit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"iter"
+	"time"
+)
+
+// ErrImportOrder is returned by Import when an event is older than the event
+// before it under the same key.
+var ErrImportOrder = errors.New("audit: import out of order")
+
+// importBatchSize is the number of events Import seals and stores at a time.
+const importBatchSize = 256
+
+// Import appends historical events, for example ones migrated from another
+// audit system, keeping their IDs, timestamps, authors, actors and request and
+// transaction IDs. Events without an ID get one generated for their timestamp.
+// Unlike LogChange it never stamps the current time.
+//
+// Each event is sealed into its key's hash chain as it is written, so the
+// Sequence, PrevHash and Hash of the input are ignored. Hidden values carrying
+// data are digested as in LogChange.
+//
+// Events of a key must be in chronological order and no older than the last
+// event already stored under it; the first violation stops the import with
+// ErrImportOrder. Events of different keys may be interleaved freely.
+//
+// Events are stored in batches as they are read. When the input fails or an
+// event is invalid, the events before it are still stored. Events whose
+// idempotency key was already stored under their key are skipped. Import
+// returns the number of events stored.
+func (l *Logger) Import(ctx context.Context, events iter.Seq2[KeyedEvent, error]) (int, error) {
+	last := make(map[string]time.Time)
+	batch := make([]KeyedEvent, 0, importBatchSize)
+	stored := 0
+	flush := func() error {
+		n, err := l.storeEvents(ctx, batch, false)
+		if err != nil {
+			return err
+		}
+		stored += n
+		batch = make([]KeyedEvent, 0, importBatchSize)
+		return nil
+	}
+
+	for e, err := range events {
+		if err == nil {
+			err = ctx.Err()
+		}
+		if err == nil {
+			err = l.checkImport(ctx, last, e)
+		}
+		if err != nil {
+			if len(batch) > 0 {
+				err = errors.Join(err, flush())
+			}
+			return stored, err
+		}
+		last[e.Key] = e.Timestamp
+
+		if e.ID == "" {
+			e.ID = l.newID(e.Timestamp)
+		}
+		e.Payload = l.digestPayload(e.Payload)
+		batch = append(batch, e)
+		if len(batch) == importBatchSize {
+			if err = flush(); err != nil {
+				return stored, err
+			}
+		}
+	}
+	if len(batch) > 0 {
+		if err := flush(); err != nil {
+			return stored, err
+		}
+	}
+	return stored, nil
+}
+
+// checkImport validates e and checks that it is not older than the previous
+// event of its key. The first time a key is seen, its last stored event is
+// looked up and recorded in last.
+func (l *Logger) checkImport(ctx context.Context, last map[string]time.Time, e KeyedEvent) error {
+	switch {
+	case e.Key == "":
+		return errors.New("audit: import event has no key")
+	case e.Timestamp.IsZero():
+		return fmt.Errorf("audit: import event of %q has no timestamp", e.Key)
+	}
+
+	prev, seen := last[e.Key]
+	if !seen {
+		stored, err := l.storage.Get(ctx, e.Key)
+		if err != nil {
+			return err
+		}
+		if len(stored) > 0 {
+			prev = stored[len(stored)-1].Timestamp
+		}
+	}
+	if e.Timestamp.Before(prev) {
+		return fmt.Errorf("%w: event of %q at %s is older than %s",
+			ErrImportOrder, e.Key, e.Timestamp.Format(time.RFC3339Nano), prev.Format(time.RFC3339Nano))
+	}
+	return nil
+}
@@ -0,0 +1,138 @@
+package audit_test
+
+import (
+	"errors"
+	"iter"
+	"testing"
+	"time"
+
+	"github.com/w0rng/audit"
+	"github.com/w0rng/audit/internal/be"
+)
+
+// events yields events, then err if it is not nil.
+func events(err error, events ...audit.KeyedEvent) iter.Seq2[audit.KeyedEvent, error] {
+	return func(yield func(audit.KeyedEvent, error) bool) {
+		for _, e := range events {
+			if !yield(e, nil) {
+				return
+			}
+		}
+		if err != nil {
+			yield(audit.KeyedEvent{}, err)
+		}
+	}
+}
+
+func imported(key string, at time.Time, author string, payload map[string]audit.Value) audit.KeyedEvent {
+	return audit.KeyedEvent{Key: key, Event: audit.Event{
+		Timestamp: at, Action: audit.ActionUpdate, Author: author, Payload: payload,
+		Sequence: 42, Hash: "legacy",
+	}}
+}
+
+func TestLogger_Import(t *testing.T) {
+	t.Parallel()
+	base := time.Date(2019, 5, 1, 9, 0, 0, 0, time.UTC)
+	logger := audit.New()
+
+	n, err := logger.Import(t.Context(), events(nil,
+		imported("order:1", base, "alice", map[string]audit.Value{"status": audit.PlainValue("pending")}),
+		imported("order:2", base.Add(-time.Hour), "carol", nil),
+		imported("order:1", base.Add(time.Minute), "bob", map[string]audit.Value{"card": audit.HiddenValueOf("4111")}),
+		imported("order:1", base.Add(time.Minute), "bob", map[string]audit.Value{"status": audit.PlainValue("paid")}),
+	))
+	be.Err(t, err, nil)
+	be.Equal(t, n, 4)
+
+	stored := logger.Events("order:1")
+	be.Equal(t, len(stored), 3)
+	for i, e := range stored {
+		be.Equal(t, e.Sequence, uint64(i+1))
+	}
+	be.True(t, stored[0].Timestamp.Equal(base))
+	be.Equal(t, stored[0].Author, "alice")
+	be.Equal(t, stored[1].Author, "bob")
+	be.Equal(t, stored[1].Payload["card"].Data, nil)
+	be.True(t, stored[1].Payload["card"].Digest != "")
+	be.Err(t, logger.Verify("order:1"), nil)
+	be.Equal(t, len(logger.Events("order:2")), 1)
+
+	// Live events continue the imported chains.
+	logger.Update("order:1", "dave", "Order shipped", map[string]audit.Value{"status": audit.PlainValue("shipped")})
+	be.Equal(t, logger.Events("order:1")[3].Sequence, uint64(4))
+	be.Err(t, logger.Verify("order:1"), nil)
+}
+
+func TestLogger_Import_Batches(t *testing.T) {
+	t.Parallel()
+	base := time.Date(2019, 5, 1, 9, 0, 0, 0, time.UTC)
+	input := make([]audit.KeyedEvent, 1000)
+	for i := range input {
+		input[i] = imported("order:1", base.Add(time.Duration(i)*time.Second), "alice", nil)
+	}
+	logger := audit.New()
+
+	n, err := logger.Import(t.Context(), events(nil, input...))
+	be.Err(t, err, nil)
+	be.Equal(t, n, len(input))
+	be.Equal(t, len(logger.Events("order:1")), len(input))
+	be.Err(t, logger.Verify("order:1"), nil)
+}
+
+func TestLogger_Import_Errors(t *testing.T) {
+	t.Parallel()
+	base := time.Date(2019, 5, 1, 9, 0, 0, 0, time.UTC)
+	errSource := errors.New("source failed")
+	tests := []struct {
+		name   string
+		input  iter.Seq2[audit.KeyedEvent, error]
+		stored int
+		want   any
+	}{
+		{
+			name: "out of order",
+			input: events(nil,
+				imported("order:1", base, "alice", nil),
+				imported("order:2", base.Add(-time.Hour), "alice", nil),
+				imported("order:1", base.Add(-time.Second), "alice", nil),
+			),
+			stored: 2,
+			want:   audit.ErrImportOrder,
+		},
+		{
+			name:  "older than stored",
+			input: events(nil, imported("order:9", base, "alice", nil)),
+			want:  audit.ErrImportOrder,
+		},
+		{
+			name:  "no key",
+			input: events(nil, imported("", base, "alice", nil)),
+			want:  "no key",
+		},
+		{
+			name:  "no timestamp",
+			input: events(nil, imported("order:1", time.Time{}, "alice", nil)),
+			want:  "no timestamp",
+		},
+		{
+			name:   "source error",
+			input:  events(errSource, imported("order:1", base, "alice", nil)),
+			stored: 1,
+			want:   errSource,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			logger := audit.New()
+			logger.Create("order:9", "alice", "created now", map[string]audit.Value{})
+
+			n, err := logger.Import(t.Context(), tt.input)
+			be.Err(t, err, tt.want)
+			be.Equal(t, n, tt.stored)
+			be.Equal(t, len(logger.Events("order:1"))+len(logger.Events("order:2")), tt.stored)
+		})
+	}
+}
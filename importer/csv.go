@@ -0,0 +1,172 @@
+package importer
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"slices"
+	"strings"
+
+	"github.com/w0rng/audit"
+)
+
+// csvColumns maps the columns of a CSV export to their positions.
+type csvColumns struct {
+	index map[string]int
+	// event lists the positions of the columns shared by all rows of an
+	// event: every column except field and value.
+	event []int
+}
+
+// newCSVColumns reads the header row. The timestamp and action columns are
+// required; other known columns may be missing and unknown ones are ignored.
+func newCSVColumns(header []string) (csvColumns, error) {
+	cols := csvColumns{index: make(map[string]int, len(header))}
+	for i, name := range header {
+		cols.index[name] = i
+		if name != "field" && name != "value" {
+			cols.event = append(cols.event, i)
+		}
+	}
+	for _, name := range []string{"timestamp", "action"} {
+		if _, ok := cols.index[name]; !ok {
+			return csvColumns{}, fmt.Errorf("importer: csv header has no %q column", name)
+		}
+	}
+	return cols, nil
+}
+
+// get returns the cell of row in the named column, or "" if there is none.
+func (c csvColumns) get(row []string, name string) string {
+	if i, ok := c.index[name]; ok && i < len(row) {
+		return row[i]
+	}
+	return ""
+}
+
+// eventCells returns the cells of row that identify its event.
+func (c csvColumns) eventCells(row []string) []string {
+	cells := make([]string, len(c.event))
+	for i, col := range c.event {
+		if col < len(row) {
+			cells[i] = row[col]
+		}
+	}
+	return cells
+}
+
+// record returns the event columns of row.
+func (c csvColumns) record(row []string) record {
+	rec := record{
+		Key:            c.get(row, "key"),
+		ID:             c.get(row, "id"),
+		Timestamp:      c.get(row, "timestamp"),
+		Action:         audit.Action(c.get(row, "action")),
+		Author:         c.get(row, "author"),
+		RequestID:      c.get(row, "request_id"),
+		TxID:           c.get(row, "tx_id"),
+		IdempotencyKey: c.get(row, "idempotency_key"),
+		Description:    c.get(row, "description"),
+	}
+	if id := c.get(row, "actor"); id != "" {
+		rec.Actor = &audit.Actor{ID: id}
+	}
+	return rec
+}
+
+// readCSV decodes a CSV export. Consecutive rows with the same event columns
+// are the payload fields of one event; a repeated field starts a new event.
+// A row without a field is an event with an empty payload.
+func readCSV(r io.Reader, opts Options) iter.Seq2[audit.KeyedEvent, error] {
+	return func(yield func(audit.KeyedEvent, error) bool) {
+		cr := csv.NewReader(r)
+		cols, ok := readHeader(cr, yield)
+		if !ok {
+			return
+		}
+
+		var (
+			first   []string
+			line    int
+			cells   []string
+			payload map[string]audit.Value
+		)
+		emit := func() bool {
+			e, err := cols.record(first).event(payload, opts)
+			if err != nil {
+				yield(audit.KeyedEvent{}, fmt.Errorf("importer: line %d: %w", line, err))
+				return false
+			}
+			return yield(e, nil)
+		}
+
+		for {
+			row, err := cr.Read()
+			if errors.Is(err, io.EOF) {
+				if first != nil {
+					emit()
+				}
+				return
+			}
+			if err != nil {
+				yield(audit.KeyedEvent{}, fmt.Errorf("importer: %w", err))
+				return
+			}
+
+			field := cols.get(row, "field")
+			rowCells := cols.eventCells(row)
+			_, repeated := payload[field]
+			if first == nil || field == "" || len(payload) == 0 || repeated || !slices.Equal(rowCells, cells) {
+				if first != nil && !emit() {
+					return
+				}
+				first, cells, payload = row, rowCells, make(map[string]audit.Value)
+				line, _ = cr.FieldPos(0)
+			}
+			if field != "" {
+				payload[field] = value(parseCell(cols.get(row, "value")))
+			}
+		}
+	}
+}
+
+// readHeader reads the header row of cr. It reports false, after yielding
+// any error, if there are no events to read.
+func readHeader(cr *csv.Reader, yield func(audit.KeyedEvent, error) bool) (csvColumns, bool) {
+	header, err := cr.Read()
+	switch {
+	case errors.Is(err, io.EOF):
+		return csvColumns{}, false
+	case err != nil:
+		yield(audit.KeyedEvent{}, fmt.Errorf("importer: %w", err))
+		return csvColumns{}, false
+	}
+	cols, err := newCSVColumns(header)
+	if err != nil {
+		yield(audit.KeyedEvent{}, err)
+		return csvColumns{}, false
+	}
+	return cols, true
+}
+
+// parseCell decodes a cell holding JSON, such as a number or an object.
+// Numbers are read as json.Number, so large integers keep their exact value.
+// Any other cell is returned as a string.
+func parseCell(s string) any {
+	if s == "" {
+		return s
+	}
+	dec := json.NewDecoder(strings.NewReader(s))
+	dec.UseNumber()
+	var data any
+	if dec.Decode(&data) != nil {
+		return s
+	}
+	if _, err := dec.Token(); !errors.Is(err, io.EOF) {
+		return s
+	}
+	return data
+}
@@ -0,0 +1,129 @@
+// Package importer reads audit events in the formats written by package
+// export, so histories can be moved between stores or migrated from other
+// systems with Logger.Import.
+//
+// Exports lose some information, which an import cannot restore:
+//   - hidden values are read back as audit.HiddenValue, without data or digest
+//   - CSV cells holding valid JSON, such as 42, true or {"a":1}, are decoded;
+//     any other cell, including an empty one, is read as a string
+//   - numbers are read as json.Number, so large integers keep their exact value
+//   - hash chains are rebuilt by Logger.Import, so exported hashes are ignored
+//
+// Example:
+//
+//	f, err := os.Open("legacy.ndjson")
+//	if err != nil {
+//	    return err
+//	}
+//	defer f.Close()
+//	n, err := importer.Import(ctx, logger, f, export.NDJSON, importer.Options{})
+package importer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"time"
+
+	"github.com/w0rng/audit"
+	"github.com/w0rng/audit/export"
+)
+
+// Options configures a reader.
+type Options struct {
+	// TimeFormat is the layout of timestamps. Defaults to time.RFC3339Nano.
+	TimeFormat string
+
+	// Key is used for records that have no key, such as exports of a single
+	// entity written with an empty key.
+	Key string
+}
+
+func (o Options) parseTime(s string) (time.Time, error) {
+	layout := o.TimeFormat
+	if layout == "" {
+		layout = time.RFC3339Nano
+	}
+	t, err := time.Parse(layout, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("timestamp: %w", err)
+	}
+	return t, nil
+}
+
+// Events returns the events encoded in r in format, in input order. The
+// sequence stops after the first error, which is yielded with a zero event.
+// An unsupported format yields export.ErrUnknownFormat.
+func Events(r io.Reader, format export.Format, opts Options) iter.Seq2[audit.KeyedEvent, error] {
+	switch format {
+	case export.CSV:
+		return readCSV(r, opts)
+	case export.JSON, export.NDJSON:
+		return readJSON(r, format, opts)
+	default:
+		return func(yield func(audit.KeyedEvent, error) bool) {
+			yield(audit.KeyedEvent{}, fmt.Errorf("%w %q", export.ErrUnknownFormat, format))
+		}
+	}
+}
+
+// Import reads the events encoded in r in format and appends them to logger
+// with Logger.Import. It returns the number of events stored.
+func Import(ctx context.Context, logger *audit.Logger, r io.Reader, format export.Format, opts Options) (int, error) {
+	return logger.Import(ctx, Events(r, format, opts))
+}
+
+// errNoKey is returned for a record without a key when Options.Key is empty.
+var errNoKey = errors.New("record has no key")
+
+// record is the decoded form shared by the CSV and JSON readers.
+type record struct {
+	Key            string
+	ID             string
+	Timestamp      string
+	Action         audit.Action
+	Author         string
+	Actor          *audit.Actor
+	RequestID      string
+	TxID           string
+	IdempotencyKey string
+	Description    string
+}
+
+// event converts rec, with payload, to a keyed event.
+func (rec record) event(payload map[string]audit.Value, opts Options) (audit.KeyedEvent, error) {
+	key := rec.Key
+	if key == "" {
+		key = opts.Key
+	}
+	if key == "" {
+		return audit.KeyedEvent{}, errNoKey
+	}
+	ts, err := opts.parseTime(rec.Timestamp)
+	if err != nil {
+		return audit.KeyedEvent{}, err
+	}
+	return audit.KeyedEvent{Key: key, Event: audit.Event{
+		ID:             rec.ID,
+		Timestamp:      ts,
+		Action:         rec.Action,
+		Author:         rec.Author,
+		Actor:          rec.Actor,
+		RequestID:      rec.RequestID,
+		TxID:           rec.TxID,
+		Description:    rec.Description,
+		Payload:        payload,
+		IdempotencyKey: rec.IdempotencyKey,
+	}}, nil
+}
+
+// value converts exported payload data back to a Value. audit.HideText
+// marks a hidden value.
+func value(data any) audit.Value {
+	if s, ok := data.(string); ok && s == audit.HideText {
+		return audit.HiddenValue()
+	}
+	return audit.PlainValue(data)
+}
@@ -0,0 +1,198 @@
+package importer_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/w0rng/audit"
+	"github.com/w0rng/audit/export"
+	"github.com/w0rng/audit/importer"
+	"github.com/w0rng/audit/internal/be"
+)
+
+// source logs a small history to a new logger.
+func source(t *testing.T) *audit.Logger {
+	t.Helper()
+	ctx := t.Context()
+	logger := audit.New()
+	be.Err(t, logger.CreateContext(ctx, "order:1", "alice", "Order created", map[string]audit.Value{
+		"status": audit.PlainValue("pending"),
+		"total":  audit.PlainValue(100),
+		"card":   audit.HiddenValueOf("4111"),
+	}), nil)
+	be.Err(t, logger.CreateContext(ctx, "user:1", "admin", "User created", map[string]audit.Value{}), nil)
+	be.Err(t, logger.UpdateContext(audit.WithIdempotencyKey(ctx, "msg-1"), "order:1", "bob", "Order paid", map[string]audit.Value{
+		"status": audit.PlainValue("paid"),
+		"tags":   audit.PlainValue([]any{"vip"}),
+	}), nil)
+	be.Err(t, logger.DeleteContext(ctx, "order:1", "bob", "Order deleted", map[string]audit.Value{}), nil)
+	return logger
+}
+
+func TestImport_RoundTrip(t *testing.T) {
+	t.Parallel()
+	for _, format := range []export.Format{export.CSV, export.JSON, export.NDJSON} {
+		t.Run(string(format), func(t *testing.T) {
+			t.Parallel()
+			src := source(t)
+			all, err := src.Query(t.Context(), audit.Query{})
+			be.Err(t, err, nil)
+
+			var buf bytes.Buffer
+			w, err := export.NewEventWriter(&buf, format, export.Options{})
+			be.Err(t, err, nil)
+			for _, e := range all {
+				be.Err(t, w.Write(e.Key, e.Event), nil)
+			}
+			be.Err(t, w.Close(), nil)
+
+			dst := audit.New()
+			n, err := importer.Import(t.Context(), dst, &buf, format, importer.Options{})
+			be.Err(t, err, nil)
+			be.Equal(t, n, len(all))
+
+			for _, key := range []string{"order:1", "user:1"} {
+				want, got := src.Events(key), dst.Events(key)
+				be.Equal(t, len(got), len(want))
+				for i := range want {
+					be.True(t, got[i].Timestamp.Equal(want[i].Timestamp))
+					be.Equal(t, got[i].Action, want[i].Action)
+					be.Equal(t, got[i].Author, want[i].Author)
+					be.Equal(t, got[i].Description, want[i].Description)
+					be.Equal(t, got[i].Sequence, want[i].Sequence)
+					be.Equal(t, got[i].ID, want[i].ID)
+					be.Equal(t, got[i].IdempotencyKey, want[i].IdempotencyKey)
+				}
+				be.Err(t, dst.Verify(key), nil)
+			}
+
+			first := dst.Events("order:1")[0].Payload
+			be.Equal(t, len(first), 3)
+			be.True(t, audit.Equal(first["total"].Data, 100))
+			be.Equal(t, first["status"].Data, any("pending"))
+			be.Equal(t, first["card"], audit.HiddenValue())
+			be.True(t, audit.Equal(dst.Events("order:1")[1].Payload["tags"].Data, []any{"vip"}))
+			be.Equal(t, len(dst.Events("order:1")[2].Payload), 0)
+		})
+	}
+}
+
+func TestImport_LargeIntegers(t *testing.T) {
+	t.Parallel()
+	const big = int64(1<<53 + 1)
+	for _, format := range []export.Format{export.CSV, export.JSON, export.NDJSON} {
+		t.Run(string(format), func(t *testing.T) {
+			t.Parallel()
+			src := audit.New()
+			be.Err(t, src.CreateContext(t.Context(), "order:1", "alice", "Order created", map[string]audit.Value{
+				"id": audit.PlainValue(big),
+			}), nil)
+
+			var buf bytes.Buffer
+			be.Err(t, export.WriteEvents(&buf, format, "order:1", src.Events("order:1"), export.Options{}), nil)
+
+			dst := audit.New()
+			_, err := importer.Import(t.Context(), dst, &buf, format, importer.Options{})
+			be.Err(t, err, nil)
+			got := dst.Events("order:1")[0].Payload["id"].Data
+			be.Equal(t, got, any(json.Number("9007199254740993")))
+			be.True(t, audit.Equal(got, big))
+		})
+	}
+}
+
+func TestImport_IdempotencyKey(t *testing.T) {
+	t.Parallel()
+	for _, format := range []export.Format{export.CSV, export.JSON, export.NDJSON} {
+		t.Run(string(format), func(t *testing.T) {
+			t.Parallel()
+			src := audit.New()
+			ctx := audit.WithIdempotencyKey(t.Context(), "msg-1")
+			be.Err(t, src.CreateContext(ctx, "order:1", "alice", "Order created", map[string]audit.Value{}), nil)
+			var buf bytes.Buffer
+			be.Err(t, export.WriteEvents(&buf, format, "order:1", src.Events("order:1"), export.Options{}), nil)
+
+			// Importing the same export twice stores its events once.
+			dst := audit.New()
+			for _, want := range []int{1, 0} {
+				n, err := importer.Import(t.Context(), dst, bytes.NewReader(buf.Bytes()), format, importer.Options{})
+				be.Err(t, err, nil)
+				be.Equal(t, n, want)
+			}
+			be.Equal(t, dst.Events("order:1")[0].IdempotencyKey, "msg-1")
+		})
+	}
+}
+
+func TestEvents_CSV(t *testing.T) {
+	t.Parallel()
+	input := `timestamp,action,author,actor,field,value,extra
+2019-05-01 09:00,create,alice,u-1,status,pending,x
+2019-05-01 09:00,create,alice,u-1,status,paid,x
+2019-05-01 10:00,update,bob,,note,"{""a"":1}",x
+2019-05-01 10:00,update,bob,,,,x
+`
+	var got []audit.KeyedEvent
+	for e, err := range importer.Events(strings.NewReader(input), export.CSV, importer.Options{
+		TimeFormat: "2006-01-02 15:04",
+		Key:        "order:1",
+	}) {
+		be.Err(t, err, nil)
+		got = append(got, e)
+	}
+
+	be.Equal(t, len(got), 4)
+	be.Equal(t, got[0].Key, "order:1")
+	be.True(t, got[0].Timestamp.Equal(time.Date(2019, 5, 1, 9, 0, 0, 0, time.UTC)))
+	be.Equal(t, got[0].Actor, &audit.Actor{ID: "u-1"})
+	be.Equal(t, got[1].Payload["status"].Data, any("paid"))
+	be.Equal(t, got[2].Payload["note"].Data, any(map[string]any{"a": json.Number("1")}))
+	be.Equal(t, got[2].Actor, nil)
+	be.Equal(t, len(got[3].Payload), 0)
+}
+
+func TestEvents_Errors(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name   string
+		format export.Format
+		input  string
+		opts   importer.Options
+		want   any
+	}{
+		{"unknown format", "xml", "", importer.Options{}, export.ErrUnknownFormat},
+		{"csv missing column", export.CSV, "key,action\norder:1,create\n", importer.Options{}, `no "timestamp" column`},
+		{"csv bad timestamp", export.CSV, "key,timestamp,action\norder:1,yesterday,create\n", importer.Options{}, "line 2: timestamp"},
+		{"csv no key", export.CSV, "timestamp,action\n2019-05-01T09:00:00Z,create\n", importer.Options{}, "no key"},
+		{"ndjson malformed", export.NDJSON, `{"key":"order:1",` + "\n", importer.Options{}, "record 1"},
+		{"ndjson no key", export.NDJSON, `{"timestamp":"2019-05-01T09:00:00Z"}` + "\n", importer.Options{}, "no key"},
+		{"json not an array", export.JSON, `{}`, importer.Options{}, "expected"},
+		{"json unterminated", export.JSON, `[{"key":"k","timestamp":"2019-05-01T09:00:00Z"}`, importer.Options{}, "record 2"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			var last error
+			for _, err := range importer.Events(strings.NewReader(tt.input), tt.format, tt.opts) {
+				last = err
+			}
+			be.Err(t, last, tt.want)
+		})
+	}
+}
+
+func TestEvents_Empty(t *testing.T) {
+	t.Parallel()
+	for _, tt := range []struct {
+		format export.Format
+		input  string
+	}{{export.CSV, ""}, {export.CSV, "key,timestamp,action\n"}, {export.JSON, "[]"}, {export.NDJSON, ""}} {
+		for _, err := range importer.Events(strings.NewReader(tt.input), tt.format, importer.Options{}) {
+			t.Fatalf("%s: unexpected event, error %v", tt.format, err)
+		}
+	}
+}
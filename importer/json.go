@@ -0,0 +1,102 @@
+package importer
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+
+	"github.com/w0rng/audit"
+	"github.com/w0rng/audit/export"
+)
+
+// jsonRecord is the JSON form of an event written by export.EventWriter.
+type jsonRecord struct {
+	Key            string         `json:"key"`
+	ID             string         `json:"id"`
+	Timestamp      string         `json:"timestamp"`
+	Action         audit.Action   `json:"action"`
+	Author         string         `json:"author"`
+	Actor          *audit.Actor   `json:"actor"`
+	RequestID      string         `json:"request_id"`
+	TxID           string         `json:"tx_id"`
+	IdempotencyKey string         `json:"idempotency_key"`
+	Description    string         `json:"description"`
+	Payload        map[string]any `json:"payload"`
+}
+
+// readJSON decodes an indented JSON array or a stream of NDJSON records.
+func readJSON(r io.Reader, format export.Format, opts Options) iter.Seq2[audit.KeyedEvent, error] {
+	return func(yield func(audit.KeyedEvent, error) bool) {
+		dec := json.NewDecoder(r)
+		// Numbers keep their exact text, so large integers survive a round trip.
+		dec.UseNumber()
+		if format == export.JSON {
+			if err := expectDelim(dec, '['); err != nil {
+				yield(audit.KeyedEvent{}, err)
+				return
+			}
+		}
+
+		for n := 1; ; n++ {
+			if format == export.JSON && !dec.More() {
+				if err := expectDelim(dec, ']'); err != nil {
+					yield(audit.KeyedEvent{}, err)
+				}
+				return
+			}
+
+			var rec jsonRecord
+			err := dec.Decode(&rec)
+			if format == export.NDJSON && errors.Is(err, io.EOF) {
+				return
+			}
+			if err != nil {
+				yield(audit.KeyedEvent{}, fmt.Errorf("importer: record %d: %w", n, err))
+				return
+			}
+
+			e, err := rec.event(opts)
+			if err != nil {
+				yield(audit.KeyedEvent{}, fmt.Errorf("importer: record %d: %w", n, err))
+				return
+			}
+			if !yield(e, nil) {
+				return
+			}
+		}
+	}
+}
+
+// event converts rec to a keyed event.
+func (rec jsonRecord) event(opts Options) (audit.KeyedEvent, error) {
+	payload := make(map[string]audit.Value, len(rec.Payload))
+	for field, data := range rec.Payload {
+		payload[field] = value(data)
+	}
+	return record{
+		Key:            rec.Key,
+		ID:             rec.ID,
+		Timestamp:      rec.Timestamp,
+		Action:         rec.Action,
+		Author:         rec.Author,
+		Actor:          rec.Actor,
+		RequestID:      rec.RequestID,
+		TxID:           rec.TxID,
+		IdempotencyKey: rec.IdempotencyKey,
+		Description:    rec.Description,
+	}.event(payload, opts)
+}
+
+// expectDelim reads the next token of dec and checks that it is want.
+func expectDelim(dec *json.Decoder, want json.Delim) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return fmt.Errorf("importer: %w", err)
+	}
+	if tok != want {
+		return fmt.Errorf("importer: expected %q, got %v", want, tok)
+	}
+	return nil
+}
@@ -0,0 +1,190 @@
+// Package cli implements the audit command-line tool.
+package cli
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	_ "modernc.org/sqlite" // registers the "sqlite" driver for SQL stores.
+
+	"github.com/w0rng/audit"
+	"github.com/w0rng/audit/sqlstore"
+)
+
+// Exit codes returned by Run.
+const (
+	ExitOK      = 0
+	ExitFailure = 1
+	ExitUsage   = 2
+)
+
+// errUsage marks errors caused by invalid arguments.
+var errUsage = errors.New("invalid arguments")
+
+// usage is printed for invalid invocations.
+const usage = `usage: audit [-store path] [-driver jsonl|sqlite] <command> [flags] [args]
+
+commands:
+  events [-field name]... [-json] <key>            print the events of an entity
+  log <key>                                        print the change history of an entity
+  keys [-prefix p]                                 list entity keys
+  export [-format csv|json|ndjson] [-prefix p]     write events to stdout
+  import [-format csv|json|ndjson] <file>          append the events of an export
+  verify [key]...                                  check hash chains, of all entities by default
+
+Only import writes to the store, creating it if needed. The other commands
+require an existing store and open it read-only. PostgreSQL stores are not
+supported; export them with the sqlstore package instead.
+`
+
+// env is the state shared by commands.
+type env struct {
+	logger *audit.Logger
+	stdout io.Writer
+}
+
+// command runs a subcommand with its arguments.
+type command func(ctx context.Context, e env, args []string) error
+
+// commands maps subcommand names to their implementations.
+func commands() map[string]command {
+	return map[string]command{
+		"events": runEvents,
+		"log":    runLog,
+		"keys":   runKeys,
+		"export": runExport,
+		"import": runImport,
+		"verify": runVerify,
+	}
+}
+
+// writes reports whether the named command modifies the store.
+func writes(name string) bool {
+	return name == "import"
+}
+
+// Run executes the command line args and returns the process exit code.
+func Run(ctx context.Context, args []string, stdout, stderr io.Writer) int {
+	global := flag.NewFlagSet("audit", flag.ContinueOnError)
+	global.SetOutput(stderr)
+	global.Usage = func() { _, _ = fmt.Fprint(stderr, usage) }
+	path := global.String("store", "audit.jsonl", "path of the audit store")
+	driver := global.String("driver", "", "store type: jsonl or sqlite (default: from the file extension)")
+	if err := global.Parse(args); err != nil {
+		return ExitUsage
+	}
+
+	cmd, ok := commands()[global.Arg(0)]
+	if !ok {
+		if name := global.Arg(0); name != "" {
+			_, _ = fmt.Fprintf(stderr, "audit: unknown command %q\n", name)
+		}
+		global.Usage()
+		return ExitUsage
+	}
+
+	storage, closeStorage, err := open(ctx, *path, *driver, !writes(global.Arg(0)))
+	if err != nil {
+		_, _ = fmt.Fprintln(stderr, "audit:", err)
+		return ExitFailure
+	}
+	defer closeStorage()
+
+	err = cmd(ctx, env{logger: audit.New(audit.WithStorageV2(storage)), stdout: stdout}, global.Args()[1:])
+	switch {
+	case err == nil:
+		return ExitOK
+	case errors.Is(err, flag.ErrHelp):
+		_, _ = fmt.Fprint(stderr, usage)
+		return ExitUsage
+	case errors.Is(err, errUsage):
+		_, _ = fmt.Fprintln(stderr, "audit:", err)
+		_, _ = fmt.Fprint(stderr, usage)
+		return ExitUsage
+	default:
+		_, _ = fmt.Fprintln(stderr, "audit:", err)
+		return ExitFailure
+	}
+}
+
+// open opens the store at path. An empty driver is chosen by file extension.
+// A read-only store must exist and is neither migrated nor repaired.
+func open(ctx context.Context, path, driver string, readOnly bool) (audit.StorageV2, func(), error) {
+	if readOnly {
+		if _, err := os.Stat(path); err != nil {
+			return nil, nil, err
+		}
+	}
+	if driver == "" {
+		driver = "jsonl"
+		switch strings.ToLower(filepath.Ext(path)) {
+		case ".db", ".sqlite", ".sqlite3":
+			driver = "sqlite"
+		}
+	}
+
+	switch driver {
+	case "jsonl":
+		storage, err := audit.OpenFileStorage(path, audit.FileStorageOptions{ReadOnly: readOnly})
+		if err != nil {
+			return nil, nil, err
+		}
+		return storage, func() { _ = storage.Close() }, nil
+	case "sqlite":
+		dsn, err := sqliteDSN(path, readOnly)
+		if err != nil {
+			return nil, nil, err
+		}
+		db, err := sql.Open("sqlite", dsn)
+		if err != nil {
+			return nil, nil, fmt.Errorf("open %s: %w", path, err)
+		}
+		store, err := sqlstore.New(ctx, db, sqlstore.Options{Dialect: sqlstore.SQLite, SkipMigrations: readOnly})
+		if err != nil {
+			_ = db.Close()
+			return nil, nil, err
+		}
+		return store, func() { _ = db.Close() }, nil
+	default:
+		return nil, nil, fmt.Errorf("unknown driver %q", driver)
+	}
+}
+
+// sqliteDSN returns the data source name of the SQLite database at path.
+// A read-only database is opened through a URI with mode=ro.
+func sqliteDSN(path string, readOnly bool) (string, error) {
+	if !readOnly {
+		return path, nil
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	// Windows paths start with a drive letter, which SQLite expects after a slash.
+	uriPath := filepath.ToSlash(abs)
+	if !strings.HasPrefix(uriPath, "/") {
+		uriPath = "/" + uriPath
+	}
+	return (&url.URL{Scheme: "file", Path: uriPath, RawQuery: "mode=ro"}).String(), nil
+}
+
+// parse parses the flags of a subcommand and returns its positional arguments.
+// Errors are reported by Run, so the flag set prints nothing itself.
+func parse(fs *flag.FlagSet, args []string, nargs int) ([]string, error) {
+	fs.SetOutput(io.Discard)
+	if err := fs.Parse(args); err != nil {
+		return nil, fmt.Errorf("%w: %w", errUsage, err)
+	}
+	if nargs >= 0 && fs.NArg() != nargs {
+		return nil, fmt.Errorf("%w: %s takes %d argument(s)", errUsage, fs.Name(), nargs)
+	}
+	return fs.Args(), nil
+}
@@ -0,0 +1,240 @@
+package cli_test
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/w0rng/audit"
+	"github.com/w0rng/audit/internal/be"
+	"github.com/w0rng/audit/internal/cli"
+	"github.com/w0rng/audit/sqlstore"
+)
+
+// seed logs a small history to logger.
+func seed(t *testing.T, logger *audit.Logger) {
+	t.Helper()
+	ctx := t.Context()
+	be.Err(t, logger.CreateContext(ctx, "order:1", "alice", "Order created", map[string]audit.Value{
+		"status": audit.PlainValue("pending"),
+		"token":  audit.HiddenValue(),
+	}), nil)
+	be.Err(t, logger.UpdateContext(ctx, "order:1", "bob", "Order paid", map[string]audit.Value{
+		"status": audit.PlainValue("paid"),
+	}), nil)
+	be.Err(t, logger.CreateContext(ctx, "user:1", "admin", "User created", map[string]audit.Value{}), nil)
+}
+
+func seedFile(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	storage, err := audit.OpenFileStorage(path, audit.FileStorageOptions{})
+	be.Err(t, err, nil)
+	seed(t, audit.New(audit.WithStorageV2(storage)))
+	be.Err(t, storage.Close(), nil)
+	return path
+}
+
+func run(t *testing.T, args ...string) (int, string, string) {
+	t.Helper()
+	var stdout, stderr bytes.Buffer
+	code := cli.Run(t.Context(), args, &stdout, &stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+func TestRun_Log(t *testing.T) {
+	t.Parallel()
+	path := seedFile(t)
+
+	code, out, _ := run(t, "-store", path, "log", "order:1")
+	be.Equal(t, code, cli.ExitOK)
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	be.Equal(t, len(lines), 5)
+	be.True(t, strings.HasSuffix(lines[0], "alice  Order created"))
+	be.Equal(t, lines[1], `    status: - -> "pending"`)
+	be.Equal(t, lines[2], `    token: "***" -> "***"`)
+	be.Equal(t, lines[4], `    status: "pending" -> "paid"`)
+}
+
+func TestRun_Events(t *testing.T) {
+	t.Parallel()
+	path := seedFile(t)
+
+	code, out, _ := run(t, "-store", path, "events", "order:1")
+	be.Equal(t, code, cli.ExitOK)
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	be.Equal(t, len(lines), 3)
+	be.True(t, strings.HasPrefix(lines[0], "SEQ"))
+	be.True(t, strings.Contains(lines[1], `status="pending" token=***`))
+
+	code, out, _ = run(t, "-store", path, "events", "-json", "-field", "token", "order:1")
+	be.Equal(t, code, cli.ExitOK)
+	var events []audit.Event
+	be.Err(t, json.Unmarshal([]byte(out), &events), nil)
+	be.Equal(t, len(events), 1)
+	be.Equal(t, events[0].Author, "alice")
+}
+
+func TestRun_KeysExportVerify(t *testing.T) {
+	t.Parallel()
+	path := seedFile(t)
+
+	code, out, _ := run(t, "-store", path, "keys")
+	be.Equal(t, code, cli.ExitOK)
+	be.Equal(t, out, "order:1\nuser:1\n")
+	_, out, _ = run(t, "-store", path, "keys", "-prefix", "user:")
+	be.Equal(t, out, "user:1\n")
+
+	code, out, _ = run(t, "-store", path, "export", "-format", "csv", "-prefix", "order:")
+	be.Equal(t, code, cli.ExitOK)
+	records, err := csv.NewReader(strings.NewReader(out)).ReadAll()
+	be.Err(t, err, nil)
+	be.Equal(t, len(records), 4)
+	be.Equal(t, records[0][0], "key")
+	be.Equal(t, records[1][0], "order:1")
+	be.Equal(t, records[1][2], "1")
+	be.Equal(t, records[2][11:], []string{"token", audit.HideText})
+
+	code, out, _ = run(t, "-store", path, "export")
+	be.Equal(t, code, cli.ExitOK)
+	var events []map[string]any
+	be.Err(t, json.Unmarshal([]byte(out), &events), nil)
+	be.Equal(t, len(events), 3)
+
+	code, out, _ = run(t, "-store", path, "export", "-format", "ndjson", "-prefix", "user:")
+	be.Equal(t, code, cli.ExitOK)
+	be.Equal(t, strings.Count(out, "\n"), 1)
+
+	code, out, _ = run(t, "-store", path, "verify")
+	be.Equal(t, code, cli.ExitOK)
+	be.Equal(t, out, "ok    order:1\nok    user:1\n")
+}
+
+func TestRun_VerifyTampered(t *testing.T) {
+	t.Parallel()
+	path := seedFile(t)
+	data, err := os.ReadFile(path)
+	be.Err(t, err, nil)
+	be.Err(t, os.WriteFile(path, bytes.Replace(data, []byte(`"paid"`), []byte(`"free"`), 1), 0o600), nil)
+
+	code, out, stderr := run(t, "-store", path, "verify", "order:1", "user:1")
+	be.Equal(t, code, cli.ExitFailure)
+	be.True(t, strings.HasPrefix(out, "FAIL  order:1: "))
+	be.True(t, strings.Contains(out, "ok    user:1"))
+	be.Equal(t, stderr, "audit: 1 of 2 entities failed verification\n")
+}
+
+func TestRun_SQLite(t *testing.T) {
+	t.Parallel()
+	path := filepath.Join(t.TempDir(), "audit.db")
+	db, err := sql.Open("sqlite", path)
+	be.Err(t, err, nil)
+	store, err := sqlstore.New(t.Context(), db, sqlstore.Options{Dialect: sqlstore.SQLite})
+	be.Err(t, err, nil)
+	seed(t, audit.New(audit.WithStorageV2(store)))
+	be.Err(t, db.Close(), nil)
+
+	code, out, _ := run(t, "-store", path, "keys", "-prefix", "order:")
+	be.Equal(t, code, cli.ExitOK)
+	be.Equal(t, out, "order:1\n")
+
+	code, out, _ = run(t, "-store", path, "verify")
+	be.Equal(t, code, cli.ExitOK)
+	be.Equal(t, out, "ok    order:1\nok    user:1\n")
+}
+
+func TestRun_ReadOnly(t *testing.T) {
+	t.Parallel()
+
+	// A mistyped path is an error and creates nothing.
+	missing := filepath.Join(t.TempDir(), "typo.jsonl")
+	code, _, stderr := run(t, "-store", missing, "verify")
+	be.Equal(t, code, cli.ExitFailure)
+	be.True(t, strings.Contains(stderr, "no such file"))
+	_, err := os.Stat(missing)
+	be.True(t, os.IsNotExist(err))
+
+	// A torn trailing record is ignored but left in place.
+	path := seedFile(t)
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0o600)
+	be.Err(t, err, nil)
+	_, err = f.WriteString(`{"key":"order:1","ev`)
+	be.Err(t, err, nil)
+	be.Err(t, f.Close(), nil)
+	before, err := os.ReadFile(path)
+	be.Err(t, err, nil)
+	code, out, _ := run(t, "-store", path, "verify")
+	be.Equal(t, code, cli.ExitOK)
+	be.Equal(t, out, "ok    order:1\nok    user:1\n")
+	after, err := os.ReadFile(path)
+	be.Err(t, err, nil)
+	be.Equal(t, string(after), string(before))
+
+	// A SQLite database is not migrated.
+	dbPath := filepath.Join(t.TempDir(), "other.db")
+	db, err := sql.Open("sqlite", dbPath)
+	be.Err(t, err, nil)
+	_, err = db.ExecContext(t.Context(), "CREATE TABLE unrelated (id INTEGER)")
+	be.Err(t, err, nil)
+	code, _, _ = run(t, "-store", dbPath, "keys")
+	be.Equal(t, code, cli.ExitFailure)
+	var tables int
+	be.Err(t, db.QueryRowContext(t.Context(), "SELECT count(*) FROM sqlite_master WHERE type = 'table'").Scan(&tables), nil)
+	be.Equal(t, tables, 1)
+	be.Err(t, db.Close(), nil)
+}
+
+func TestRun_Import(t *testing.T) {
+	t.Parallel()
+	path := seedFile(t)
+	code, out, _ := run(t, "-store", path, "export", "-format", "csv")
+	be.Equal(t, code, cli.ExitOK)
+	dump := filepath.Join(t.TempDir(), "dump.csv")
+	be.Err(t, os.WriteFile(dump, []byte(out), 0o600), nil)
+
+	target := filepath.Join(t.TempDir(), "copy.jsonl")
+	code, out, _ = run(t, "-store", target, "import", "-format", "csv", dump)
+	be.Equal(t, code, cli.ExitOK)
+	be.Equal(t, out, "imported 3 events\n")
+
+	_, out, _ = run(t, "-store", target, "verify")
+	be.Equal(t, out, "ok    order:1\nok    user:1\n")
+
+	// Importing again would put events before the ones just imported.
+	code, _, stderr := run(t, "-store", target, "import", "-format", "csv", dump)
+	be.Equal(t, code, cli.ExitFailure)
+	be.True(t, strings.Contains(stderr, "out of order"))
+}
+
+func TestRun_Usage(t *testing.T) {
+	t.Parallel()
+	path := seedFile(t)
+	tests := []struct {
+		name string
+		args []string
+		code int
+		want string
+	}{
+		{"no command", nil, cli.ExitUsage, "usage:"},
+		{"unknown command", []string{"-store", path, "frobnicate"}, cli.ExitUsage, `unknown command "frobnicate"`},
+		{"missing key", []string{"-store", path, "log"}, cli.ExitUsage, "log takes 1 argument(s)"},
+		{"unknown flag", []string{"-store", path, "keys", "-bogus"}, cli.ExitUsage, "-bogus"},
+		{"unknown format", []string{"-store", path, "export", "-format", "xml"}, cli.ExitUsage, `unknown format "xml"`},
+		{"unknown import format", []string{"-store", path, "import", "-format", "xml", path}, cli.ExitUsage, `unknown format "xml"`},
+		{"unknown driver", []string{"-store", path, "-driver", "redis", "keys"}, cli.ExitFailure, "redis"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			code, _, stderr := run(t, tt.args...)
+			be.Equal(t, code, tt.code)
+			be.True(t, strings.Contains(stderr, tt.want))
+			be.True(t, tt.code != cli.ExitUsage || strings.Contains(stderr, "usage:"))
+		})
+	}
+}
@@ -0,0 +1,240 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/w0rng/audit"
+	"github.com/w0rng/audit/export"
+	"github.com/w0rng/audit/importer"
+)
+
+// keysPageSize is the number of keys read per KeysPage call.
+const keysPageSize = 1000
+
+// stringList is a repeatable string flag.
+type stringList []string
+
+func (s *stringList) String() string { return strings.Join(*s, ",") }
+
+func (s *stringList) Set(v string) error {
+	*s = append(*s, v)
+	return nil
+}
+
+func runEvents(ctx context.Context, e env, args []string) error {
+	fs := flag.NewFlagSet("events", flag.ContinueOnError)
+	var fields stringList
+	fs.Var(&fields, "field", "only show events with this payload field (repeatable)")
+	asJSON := fs.Bool("json", false, "print events as JSON")
+	args, err := parse(fs, args, 1)
+	if err != nil {
+		return err
+	}
+
+	events, err := e.logger.EventsContext(ctx, args[0], fields...)
+	if err != nil {
+		return err
+	}
+	if *asJSON {
+		enc := json.NewEncoder(e.stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(events)
+	}
+
+	w := tabwriter.NewWriter(e.stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "SEQ\tTIME\tACTION\tAUTHOR\tDESCRIPTION\tPAYLOAD")
+	for _, event := range events {
+		_, _ = fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s\n", event.Sequence, event.Timestamp.Format(time.RFC3339),
+			event.Action, event.Author, event.Description, formatPayload(event.Payload))
+	}
+	return w.Flush()
+}
+
+func runLog(ctx context.Context, e env, args []string) error {
+	args, err := parse(flag.NewFlagSet("log", flag.ContinueOnError), args, 1)
+	if err != nil {
+		return err
+	}
+
+	changes, err := e.logger.LogsContext(ctx, args[0])
+	if err != nil {
+		return err
+	}
+	for _, change := range changes {
+		_, _ = fmt.Fprintf(e.stdout, "%s  %s  %s\n", change.Timestamp.Format(time.RFC3339), change.Author, change.Description)
+		for _, field := range change.Fields {
+			_, _ = fmt.Fprintf(e.stdout, "    %s: %s -> %s\n", field.Field, formatValue(field.From), formatValue(field.To))
+		}
+	}
+	return nil
+}
+
+func runKeys(ctx context.Context, e env, args []string) error {
+	fs := flag.NewFlagSet("keys", flag.ContinueOnError)
+	prefix := fs.String("prefix", "", "only list keys starting with prefix")
+	if _, err := parse(fs, args, 0); err != nil {
+		return err
+	}
+
+	keys, err := listKeys(ctx, e.logger, *prefix)
+	if err != nil {
+		return err
+	}
+	for _, key := range keys {
+		_, _ = fmt.Fprintln(e.stdout, key)
+	}
+	return nil
+}
+
+func runExport(ctx context.Context, e env, args []string) error {
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	format := fs.String("format", "json", "output format: csv, json or ndjson")
+	prefix := fs.String("prefix", "", "only export keys starting with prefix")
+	if _, err := parse(fs, args, 0); err != nil {
+		return err
+	}
+
+	w, err := export.NewEventWriter(e.stdout, export.Format(*format), export.Options{})
+	if err != nil {
+		return fmt.Errorf("%w: %w", errUsage, err)
+	}
+	events, err := e.logger.Query(ctx, audit.Query{KeyPrefix: *prefix})
+	if err != nil {
+		return err
+	}
+	for _, event := range events {
+		if err := w.Write(event.Key, event.Event); err != nil {
+			return err
+		}
+	}
+	return w.Close()
+}
+
+func runImport(ctx context.Context, e env, args []string) error {
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	format := fs.String("format", "json", "input format: csv, json or ndjson")
+	args, err := parse(fs, args, 1)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Open(args[0])
+	if err != nil {
+		return err
+	}
+	defer func() { _ = f.Close() }()
+
+	n, err := importer.Import(ctx, e.logger, f, export.Format(*format), importer.Options{})
+	if errors.Is(err, export.ErrUnknownFormat) {
+		return fmt.Errorf("%w: %w", errUsage, err)
+	}
+	if err != nil {
+		return fmt.Errorf("imported %d events: %w", n, err)
+	}
+	_, _ = fmt.Fprintf(e.stdout, "imported %d events\n", n)
+	return nil
+}
+
+func runVerify(ctx context.Context, e env, args []string) error {
+	keys, err := parse(flag.NewFlagSet("verify", flag.ContinueOnError), args, -1)
+	if err != nil {
+		return err
+	}
+	if len(keys) == 0 {
+		if keys, err = listKeys(ctx, e.logger, ""); err != nil {
+			return err
+		}
+	}
+
+	failed := 0
+	for _, key := range keys {
+		err := e.logger.VerifyContext(ctx, key)
+		switch {
+		case err == nil:
+			_, _ = fmt.Fprintf(e.stdout, "ok    %s\n", key)
+		case errors.Is(err, audit.ErrChainBroken):
+			failed++
+			_, _ = fmt.Fprintf(e.stdout, "FAIL  %s: %v\n", key, err)
+		default:
+			return err
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d entities failed verification", failed, len(keys))
+	}
+	return nil
+}
+
+// listKeys returns the keys starting with prefix in sorted order. Storages
+// without a KeyLister are scanned with a query.
+func listKeys(ctx context.Context, logger *audit.Logger, prefix string) ([]string, error) {
+	var keys []string
+	cursor := ""
+	for {
+		page, next, err := logger.KeysPage(ctx, prefix, cursor, keysPageSize)
+		if errors.Is(err, audit.ErrQueryUnsupported) {
+			return queryKeys(ctx, logger, prefix)
+		}
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, page...)
+		if next == "" {
+			return keys, nil
+		}
+		cursor = next
+	}
+}
+
+func queryKeys(ctx context.Context, logger *audit.Logger, prefix string) ([]string, error) {
+	events, err := logger.Query(ctx, audit.Query{KeyPrefix: prefix})
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[string]struct{})
+	for _, e := range events {
+		seen[e.Key] = struct{}{}
+	}
+	return slices.Sorted(maps.Keys(seen)), nil
+}
+
+// formatPayload renders a payload as sorted key=value pairs.
+func formatPayload(payload map[string]audit.Value) string {
+	parts := make([]string, 0, len(payload))
+	for _, field := range slices.Sorted(maps.Keys(payload)) {
+		v := payload[field]
+		if v.Hidden {
+			parts = append(parts, field+"="+audit.HideText)
+			continue
+		}
+		parts = append(parts, field+"="+formatValue(v.Data))
+	}
+	return strings.Join(parts, " ")
+}
+
+// formatValue renders a field value; absent values are shown as "-".
+func formatValue(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return "-"
+	case string:
+		return strconv.Quote(v)
+	default:
+		data, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Sprint(v)
+		}
+		return string(data)
+	}
+}
@@ -0,0 +1,94 @@
+package audit
+
+import (
+	"context"
+	"fmt"
+	"iter"
+	"slices"
+	"strings"
+)
+
+// KeyLister is an optional interface for storages that can enumerate the keys
+// they hold events for. Keys are listed in ascending byte order.
+type KeyLister interface {
+	// Keys iterates over the keys starting with prefix. An empty prefix lists every key.
+	Keys(prefix string) iter.Seq[string]
+
+	// KeysPage returns up to limit keys starting with prefix that sort after
+	// cursor, and the cursor of the next page, which is empty on the last page.
+	// Pass an empty cursor for the first page. A limit <= 0 returns all remaining keys.
+	KeysPage(ctx context.Context, prefix, cursor string, limit int) (keys []string, next string, err error)
+}
+
+// Keys iterates over a snapshot of the keys starting with prefix.
+// It implements KeyLister.
+func (s *InMemoryStorage) Keys(prefix string) iter.Seq[string] {
+	return func(yield func(string) bool) {
+		keys, _ := s.page(prefix, "", 0)
+		for _, key := range keys {
+			if !yield(key) {
+				return
+			}
+		}
+	}
+}
+
+// KeysPage returns a page of keys starting with prefix. It implements KeyLister.
+func (s *InMemoryStorage) KeysPage(ctx context.Context, prefix, cursor string, limit int) ([]string, string, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, "", err
+	}
+	keys, next := s.page(prefix, cursor, limit)
+	return keys, next, nil
+}
+
+func (s *InMemoryStorage) page(prefix, cursor string, limit int) ([]string, string) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return pageKeys(s.keys, prefix, cursor, limit)
+}
+
+// pageKeys returns a copy of up to limit keys from sorted that start with
+// prefix and sort after cursor, and the cursor of the next page.
+func pageKeys(sorted []string, prefix, cursor string, limit int) ([]string, string) {
+	start, _ := slices.BinarySearch(sorted, max(prefix, cursor))
+	if start < len(sorted) && sorted[start] == cursor {
+		start++
+	}
+
+	end := start
+	for end < len(sorted) && strings.HasPrefix(sorted[end], prefix) {
+		end++
+	}
+	if limit <= 0 || end-start <= limit {
+		return slices.Clone(sorted[start:end]), ""
+	}
+
+	page := slices.Clone(sorted[start : start+limit])
+	return page, page[len(page)-1]
+}
+
+// KeysPage returns a page of keys starting with prefix from the storage.
+// It returns ErrQueryUnsupported if the storage does not implement KeyLister.
+//
+// Example:
+//
+//	var cursor string
+//	for {
+//	    keys, next, err := logger.KeysPage(ctx, "order:", cursor, 100)
+//	    if err != nil {
+//	        return err
+//	    }
+//	    render(keys)
+//	    if next == "" {
+//	        break
+//	    }
+//	    cursor = next
+//	}
+func (l *Logger) KeysPage(ctx context.Context, prefix, cursor string, limit int) ([]string, string, error) {
+	lister, ok := capability[KeyLister](l.storage)
+	if !ok {
+		return nil, "", fmt.Errorf("%w: storage cannot list keys", ErrQueryUnsupported)
+	}
+	return lister.KeysPage(ctx, prefix, cursor, limit)
+}
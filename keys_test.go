@@ -0,0 +1,91 @@
+package audit_test
+
+import (
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/w0rng/audit"
+	"github.com/w0rng/audit/internal/be"
+)
+
+func TestKeyLister(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name    string
+		storage func(t *testing.T) (audit.StorageV2, audit.KeyLister)
+	}{
+		{"in memory", func(*testing.T) (audit.StorageV2, audit.KeyLister) {
+			s := audit.NewInMemoryStorage()
+			return audit.AdaptStorage(s), s
+		}},
+		{"file", func(t *testing.T) (audit.StorageV2, audit.KeyLister) {
+			s := openFileStorage(t, filepath.Join(t.TempDir(), "audit.jsonl"), audit.FileStorageOptions{})
+			return s, s
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			storage, lister := tt.storage(t)
+			logger := audit.New(audit.WithStorageV2(storage))
+			ctx := t.Context()
+			for _, key := range []string{"order:3", "user:1", "order:1", "order:2", "order:10", "orders", "order:1"} {
+				be.Err(t, storage.Store(ctx, key, audit.Event{}), nil)
+			}
+			be.Err(t, storage.Clear(ctx, "order:3"), nil)
+
+			be.Equal(t, slices.Collect(lister.Keys("user")), []string{"user:1"})
+			be.Equal(t, slices.Collect(lister.Keys("missing")), []string(nil))
+
+			page, next, err := logger.KeysPage(ctx, "order:", "", 2)
+			be.Err(t, err, nil)
+			be.Equal(t, page, []string{"order:1", "order:10"})
+			be.Equal(t, next, "order:10")
+
+			page, next, err = logger.KeysPage(ctx, "order:", next, 2)
+			be.Err(t, err, nil)
+			be.Equal(t, page, []string{"order:2"})
+			be.Equal(t, next, "")
+
+			all, next, err := logger.KeysPage(ctx, "", "", 0)
+			be.Err(t, err, nil)
+			be.Equal(t, all, []string{"order:1", "order:10", "order:2", "orders", "user:1"})
+			be.Equal(t, next, "")
+		})
+	}
+}
+
+func TestLogger_KeysPage_Unsupported(t *testing.T) {
+	t.Parallel()
+	logger := audit.New(audit.WithStorage(newMockStorage()))
+
+	_, _, err := logger.KeysPage(t.Context(), "", "", 10)
+	be.Err(t, err, audit.ErrQueryUnsupported)
+}
+
+func TestLogger_Query_KeyListerFallback(t *testing.T) {
+	t.Parallel()
+	storage := openFileStorage(t, filepath.Join(t.TempDir(), "audit.jsonl"), audit.FileStorageOptions{})
+	logger := audit.New(audit.WithStorageV2(storage))
+	ctx := t.Context()
+	for _, key := range []string{"order:1", "user:1", "order:2"} {
+		be.Err(t, logger.CreateContext(ctx, key, "alice", "created", map[string]audit.Value{}), nil)
+	}
+
+	events, err := logger.Query(ctx, audit.Query{KeyPrefix: "order:"})
+	be.Err(t, err, nil)
+	keys := make([]string, 0, len(events))
+	for _, e := range events {
+		keys = append(keys, e.Key)
+	}
+	slices.Sort(keys)
+	be.Equal(t, keys, []string{"order:1", "order:2"})
+}
+
+func TestKeyListerInterface(t *testing.T) {
+	t.Parallel()
+	var _ audit.KeyLister = (*audit.InMemoryStorage)(nil)
+	var _ audit.KeyLister = (*audit.FileStorage)(nil)
+}
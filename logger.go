@@ -20,6 +20,9 @@
 package audit
 
 import (
+	"context"
+	"maps"
+	"slices"
 	"time"
 )
 
@@ -34,34 +37,64 @@ const (
 )
 
 type Value struct {
-	Data   any
-	Hidden bool
+	Data   any    `json:"data,omitempty"`
+	Hidden bool   `json:"hidden,omitempty"`
+	Digest string `json:"digest,omitempty"`
 }
 
 type ChangeField struct {
-	Field string
-	From  any
-	To    any
+	Field string `json:"field"`
+	From  any    `json:"from"`
+	To    any    `json:"to"`
 }
 
 type Change struct {
-	Fields      []ChangeField
-	Description string
-	Author      string
-	Timestamp   time.Time
+	Fields      []ChangeField `json:"fields"`
+	Description string        `json:"description"`
+	Author      string        `json:"author"`
+	Timestamp   time.Time     `json:"timestamp"`
 }
 
 type Event struct {
-	Timestamp   time.Time
-	Action      Action
-	Author      string
-	Description string
-	Payload     map[string]Value
+	// ID uniquely identifies the event. IDs from the default generator sort by time.
+	ID          string           `json:"id,omitempty"`
+	Timestamp   time.Time        `json:"timestamp"`
+	Action      Action           `json:"action"`
+	Author      string           `json:"author"`
+	Description string           `json:"description"`
+	Payload     map[string]Value `json:"payload"`
+
+	// Sequence is the 1-based position of the event in its entity's stream.
+	Sequence uint64 `json:"sequence,omitempty"`
+	// PrevHash is the Hash of the previous event for the same key.
+	PrevHash string `json:"prev_hash,omitempty"`
+	// Hash is the SHA-256 of the event's canonical encoding, including PrevHash.
+	Hash string `json:"hash,omitempty"`
+
+	// Actor is the identity stored in the logging context by WithActor, if any.
+	Actor *Actor `json:"actor,omitempty"`
+	// RequestID is the ID stored in the logging context by WithRequestID, if any.
+	RequestID string `json:"request_id,omitempty"`
+	// TxID is the ID of the Tx that recorded the event, if any.
+	TxID string `json:"tx_id,omitempty"`
+	// IdempotencyKey identifies the write that recorded the event, such as a
+	// message ID, so retries of the write can be skipped. See Deduplicator.
+	IdempotencyKey string `json:"idempotency_key,omitempty"`
 }
 
 // Logger provides thread-safe audit logging functionality.
 type Logger struct {
-	storage Storage
+	storage StorageV2
+	salt    []byte
+	chain   chain
+	compare Comparator
+	clock   func() time.Time
+	newID   IDGenerator
+
+	snapshots     SnapshotStore
+	snapshotEvery uint64
+
+	subs subscribers
 }
 
 // Option is a function that configures a Logger.
@@ -70,6 +103,14 @@ type Option func(*Logger)
 // WithStorage sets a custom storage implementation for the logger.
 // If not specified, NewInMemoryStorage() is used by default.
 func WithStorage(storage Storage) Option {
+	return func(l *Logger) {
+		l.storage = AdaptStorage(storage)
+	}
+}
+
+// WithStorageV2 sets a context-aware storage implementation for the logger.
+// Errors returned by the storage are propagated by the *Context methods.
+func WithStorageV2(storage StorageV2) Option {
 	return func(l *Logger) {
 		l.storage = storage
 	}
@@ -84,13 +125,27 @@ func WithStorage(storage Storage) Option {
 //	logger := audit.New(audit.WithStorage(customStorage)) // uses custom storage
 func New(opts ...Option) *Logger {
 	l := &Logger{
-		storage: NewInMemoryStorage(), // default storage
+		storage: AdaptStorage(NewInMemoryStorage()), // default storage
 	}
+	l.chain.limit = DefaultHeadCacheSize
 
 	for _, opt := range opts {
 		opt(l)
 	}
 
+	if l.compare == nil {
+		l.compare = Equal
+	}
+	if l.salt == nil {
+		l.salt = newSalt()
+	}
+	if l.clock == nil {
+		l.clock = time.Now
+	}
+	if l.newID == nil {
+		l.newID = NewIDGenerator()
+	}
+
 	return l
 }
 
@@ -109,16 +164,56 @@ func PlainValue(v any) Value {
 // LogChange records a new audit event for the given key with the specified action,
 // author, description, and payload. This is the core logging method used by Create,
 // Update, and Delete convenience methods.
+//
+// Storage errors are discarded; use LogChangeContext to observe them.
 func (l *Logger) LogChange(key string, action Action, author, description string, payload map[string]Value) {
+	_ = l.LogChangeContext(context.Background(), key, action, author, description, payload)
+}
+
+// LogChangeContext is like LogChange but honors ctx and returns the storage error, if any.
+//
+// The actor and request ID stored in ctx by WithActor and WithRequestID are
+// recorded on the event. If author is empty, the actor's ID is used instead.
+//
+// The event is appended to the key's hash chain: it receives the next sequence
+// number, the previous event's hash and its own hash. Writes to the same key are
+// serialized; the Logger must be the only writer for the keys it logs.
+//
+// If ctx carries an idempotency key (see WithIdempotencyKey) and the storage
+// implements Deduplicator, the event is skipped when the key already has an
+// event with the same idempotency key, and nil is returned.
+func (l *Logger) LogChangeContext(
+	ctx context.Context, key string, action Action, author, description string, payload map[string]Value,
+) error {
+	now := l.clock()
 	event := Event{
-		Timestamp:   time.Now(),
+		ID:          l.newID(now),
+		Timestamp:   now,
 		Action:      action,
 		Author:      author,
 		Description: description,
-		Payload:     payload,
+		Payload:     l.digestPayload(payload),
 	}
+	applyContext(ctx, &event)
 
-	l.storage.Store(key, event)
+	unlock := l.chain.lock(key)
+	defer unlock()
+
+	if dup, err := l.duplicate(ctx, key, event.IdempotencyKey); err != nil || dup {
+		return err
+	}
+	head, err := l.head(ctx, key)
+	if err != nil {
+		return err
+	}
+	seal(&event, head)
+	if err := l.storage.Store(ctx, key, event); err != nil {
+		return err
+	}
+	l.chain.advance(key, chainHead{sequence: event.Sequence, hash: event.Hash})
+	l.maybeSnapshot(ctx, key, event.Sequence)
+	l.subs.publish(key, event)
+	return nil
 }
 
 func (l *Logger) Create(key, author, description string, payload map[string]Value) {
@@ -133,97 +228,175 @@ func (l *Logger) Delete(key, author, description string, payload map[string]Valu
 	l.LogChange(key, ActionDelete, author, description, payload)
 }
 
+// CreateContext is like Create but honors ctx and returns the storage error, if any.
+func (l *Logger) CreateContext(ctx context.Context, key, author, description string, payload map[string]Value) error {
+	return l.LogChangeContext(ctx, key, ActionCreate, author, description, payload)
+}
+
+// UpdateContext is like Update but honors ctx and returns the storage error, if any.
+func (l *Logger) UpdateContext(ctx context.Context, key, author, description string, payload map[string]Value) error {
+	return l.LogChangeContext(ctx, key, ActionUpdate, author, description, payload)
+}
+
+// DeleteContext is like Delete but honors ctx and returns the storage error, if any.
+func (l *Logger) DeleteContext(ctx context.Context, key, author, description string, payload map[string]Value) error {
+	return l.LogChangeContext(ctx, key, ActionDelete, author, description, payload)
+}
+
+// Clear removes all events of key from the storage and resets its hash chain,
+// so the next event starts a new stream at sequence 1. Clear the storage
+// through the Logger rather than directly: a logger that has written to key
+// would otherwise keep chaining onto the removed events.
+func (l *Logger) Clear(ctx context.Context, key string) error {
+	unlock := l.chain.lock(key)
+	defer unlock()
+	// Forget the head even if Clear fails, as some events may be gone.
+	defer l.chain.forget(key)
+	return l.storage.Clear(ctx, key)
+}
+
 // Events retrieves audit events for a key, optionally filtering by specific payload fields.
 // If no fields are specified, all events for the key are returned.
 // When fields are provided, only events containing at least one of those fields are returned,
 // with their payloads filtered to include only the requested fields.
+//
+// Storage errors are discarded and yield a nil result; use EventsContext to observe them.
 func (l *Logger) Events(key string, fields ...string) []Event {
-	events := l.storage.Get(key)
+	events, _ := l.EventsContext(context.Background(), key, fields...)
+	return events
+}
+
+// EventsContext is like Events but honors ctx and returns the storage error, if any.
+func (l *Logger) EventsContext(ctx context.Context, key string, fields ...string) ([]Event, error) {
+	events, err := l.storage.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
 
 	// If no fields specified, return all events
 	if len(fields) == 0 {
 		result := make([]Event, len(events))
 		copy(result, events)
-		return result
+		return result, nil
 	}
 
-	// Build field set for O(1) lookup
+	fieldSet := newFieldSet(fields)
+	var filtered []Event
+	for _, e := range events {
+		if e, ok := filterFields(e, fieldSet); ok {
+			filtered = append(filtered, e)
+		}
+	}
+
+	return filtered, nil
+}
+
+// newFieldSet builds a set of field names for O(1) lookup.
+func newFieldSet(fields []string) map[string]struct{} {
 	fieldSet := make(map[string]struct{}, len(fields))
 	for _, f := range fields {
 		fieldSet[f] = struct{}{}
 	}
+	return fieldSet
+}
 
-	var filtered []Event
-	for _, e := range events {
-		// Check if event has any of the requested fields
-		hasField := false
-		for k := range e.Payload {
-			if _, ok := fieldSet[k]; ok {
-				hasField = true
-				break
-			}
+// filterFields reports whether e has at least one field from fieldSet and
+// returns a copy of e whose payload only contains those fields.
+func filterFields(e Event, fieldSet map[string]struct{}) (Event, bool) {
+	// Check if event has any of the requested fields
+	hasField := false
+	for k := range e.Payload {
+		if _, ok := fieldSet[k]; ok {
+			hasField = true
+			break
 		}
+	}
 
-		if !hasField {
-			continue
-		}
+	if !hasField {
+		return Event{}, false
+	}
 
-		// Build filtered payload using fieldSet (not slices.Contains)
-		payload := make(map[string]Value)
-		for k, v := range e.Payload {
-			if _, ok := fieldSet[k]; ok {
-				payload[k] = v
-			}
+	// Build filtered payload using fieldSet (not slices.Contains)
+	payload := make(map[string]Value)
+	for k, v := range e.Payload {
+		if _, ok := fieldSet[k]; ok {
+			payload[k] = v
 		}
-
-		filtered = append(filtered, Event{
-			Timestamp:   e.Timestamp,
-			Action:      e.Action,
-			Author:      e.Author,
-			Description: e.Description,
-			Payload:     payload,
-		})
 	}
 
-	return filtered
+	e.Payload = payload
+	return e, true
 }
 
 // Logs returns the complete change history for a key with field-level state transitions.
 // It reconstructs the state over time, tracking before/after values for each field.
+//
+// Values are compared with the logger's Comparator (Equal by default). When both the
+// old and new value of a field are maps or structs, each changed sub-path is reported
+// separately, for example "address.city". Fields are reported in sorted order.
+//
+// Storage errors are discarded and yield a nil result; use LogsContext to observe them.
 func (l *Logger) Logs(key string) []Change {
-	events := l.storage.Get(key)
-	state := make(map[string]any)
-	result := make([]Change, 0, len(events))
+	changes, _ := l.LogsContext(context.Background(), key)
+	return changes
+}
+
+// LogsContext is like Logs but honors ctx and returns the storage error, if any.
+func (l *Logger) LogsContext(ctx context.Context, key string) ([]Change, error) {
+	events, err := l.storage.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+
+	return l.changes(events), nil
+}
 
+// changes converts events into field-level state transitions, one Change per event.
+func (l *Logger) changes(events []Event) []Change {
+	d := l.newDiffer()
+	result := make([]Change, 0, len(events))
 	for _, e := range events {
-		change := Change{
-			Description: e.Description,
-			Author:      e.Author,
-			Timestamp:   e.Timestamp,
-			Fields:      make([]ChangeField, 0, len(e.Payload)),
-		}
-		for field, val := range e.Payload {
-			old := state[field]
-
-			from, to := old, val.Data
-			if val.Hidden {
-				from = HideText
-				to = HideText
-			}
-
-			if val.Hidden || old != val.Data {
-				change.Fields = append(change.Fields, ChangeField{
-					Field: field,
-					From:  from,
-					To:    to,
-				})
-				if !val.Hidden {
-					state[field] = val.Data
-				}
-			}
+		result = append(result, d.change(e))
+	}
+	return result
+}
+
+// differ reconstructs the field state of an entity event by event.
+type differ struct {
+	state   map[string]any
+	compare Comparator
+}
+
+func (l *Logger) newDiffer() *differ {
+	return &differ{state: make(map[string]any), compare: l.compare}
+}
+
+// change returns the field-level transitions caused by e and applies them to the state.
+func (d *differ) change(e Event) Change {
+	change := Change{
+		Description: e.Description,
+		Author:      e.Author,
+		Timestamp:   e.Timestamp,
+		Fields:      make([]ChangeField, 0, len(e.Payload)),
+	}
+	for _, field := range slices.Sorted(maps.Keys(e.Payload)) {
+		val := e.Payload[field]
+		if val.Hidden {
+			change.Fields = append(change.Fields, ChangeField{Field: field, From: HideText, To: HideText})
+			continue
 		}
-		result = append(result, change)
+
+		change.Fields = append(change.Fields, diffValue(field, d.state[field], val.Data, d.compare, 0)...)
+		d.state[field] = val.Data
 	}
+	return change
+}
 
-	return result
+// apply updates the state with e without computing its changes.
+func (d *differ) apply(e Event) {
+	for field, val := range e.Payload {
+		if !val.Hidden {
+			d.state[field] = val.Data
+		}
+	}
 }
@@ -1,6 +1,7 @@
 package audit_test
 
 import (
+	"context"
 	"fmt"
 	"testing"
 
@@ -45,6 +46,23 @@ func BenchmarkLogger_Events_NoFilter(b *testing.B) {
 	}
 }
 
+func BenchmarkLogger_EventsSeq(b *testing.B) {
+	logger := audit.New()
+	// Populate with 100 events
+	for i := range 100 {
+		logger.Create("key", "author", "desc", map[string]audit.Value{
+			"field": audit.PlainValue(i),
+		})
+	}
+	ctx := context.Background()
+
+	for b.Loop() {
+		for e := range logger.EventsSeq(ctx, "key") {
+			_ = e
+		}
+	}
+}
+
 func BenchmarkLogger_Events_WithFilter(b *testing.B) {
 	logger := audit.New()
 	// Populate with 100 events
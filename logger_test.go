@@ -1,6 +1,7 @@
 package audit_test
 
 import (
+	"context"
 	"fmt"
 	"sync"
 	"testing"
@@ -384,3 +385,37 @@ func TestHiddenValue(t *testing.T) {
 	be.True(t, v.Hidden)
 	be.Equal(t, v.Data, nil)
 }
+
+func TestLogger_ContextMethods(t *testing.T) {
+	t.Parallel()
+	logger := audit.New()
+	ctx := t.Context()
+
+	be.Err(t, logger.CreateContext(ctx, "order:1", "user", "Created", map[string]audit.Value{
+		"status": audit.PlainValue("pending"),
+	}), nil)
+	be.Err(t, logger.UpdateContext(ctx, "order:1", "user", "Updated", map[string]audit.Value{
+		"status": audit.PlainValue("approved"),
+	}), nil)
+	be.Err(t, logger.DeleteContext(ctx, "order:1", "admin", "Deleted", map[string]audit.Value{}), nil)
+
+	events, err := logger.EventsContext(ctx, "order:1", "status")
+	be.Err(t, err, nil)
+	be.Equal(t, len(events), 2)
+
+	changes, err := logger.LogsContext(ctx, "order:1")
+	be.Err(t, err, nil)
+	be.Equal(t, len(changes), 3)
+	be.Equal(t, changes[1].Fields[0].From, any("pending"))
+}
+
+func TestLogger_ContextMethods_Canceled(t *testing.T) {
+	t.Parallel()
+	logger := audit.New()
+	ctx, cancel := context.WithCancel(t.Context())
+	cancel()
+
+	err := logger.CreateContext(ctx, "order:1", "user", "Created", map[string]audit.Value{})
+	be.Err(t, err, context.Canceled)
+	be.Equal(t, len(logger.Events("order:1")), 0)
+}
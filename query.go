@@ -0,0 +1,188 @@
+package audit
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrInvalidQuery is returned when a Query has inconsistent parameters.
+	ErrInvalidQuery = errors.New("audit: invalid query")
+
+	// ErrQueryUnsupported is returned when a Query needs capabilities the storage lacks,
+	// such as scanning keys by prefix without implementing Querier.
+	ErrQueryUnsupported = errors.New("audit: query not supported by storage")
+)
+
+// Order selects the sort direction of query results.
+type Order int
+
+const (
+	// OrderAsc returns the oldest events first. This is the default.
+	OrderAsc Order = iota
+
+	// OrderDesc returns the newest events first.
+	OrderDesc
+)
+
+// KeyedEvent is an Event together with the key it was stored under.
+type KeyedEvent struct {
+	Key string `json:"key"`
+	Event
+}
+
+// Query selects events across one or many keys.
+// Zero values mean "no restriction" for every filter.
+type Query struct {
+	// Key restricts results to a single entity. It takes precedence over KeyPrefix.
+	Key string
+
+	// KeyPrefix restricts results to entities whose key starts with the prefix.
+	// An empty Key and KeyPrefix select all entities.
+	KeyPrefix string
+
+	// From and To bound the event timestamp to [From, To).
+	From time.Time
+	To   time.Time
+
+	// Authors and Actions keep events matching any of the listed values.
+	Authors []string
+	Actions []Action
+
+	// Fields keeps events with at least one of the listed payload fields and
+	// narrows their payload to those fields, like Logger.Events.
+	Fields []string
+
+	// Limit caps the number of results after Offset results have been skipped.
+	Limit  int
+	Offset int
+
+	// Order sorts results by timestamp, then key, then sequence.
+	Order Order
+}
+
+// Querier is an optional interface for storages that can evaluate a Query
+// themselves instead of loading every event through Get. Implementations must
+// apply all filters, ordering and pagination described by Query.
+type Querier interface {
+	Query(ctx context.Context, q Query) ([]KeyedEvent, error)
+}
+
+// Validate reports whether the query parameters are consistent.
+func (q Query) Validate() error {
+	switch {
+	case q.Limit < 0:
+		return fmt.Errorf("%w: negative limit", ErrInvalidQuery)
+	case q.Offset < 0:
+		return fmt.Errorf("%w: negative offset", ErrInvalidQuery)
+	case !q.From.IsZero() && !q.To.IsZero() && !q.From.Before(q.To):
+		return fmt.Errorf("%w: From must be before To", ErrInvalidQuery)
+	case q.Order != OrderAsc && q.Order != OrderDesc:
+		return fmt.Errorf("%w: unknown order %d", ErrInvalidQuery, q.Order)
+	}
+	return nil
+}
+
+// MatchKey reports whether key is selected by Key or KeyPrefix.
+func (q Query) MatchKey(key string) bool {
+	if q.Key != "" {
+		return key == q.Key
+	}
+	return strings.HasPrefix(key, q.KeyPrefix)
+}
+
+// Match reports whether the event stored under key satisfies every filter of q
+// except pagination, and returns it with the payload narrowed to q.Fields.
+// Querier implementations can use it for filters they cannot push down.
+func (q Query) Match(key string, e Event) (Event, bool) {
+	switch {
+	case !q.MatchKey(key):
+		return Event{}, false
+	case !q.From.IsZero() && e.Timestamp.Before(q.From):
+		return Event{}, false
+	case !q.To.IsZero() && !e.Timestamp.Before(q.To):
+		return Event{}, false
+	case len(q.Authors) > 0 && !slices.Contains(q.Authors, e.Author):
+		return Event{}, false
+	case len(q.Actions) > 0 && !slices.Contains(q.Actions, e.Action):
+		return Event{}, false
+	case len(q.Fields) > 0:
+		return filterFields(e, newFieldSet(q.Fields))
+	}
+	return e, true
+}
+
+// apply sorts matched events and applies Offset and Limit.
+func (q Query) apply(events []KeyedEvent) []KeyedEvent {
+	slices.SortStableFunc(events, func(a, b KeyedEvent) int {
+		c := cmp.Or(
+			a.Timestamp.Compare(b.Timestamp),
+			strings.Compare(a.Key, b.Key),
+			cmp.Compare(a.Sequence, b.Sequence),
+		)
+		if q.Order == OrderDesc {
+			return -c
+		}
+		return c
+	})
+
+	if q.Offset >= len(events) {
+		return []KeyedEvent{}
+	}
+	events = events[q.Offset:]
+	if q.Limit > 0 && q.Limit < len(events) {
+		events = events[:q.Limit]
+	}
+	return events
+}
+
+// Query returns the events selected by q. If the storage implements Querier
+// the query is delegated to it; otherwise Logger evaluates it on top of Get.
+// Queries without a Key then need a storage implementing KeyLister.
+//
+// Example:
+//
+//	// All deletes by alice during the last week.
+//	events, err := logger.Query(ctx, audit.Query{
+//	    KeyPrefix: "order:",
+//	    Authors:   []string{"alice"},
+//	    Actions:   []audit.Action{audit.ActionDelete},
+//	    From:      time.Now().AddDate(0, 0, -7),
+//	})
+func (l *Logger) Query(ctx context.Context, q Query) ([]KeyedEvent, error) {
+	if err := q.Validate(); err != nil {
+		return nil, err
+	}
+
+	if querier, ok := capability[Querier](l.storage); ok {
+		return querier.Query(ctx, q)
+	}
+
+	keys := []string{q.Key}
+	if q.Key == "" {
+		lister, ok := capability[KeyLister](l.storage)
+		if !ok {
+			return nil, fmt.Errorf("%w: key prefix scans need a Querier or KeyLister", ErrQueryUnsupported)
+		}
+		keys = slices.Collect(lister.Keys(q.KeyPrefix))
+	}
+
+	matched := []KeyedEvent{}
+	for _, key := range keys {
+		events, err := l.storage.Get(ctx, key)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range events {
+			if e, ok := q.Match(key, e); ok {
+				matched = append(matched, KeyedEvent{Key: key, Event: e})
+			}
+		}
+	}
+	return q.apply(matched), nil
+}
@@ -0,0 +1,169 @@
+package audit_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/w0rng/audit"
+	"github.com/w0rng/audit/internal/be"
+)
+
+// seedQueryStorage stores a fixed set of events one hour apart, starting at base.
+func seedQueryStorage(storage audit.Storage, base time.Time) {
+	events := []struct {
+		key    string
+		action audit.Action
+		author string
+		field  string
+	}{
+		{"order:1", audit.ActionCreate, "alice", "status"},
+		{"order:2", audit.ActionCreate, "bob", "status"},
+		{"order:1", audit.ActionUpdate, "bob", "total"},
+		{"user:1", audit.ActionCreate, "alice", "email"},
+		{"order:2", audit.ActionDelete, "alice", "status"},
+		{"order:1", audit.ActionDelete, "alice", "status"},
+	}
+	for i, e := range events {
+		storage.Store(e.key, audit.Event{
+			Timestamp: base.Add(time.Duration(i) * time.Hour),
+			Action:    e.action,
+			Author:    e.author,
+			Payload: map[string]audit.Value{
+				e.field: audit.PlainValue(i),
+				"note":  audit.PlainValue("n"),
+			},
+		})
+	}
+}
+
+func TestLogger_Query(t *testing.T) {
+	t.Parallel()
+	base := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	storage := audit.NewInMemoryStorage()
+	seedQueryStorage(storage, base)
+	logger := audit.New(audit.WithStorage(storage))
+
+	type hit struct {
+		key    string
+		action audit.Action
+	}
+	tests := []struct {
+		name  string
+		query audit.Query
+		want  []hit
+	}{
+		{
+			name:  "single key",
+			query: audit.Query{Key: "order:2"},
+			want:  []hit{{"order:2", audit.ActionCreate}, {"order:2", audit.ActionDelete}},
+		},
+		{
+			name:  "prefix and action",
+			query: audit.Query{KeyPrefix: "order:", Actions: []audit.Action{audit.ActionDelete}},
+			want:  []hit{{"order:2", audit.ActionDelete}, {"order:1", audit.ActionDelete}},
+		},
+		{
+			name:  "all keys by author",
+			query: audit.Query{Authors: []string{"bob"}},
+			want:  []hit{{"order:2", audit.ActionCreate}, {"order:1", audit.ActionUpdate}},
+		},
+		{
+			name:  "time window",
+			query: audit.Query{From: base.Add(2 * time.Hour), To: base.Add(4 * time.Hour)},
+			want:  []hit{{"order:1", audit.ActionUpdate}, {"user:1", audit.ActionCreate}},
+		},
+		{
+			name:  "fields",
+			query: audit.Query{Fields: []string{"total", "email"}},
+			want:  []hit{{"order:1", audit.ActionUpdate}, {"user:1", audit.ActionCreate}},
+		},
+		{
+			name:  "descending with limit and offset",
+			query: audit.Query{KeyPrefix: "order:", Order: audit.OrderDesc, Offset: 1, Limit: 2},
+			want:  []hit{{"order:2", audit.ActionDelete}, {"order:1", audit.ActionUpdate}},
+		},
+		{
+			name:  "offset past end",
+			query: audit.Query{Key: "order:1", Offset: 10},
+			want:  []hit{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			events, err := logger.Query(t.Context(), tt.query)
+			be.Err(t, err, nil)
+
+			got := make([]hit, 0, len(events))
+			for _, e := range events {
+				got = append(got, hit{e.Key, e.Action})
+			}
+			be.Equal(t, got, tt.want)
+		})
+	}
+}
+
+func TestLogger_Query_NarrowsPayload(t *testing.T) {
+	t.Parallel()
+	storage := audit.NewInMemoryStorage()
+	seedQueryStorage(storage, time.Now())
+	logger := audit.New(audit.WithStorage(storage))
+
+	events, err := logger.Query(t.Context(), audit.Query{Key: "user:1", Fields: []string{"email"}})
+	be.Err(t, err, nil)
+	be.Equal(t, len(events), 1)
+	be.Equal(t, len(events[0].Payload), 1)
+
+	// The stored event keeps its full payload.
+	be.Equal(t, len(storage.Get("user:1")[0].Payload), 2)
+}
+
+func TestLogger_Query_FallbackWithoutQuerier(t *testing.T) {
+	t.Parallel()
+	base := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	mock := newMockStorage()
+	seedQueryStorage(mock, base)
+	logger := audit.New(audit.WithStorage(mock))
+
+	events, err := logger.Query(t.Context(), audit.Query{
+		Key:     "order:1",
+		Authors: []string{"alice"},
+		Order:   audit.OrderDesc,
+	})
+	be.Err(t, err, nil)
+	be.Equal(t, len(events), 2)
+	be.Equal(t, events[0].Action, audit.ActionDelete)
+	be.Equal(t, events[0].Key, "order:1")
+
+	_, err = logger.Query(t.Context(), audit.Query{KeyPrefix: "order:"})
+	be.Err(t, err, audit.ErrQueryUnsupported)
+}
+
+func TestQuery_Validate(t *testing.T) {
+	t.Parallel()
+	now := time.Now()
+	tests := []struct {
+		name  string
+		query audit.Query
+	}{
+		{"negative limit", audit.Query{Limit: -1}},
+		{"negative offset", audit.Query{Offset: -1}},
+		{"empty window", audit.Query{From: now, To: now}},
+		{"unknown order", audit.Query{Order: audit.Order(7)}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			be.Err(t, tt.query.Validate(), audit.ErrInvalidQuery)
+
+			_, err := audit.New().Query(t.Context(), tt.query)
+			be.Err(t, err, audit.ErrInvalidQuery)
+		})
+	}
+}
+
+func TestInMemoryStorage_QuerierInterface(t *testing.T) {
+	var _ audit.Querier = (*audit.InMemoryStorage)(nil)
+}
@@ -0,0 +1,479 @@
+package audit
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrPruneUnsupported is returned when retention is applied to a storage
+// that does not implement Pruner.
+var ErrPruneUnsupported = errors.New("audit: storage cannot prune events")
+
+// RetentionPolicy selects the events a Pruner removes. Events are always
+// removed from the start of a key's stream, oldest first. Zero limits are
+// not enforced.
+type RetentionPolicy struct {
+	// MaxAge removes events whose Timestamp is older than MaxAge.
+	MaxAge time.Duration
+
+	// Now returns the current time MaxAge is measured from. Logger.Prune
+	// sets it to the logger's clock; otherwise it defaults to time.Now.
+	Now func() time.Time
+
+	// MaxEventsPerKey keeps at most this many of the newest events per key.
+	MaxEventsPerKey int
+
+	// MaxBytes bounds the total JSON-encoded size of the stored events.
+	// The oldest events across all keys are removed until the rest fit.
+	MaxBytes int64
+
+	// OnPrune, if set, receives the events selected for removal before they
+	// are removed, for example to archive them. If it returns an error,
+	// nothing is removed and Prune returns that error. An event may be passed
+	// to OnPrune again if it could not be removed afterwards.
+	OnPrune func(ctx context.Context, events []KeyedEvent) error
+}
+
+// Checkpoint identifies the last event pruned from a key's stream. Verify
+// uses it to check that the first remaining event continues the hash chain.
+type Checkpoint struct {
+	Sequence uint64 `json:"sequence"`
+	Hash     string `json:"hash"`
+}
+
+// Pruner is an optional interface for storages that can remove old events.
+type Pruner interface {
+	// Prune removes the events selected by policy and returns how many were removed.
+	Prune(ctx context.Context, policy RetentionPolicy) (int, error)
+
+	// Checkpoint returns the last event pruned from key, if any. Clear
+	// forgets the checkpoint along with the events.
+	Checkpoint(ctx context.Context, key string) (Checkpoint, bool, error)
+}
+
+// Prune applies policy to the logger's storage and returns how many events were removed.
+// It returns ErrPruneUnsupported if the storage does not implement Pruner.
+func (l *Logger) Prune(ctx context.Context, policy RetentionPolicy) (int, error) {
+	pruner, ok := capability[Pruner](l.storage)
+	if !ok {
+		return 0, ErrPruneUnsupported
+	}
+	if policy.Now == nil {
+		policy.Now = l.clock
+	}
+	return pruner.Prune(ctx, policy)
+}
+
+// defaultJanitorInterval is used when JanitorOptions.Interval is not set.
+const defaultJanitorInterval = time.Minute
+
+// JanitorOptions configures a Janitor.
+type JanitorOptions struct {
+	// Interval is the time between pruning runs. Defaults to one minute.
+	Interval time.Duration
+
+	// OnError, if set, is called with the errors returned by Prune.
+	OnError func(err error)
+}
+
+// Janitor applies a RetentionPolicy to a storage in the background.
+type Janitor struct {
+	pruner   Pruner
+	policy   RetentionPolicy
+	opts     JanitorOptions
+	cancel   context.CancelFunc
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+// StartJanitor prunes storage with policy every opts.Interval until Stop is called.
+// It returns ErrPruneUnsupported if the storage does not implement Pruner.
+//
+// Example:
+//
+//	janitor, err := audit.StartJanitor(storage, audit.RetentionPolicy{
+//	    MaxAge:  90 * 24 * time.Hour,
+//	    OnPrune: archive,
+//	}, audit.JanitorOptions{Interval: time.Hour})
+//	if err != nil {
+//	    return err
+//	}
+//	defer janitor.Stop()
+func StartJanitor(storage StorageV2, policy RetentionPolicy, opts JanitorOptions) (*Janitor, error) {
+	pruner, ok := capability[Pruner](storage)
+	if !ok {
+		return nil, ErrPruneUnsupported
+	}
+	if opts.Interval <= 0 {
+		opts.Interval = defaultJanitorInterval
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	j := &Janitor{pruner: pruner, policy: policy, opts: opts, cancel: cancel, done: make(chan struct{})}
+	go j.loop(ctx)
+	return j, nil
+}
+
+func (j *Janitor) loop(ctx context.Context) {
+	defer close(j.done)
+	ticker := time.NewTicker(j.opts.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := j.pruner.Prune(ctx, j.policy); err != nil && ctx.Err() == nil && j.opts.OnError != nil {
+				j.opts.OnError(err)
+			}
+		}
+	}
+}
+
+// Stop cancels a running prune and stops the janitor. It is safe to call more than once.
+func (j *Janitor) Stop() {
+	j.stopOnce.Do(func() {
+		j.cancel()
+		<-j.done
+	})
+}
+
+// checkpoint returns the chain head left by pruning key, if the storage records one.
+func (l *Logger) checkpoint(ctx context.Context, key string) (chainHead, bool, error) {
+	pruner, ok := capability[Pruner](l.storage)
+	if !ok {
+		return chainHead{}, false, nil
+	}
+	cp, ok, err := pruner.Checkpoint(ctx, key)
+	if err != nil || !ok {
+		return chainHead{}, false, err
+	}
+	return chainHead{sequence: cp.Sequence, hash: cp.Hash}, true, nil
+}
+
+// Prune removes the events selected by policy. The events to remove are
+// chosen under a read lock, so writers are not blocked while OnPrune runs.
+// It implements Pruner.
+func (s *InMemoryStorage) Prune(ctx context.Context, policy RetentionPolicy) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	s.pruneMu.Lock()
+	defer s.pruneMu.Unlock()
+
+	s.mu.RLock()
+	cuts, generation := retentionCuts(s.events, policy), s.generation
+	pruned := cutEvents(s.events, cuts)
+	s.mu.RUnlock()
+
+	if len(pruned) == 0 {
+		return 0, nil
+	}
+	if policy.OnPrune != nil {
+		if err := policy.OnPrune(ctx, pruned); err != nil {
+			return 0, fmt.Errorf("audit: prune hook: %w", err)
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.generation != generation {
+		// A key was cleared meanwhile; the plan may no longer match its stream.
+		return 0, nil
+	}
+	for key, n := range cuts {
+		s.removeFrontLocked(key, n)
+	}
+	return len(pruned), nil
+}
+
+// Checkpoint returns the last event pruned from key. It implements Pruner.
+func (s *InMemoryStorage) Checkpoint(ctx context.Context, key string) (Checkpoint, bool, error) {
+	if err := ctx.Err(); err != nil {
+		return Checkpoint{}, false, err
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	cp, ok := s.checkpoints[key]
+	return cp, ok, nil
+}
+
+// Prune removes the events selected by policy by rewriting the file without
+// them, with a checkpoint record for every pruned key, and renaming it over
+// the original. The events to remove are chosen without blocking writers;
+// the rewrite blocks them. Reads in progress finish on the old file.
+// It implements Pruner.
+func (s *FileStorage) Prune(ctx context.Context, policy RetentionPolicy) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	if s.opts.ReadOnly {
+		return 0, ErrReadOnly
+	}
+	s.pruneMu.Lock()
+	defer s.pruneMu.Unlock()
+
+	streams, generation, err := s.streams(ctx)
+	if err != nil {
+		return 0, err
+	}
+	cuts := retentionCuts(streams, policy)
+	pruned := cutEvents(streams, cuts)
+	if len(pruned) == 0 {
+		return 0, nil
+	}
+	if policy.OnPrune != nil {
+		if err = policy.OnPrune(ctx, pruned); err != nil {
+			return 0, fmt.Errorf("audit: prune hook: %w", err)
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch {
+	case s.closed:
+		return 0, ErrStorageClosed
+	case s.generation != generation:
+		// A key was cleared meanwhile; the plan may no longer match its stream.
+		return 0, nil
+	}
+	if err = s.compact(streams, cuts); err != nil {
+		return 0, err
+	}
+	return len(pruned), nil
+}
+
+// Checkpoint returns the last event pruned from key. It implements Pruner.
+func (s *FileStorage) Checkpoint(ctx context.Context, key string) (Checkpoint, bool, error) {
+	if err := ctx.Err(); err != nil {
+		return Checkpoint{}, false, err
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.closed {
+		return Checkpoint{}, false, ErrStorageClosed
+	}
+	cp, ok := s.checkpoints[key]
+	return cp, ok, nil
+}
+
+// streams reads the events of every key, along with the Clear generation
+// they were read at.
+func (s *FileStorage) streams(ctx context.Context) (map[string][]Event, uint64, error) {
+	s.mu.RLock()
+	keys := slices.Collect(maps.Keys(s.index))
+	generation := s.generation
+	s.mu.RUnlock()
+
+	streams := make(map[string][]Event, len(keys))
+	for _, key := range keys {
+		events, err := s.Get(ctx, key)
+		if err != nil {
+			return nil, 0, err
+		}
+		streams[key] = events
+	}
+	return streams, generation, nil
+}
+
+// compact replaces the file with one that holds the checkpoints and the
+// records of events not selected by cuts, then reopens it. The kept records
+// are copied byte for byte, in their original order. Callers must hold s.mu.
+func (s *FileStorage) compact(streams map[string][]Event, cuts map[string]int) error {
+	checkpoints := maps.Clone(s.checkpoints)
+	var kept []recordRef
+	for key, refs := range s.index {
+		if n := cuts[key]; n > 0 {
+			last := streams[key][n-1]
+			checkpoints[key] = Checkpoint{Sequence: last.Sequence, Hash: last.Hash}
+			refs = refs[n:]
+		}
+		kept = append(kept, refs...)
+	}
+	slices.SortFunc(kept, func(a, b recordRef) int { return cmp.Compare(a.offset, b.offset) })
+
+	err := replaceFile(s.path, s.opts.Perm, func(w io.Writer) error {
+		for _, key := range slices.Sorted(maps.Keys(checkpoints)) {
+			cp := checkpoints[key]
+			line, err := json.Marshal(fileRecord{Key: key, Op: opCheckpoint, Checkpoint: &cp})
+			if err != nil {
+				return err
+			}
+			if _, err = w.Write(append(line, '\n')); err != nil {
+				return err
+			}
+		}
+		_, err := io.Copy(w, &recordReader{file: s.file, refs: kept})
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("audit: compact %s: %w", s.path, err)
+	}
+	return s.reopen()
+}
+
+// reopen replaces the open file with the one now at s.path and rebuilds the
+// index from it. If that fails, the storage is closed. Callers must hold s.mu.
+func (s *FileStorage) reopen() error {
+	file, err := os.OpenFile(s.path, os.O_RDWR, s.opts.Perm)
+	if err != nil {
+		s.closed = true
+		s.file.retire()
+		return fmt.Errorf("audit: reopen %s: %w", s.path, err)
+	}
+	s.file.retire()
+	s.file = &sharedFile{File: file}
+	s.index = make(map[string][]recordRef)
+	s.checkpoints = make(map[string]Checkpoint)
+	s.size = 0
+	s.dirty = false
+	if err = s.rebuild(); err != nil {
+		s.closed = true
+		s.file.retire()
+		return err
+	}
+	return nil
+}
+
+// recordReader reads the records at refs of file one after another.
+type recordReader struct {
+	file *sharedFile
+	refs []recordRef
+	pos  int // read position within refs[0]
+}
+
+func (r *recordReader) Read(p []byte) (int, error) {
+	if len(r.refs) == 0 {
+		return 0, io.EOF
+	}
+	ref := r.refs[0]
+	n := min(len(p), ref.length-r.pos)
+	n, err := r.file.ReadAt(p[:n], ref.offset+int64(r.pos))
+	r.pos += n
+	switch {
+	case r.pos == ref.length:
+		// ReadAt may report io.EOF along with the last record of the file.
+		r.refs, r.pos = r.refs[1:], 0
+		err = nil
+	case errors.Is(err, io.EOF):
+		err = io.ErrUnexpectedEOF
+	}
+	return n, err
+}
+
+// removeFrontLocked removes the first n events of key and records the last
+// one as its checkpoint. Callers must hold s.mu.
+func (s *InMemoryStorage) removeFrontLocked(key string, n int) {
+	events := s.events[key]
+	last := events[n-1]
+	if s.checkpoints == nil {
+		s.checkpoints = make(map[string]Checkpoint)
+	}
+	s.checkpoints[key] = Checkpoint{Sequence: last.Sequence, Hash: last.Hash}
+
+	if n == len(events) {
+		delete(s.events, key)
+		if i, ok := slices.BinarySearch(s.keys, key); ok {
+			s.keys = slices.Delete(s.keys, i, i+1)
+		}
+		return
+	}
+	// Readers may still hold the old slice, so the survivors are copied.
+	s.events[key] = slices.Clone(events[n:])
+}
+
+// cutEvents returns the events selected by cuts, ordered by key.
+func cutEvents(streams map[string][]Event, cuts map[string]int) []KeyedEvent {
+	var events []KeyedEvent
+	for _, key := range slices.Sorted(maps.Keys(cuts)) {
+		for _, e := range streams[key][:cuts[key]] {
+			events = append(events, KeyedEvent{Key: key, Event: e})
+		}
+	}
+	return events
+}
+
+// retentionCuts returns, for each key of streams with events to remove, how
+// many events to remove from the start of its stream.
+func retentionCuts(streams map[string][]Event, policy RetentionPolicy) map[string]int {
+	now := time.Now
+	if policy.Now != nil {
+		now = policy.Now
+	}
+	cutoff := now().Add(-policy.MaxAge)
+	cuts := make(map[string]int)
+	for key, events := range streams {
+		n := 0
+		if policy.MaxEventsPerKey > 0 && len(events) > policy.MaxEventsPerKey {
+			n = len(events) - policy.MaxEventsPerKey
+		}
+		if policy.MaxAge > 0 {
+			for n < len(events) && events[n].Timestamp.Before(cutoff) {
+				n++
+			}
+		}
+		if n > 0 {
+			cuts[key] = n
+		}
+	}
+	if policy.MaxBytes > 0 {
+		cutToSize(streams, cuts, policy.MaxBytes)
+	}
+	return cuts
+}
+
+// cutToSize extends cuts so that the remaining events encode to at most
+// maxBytes, removing the oldest events across all keys first.
+func cutToSize(streams map[string][]Event, cuts map[string]int, maxBytes int64) {
+	type candidate struct {
+		key   string
+		index int
+		at    time.Time
+	}
+
+	var total int64
+	sizes := make(map[string][]int64, len(streams))
+	var candidates []candidate
+	for key, events := range streams {
+		sizes[key] = make([]int64, len(events))
+		for i := cuts[key]; i < len(events); i++ {
+			sizes[key][i] = eventSize(key, events[i])
+			total += sizes[key][i]
+			candidates = append(candidates, candidate{key: key, index: i, at: events[i].Timestamp})
+		}
+	}
+	slices.SortFunc(candidates, func(a, b candidate) int {
+		return cmp.Or(a.at.Compare(b.at), strings.Compare(a.key, b.key), cmp.Compare(a.index, b.index))
+	})
+
+	for _, c := range candidates {
+		if total <= maxBytes {
+			return
+		}
+		// Streams are cut from the start, so removing c removes its predecessors too.
+		for i := cuts[c.key]; i <= c.index; i++ {
+			total -= sizes[c.key][i]
+		}
+		cuts[c.key] = max(cuts[c.key], c.index+1)
+	}
+}
+
+// eventSize approximates the storage footprint of an event by its JSON encoding.
+func eventSize(key string, e Event) int64 {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return int64(len(key))
+	}
+	return int64(len(key) + len(data))
+}
@@ -0,0 +1,289 @@
+package audit_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"iter"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/w0rng/audit"
+	"github.com/w0rng/audit/internal/be"
+)
+
+func logN(logger *audit.Logger, key string, n int) {
+	for i := range n {
+		logger.Update(key, "alice", "updated", map[string]audit.Value{"n": audit.PlainValue(i)})
+	}
+}
+
+func TestInMemoryStorage_Prune_MaxEventsPerKey(t *testing.T) {
+	t.Parallel()
+	storage := audit.NewInMemoryStorage()
+	logger := audit.New(audit.WithStorage(storage))
+	logN(logger, "order:1", 5)
+	logN(logger, "order:2", 2)
+
+	var archived []audit.KeyedEvent
+	pruned, err := logger.Prune(t.Context(), audit.RetentionPolicy{
+		MaxEventsPerKey: 2,
+		OnPrune: func(_ context.Context, events []audit.KeyedEvent) error {
+			archived = append(archived, events...)
+			return nil
+		},
+	})
+	be.Err(t, err, nil)
+	be.Equal(t, pruned, 3)
+	be.Equal(t, len(archived), 3)
+	for i, e := range archived {
+		be.Equal(t, e.Key, "order:1")
+		be.Equal(t, e.Sequence, uint64(i+1))
+	}
+
+	events := logger.Events("order:1")
+	be.Equal(t, len(events), 2)
+	be.Equal(t, events[0].Sequence, uint64(4))
+	be.Equal(t, len(logger.Events("order:2")), 2)
+
+	cp, ok, err := storage.Checkpoint(t.Context(), "order:1")
+	be.Err(t, err, nil)
+	be.True(t, ok)
+	be.Equal(t, cp, audit.Checkpoint{Sequence: 3, Hash: archived[2].Hash})
+
+	be.Err(t, logger.Verify("order:1"), nil)
+	logN(logger, "order:1", 1)
+	be.Err(t, logger.Verify("order:1"), nil)
+}
+
+func TestFileStorage_Prune(t *testing.T) {
+	t.Parallel()
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	storage := openFileStorage(t, path, audit.FileStorageOptions{})
+	logger := audit.New(audit.WithStorageV2(storage))
+	logN(logger, "order:1", 5)
+	logN(logger, "order:2", 2)
+	before, err := os.Stat(path)
+	be.Err(t, err, nil)
+
+	// A read in progress finishes on the file it started with.
+	next, stop := iter.Pull2(storage.Stream(t.Context(), "order:1"))
+	defer stop()
+	_, err, ok := next()
+	be.Err(t, err, nil)
+	be.True(t, ok)
+
+	var archived []audit.KeyedEvent
+	pruned, err := logger.Prune(t.Context(), audit.RetentionPolicy{
+		MaxEventsPerKey: 2,
+		OnPrune: func(_ context.Context, events []audit.KeyedEvent) error {
+			archived = append(archived, events...)
+			return nil
+		},
+	})
+	be.Err(t, err, nil)
+	be.Equal(t, pruned, 3)
+	be.Equal(t, len(archived), 3)
+	after, err := os.Stat(path)
+	be.Err(t, err, nil)
+	be.True(t, after.Size() < before.Size())
+	for range 4 {
+		_, err, ok = next()
+		be.Err(t, err, nil)
+		be.True(t, ok)
+	}
+	stop()
+
+	events := logger.Events("order:1")
+	be.Equal(t, len(events), 2)
+	be.Equal(t, events[0].Sequence, uint64(4))
+	cp, ok, err := storage.Checkpoint(t.Context(), "order:1")
+	be.Err(t, err, nil)
+	be.True(t, ok)
+	be.Equal(t, cp, audit.Checkpoint{Sequence: 3, Hash: archived[2].Hash})
+	be.Err(t, logger.Verify("order:1"), nil)
+	logN(logger, "order:1", 1)
+	be.Err(t, logger.Verify("order:1"), nil)
+
+	// Pruning every event of a key leaves only its checkpoint.
+	pruned, err = logger.Prune(t.Context(), audit.RetentionPolicy{
+		MaxAge: time.Hour,
+		Now:    func() time.Time { return time.Now().Add(2 * time.Hour) },
+	})
+	be.Err(t, err, nil)
+	be.Equal(t, pruned, 5)
+	has, err := storage.Has(t.Context(), "order:2")
+	be.Err(t, err, nil)
+	be.True(t, !has)
+
+	// Checkpoints survive reopening, so new events continue the chains.
+	be.Err(t, storage.Close(), nil)
+	storage = openFileStorage(t, path, audit.FileStorageOptions{})
+	logger = audit.New(audit.WithStorageV2(storage))
+	logN(logger, "order:2", 1)
+	events = logger.Events("order:2")
+	be.Equal(t, len(events), 1)
+	be.Equal(t, events[0].Sequence, uint64(3))
+	be.Err(t, logger.Verify("order:1"), nil)
+	be.Err(t, logger.Verify("order:2"), nil)
+
+	// Clear forgets the checkpoint, also across reopening.
+	be.Err(t, logger.Clear(t.Context(), "order:1"), nil)
+	be.Err(t, storage.Close(), nil)
+	storage = openFileStorage(t, path, audit.FileStorageOptions{})
+	_, ok, err = storage.Checkpoint(t.Context(), "order:1")
+	be.Err(t, err, nil)
+	be.True(t, !ok)
+}
+
+func TestFileStorage_Prune_ReadOnly(t *testing.T) {
+	t.Parallel()
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	logN(audit.New(audit.WithStorageV2(openFileStorage(t, path, audit.FileStorageOptions{}))), "order:1", 3)
+
+	storage := openFileStorage(t, path, audit.FileStorageOptions{ReadOnly: true})
+	_, err := storage.Prune(t.Context(), audit.RetentionPolicy{MaxEventsPerKey: 1})
+	be.Err(t, err, audit.ErrReadOnly)
+}
+
+func TestInMemoryStorage_Prune_MaxAge(t *testing.T) {
+	t.Parallel()
+	storage := audit.NewInMemoryStorage()
+	logger := audit.New(audit.WithStorage(storage))
+	logN(logger, "order:1", 3)
+
+	pruned, err := logger.Prune(t.Context(), audit.RetentionPolicy{
+		MaxAge: time.Hour,
+		Now:    func() time.Time { return time.Now().Add(2 * time.Hour) },
+	})
+	be.Err(t, err, nil)
+	be.Equal(t, pruned, 3)
+	be.True(t, !storage.Has("order:1"))
+
+	// A fresh logger continues the chain from the checkpoint.
+	logger = audit.New(audit.WithStorage(storage))
+	logN(logger, "order:1", 1)
+	be.Equal(t, logger.Events("order:1")[0].Sequence, uint64(4))
+	be.Err(t, logger.Verify("order:1"), nil)
+
+	pruned, err = logger.Prune(t.Context(), audit.RetentionPolicy{MaxAge: time.Hour})
+	be.Err(t, err, nil)
+	be.Equal(t, pruned, 0)
+}
+
+func TestLogger_Prune_Clock(t *testing.T) {
+	t.Parallel()
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	logger := audit.New(audit.WithClock(func() time.Time { return now }))
+	logN(logger, "order:1", 2)
+	now = now.Add(2 * time.Hour)
+	logN(logger, "order:1", 1)
+
+	pruned, err := logger.Prune(t.Context(), audit.RetentionPolicy{MaxAge: time.Hour})
+	be.Err(t, err, nil)
+	be.Equal(t, pruned, 2)
+	be.Equal(t, len(logger.Events("order:1")), 1)
+}
+
+func TestInMemoryStorage_Prune_MaxBytes(t *testing.T) {
+	t.Parallel()
+	storage := audit.NewInMemoryStorage()
+	base := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	for i, key := range []string{"a", "b", "a", "b", "a"} {
+		storage.Store(key, audit.Event{Timestamp: base.Add(time.Duration(i) * time.Minute), Description: "same size"})
+	}
+	encoded, err := json.Marshal(storage.Get("a")[0])
+	be.Err(t, err, nil)
+	size := int64(len(encoded) + 1)
+
+	pruned, err := storage.Prune(t.Context(), audit.RetentionPolicy{MaxBytes: 5 * size})
+	be.Err(t, err, nil)
+	be.Equal(t, pruned, 0)
+
+	// Room for three events: the two oldest go, across keys.
+	var archived []audit.KeyedEvent
+	pruned, err = storage.Prune(t.Context(), audit.RetentionPolicy{
+		MaxBytes: 3 * size,
+		OnPrune: func(_ context.Context, events []audit.KeyedEvent) error {
+			archived = events
+			return nil
+		},
+	})
+	be.Err(t, err, nil)
+	be.Equal(t, pruned, 2)
+	be.Equal(t, len(archived), 2)
+	be.Equal(t, len(storage.Get("a")), 2)
+	be.Equal(t, len(storage.Get("b")), 1)
+	be.Equal(t, storage.Get("b")[0].Timestamp, base.Add(3*time.Minute))
+}
+
+func TestInMemoryStorage_Prune_HookError(t *testing.T) {
+	t.Parallel()
+	logger := audit.New()
+	logN(logger, "order:1", 3)
+	errArchive := errors.New("archive unavailable")
+
+	pruned, err := logger.Prune(t.Context(), audit.RetentionPolicy{
+		MaxEventsPerKey: 1,
+		OnPrune:         func(context.Context, []audit.KeyedEvent) error { return errArchive },
+	})
+	be.Err(t, err, errArchive)
+	be.Equal(t, pruned, 0)
+	be.Equal(t, len(logger.Events("order:1")), 3)
+}
+
+func TestLogger_Verify_ClearForgetsCheckpoint(t *testing.T) {
+	t.Parallel()
+	storage := audit.NewInMemoryStorage()
+	logger := audit.New(audit.WithStorage(storage))
+	logN(logger, "order:1", 3)
+	tail := logger.Events("order:1")[2]
+
+	storage.Clear("order:1")
+	storage.Store("order:1", tail)
+	be.Err(t, logger.Verify("order:1"), audit.ErrChainBroken)
+
+	_, err := logger.Prune(t.Context(), audit.RetentionPolicy{MaxEventsPerKey: 1})
+	be.Err(t, err, nil)
+	be.Err(t, logger.Verify("order:1"), audit.ErrChainBroken)
+}
+
+func TestPrune_Unsupported(t *testing.T) {
+	t.Parallel()
+	logger := audit.New(audit.WithStorage(newMockStorage()))
+
+	_, err := logger.Prune(t.Context(), audit.RetentionPolicy{MaxEventsPerKey: 1})
+	be.Err(t, err, audit.ErrPruneUnsupported)
+	_, err = audit.StartJanitor(audit.AdaptStorage(newMockStorage()), audit.RetentionPolicy{}, audit.JanitorOptions{})
+	be.Err(t, err, audit.ErrPruneUnsupported)
+}
+
+func TestJanitor(t *testing.T) {
+	t.Parallel()
+	storage := audit.NewInMemoryStorage()
+	logger := audit.New(audit.WithStorage(storage))
+	logN(logger, "order:1", 5)
+
+	pruned := make(chan int, 1)
+	janitor, err := audit.StartJanitor(audit.AdaptStorage(storage), audit.RetentionPolicy{
+		MaxEventsPerKey: 2,
+		OnPrune: func(_ context.Context, events []audit.KeyedEvent) error {
+			pruned <- len(events)
+			return nil
+		},
+	}, audit.JanitorOptions{Interval: time.Millisecond})
+	be.Err(t, err, nil)
+
+	be.Equal(t, <-pruned, 3)
+	janitor.Stop()
+	janitor.Stop()
+	be.Equal(t, len(logger.Events("order:1")), 2)
+}
+
+func TestPrunerInterface(t *testing.T) {
+	t.Parallel()
+	var _ audit.Pruner = (*audit.InMemoryStorage)(nil)
+	var _ audit.Pruner = (*audit.FileStorage)(nil)
+}
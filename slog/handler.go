@@ -121,6 +121,8 @@ func (h *Handler) Enabled(ctx context.Context, level slog.Level) bool {
 }
 
 // Handle processes a slog.Record, optionally sending it to audit.
+// The actor and request ID carried by ctx are recorded on the audit event.
+// Errors from the audit storage are returned to the caller.
 func (h *Handler) Handle(ctx context.Context, record slog.Record) error {
 	// Delegate to underlying handler first
 	if h.handler != nil {
@@ -155,9 +157,7 @@ func (h *Handler) Handle(ctx context.Context, record slog.Record) error {
 	payload := h.opts.PayloadExtractor(allAttrs)
 
 	// Log to audit
-	h.logger.LogChange(key, action, author, record.Message, payload)
-
-	return nil
+	return h.logger.LogChangeContext(ctx, key, action, author, record.Message, payload)
 }
 
 // WithAttrs returns a new Handler with additional attributes.
@@ -213,6 +213,7 @@ func DefaultActionExtractor(attrs []slog.Attr) audit.Action {
 }
 
 // DefaultAuthorExtractor extracts author from AttrAuthor or AttrUser attribute.
+// If neither is present, it uses the ID of the actor stored in ctx by audit.WithActor.
 // Defaults to "system" if not found.
 func DefaultAuthorExtractor(ctx context.Context, attrs []slog.Attr) string {
 	for _, attr := range attrs {
@@ -220,6 +221,9 @@ func DefaultAuthorExtractor(ctx context.Context, attrs []slog.Attr) string {
 			return attr.Value.String()
 		}
 	}
+	if actor, ok := audit.ActorFrom(ctx); ok && actor.ID != "" {
+		return actor.ID
+	}
 	return "system"
 }
 
@@ -2,6 +2,7 @@ package slog_test
 
 import (
 	"bytes"
+	"context"
 	"log/slog"
 	"testing"
 
@@ -93,6 +94,49 @@ func TestHandler_Handle_WithAuthor(t *testing.T) {
 	be.Equal(t, events[0].Author, author)
 }
 
+func TestHandler_Handle_ContextMetadata(t *testing.T) {
+	t.Parallel()
+	logger := audit.New()
+	handler := auditslog.NewHandler(logger, auditslog.HandlerOptions{
+		KeyExtractor: auditslog.AttrExtractor("entity"),
+	})
+
+	ctx := audit.WithActor(t.Context(), audit.Actor{ID: "u-42", Type: "user", IP: "10.0.0.1"})
+	ctx = audit.WithRequestID(ctx, "req-1")
+
+	record := slog.Record{
+		Message: "User created",
+	}
+	record.AddAttrs(slog.String("entity", "user:123"))
+
+	be.Err(t, handler.Handle(ctx, record), nil)
+
+	events := logger.Events("user:123")
+	be.Equal(t, len(events), 1)
+	be.Equal(t, events[0].Author, "u-42")
+	be.Equal(t, *events[0].Actor, audit.Actor{ID: "u-42", Type: "user", IP: "10.0.0.1"})
+	be.Equal(t, events[0].RequestID, "req-1")
+}
+
+func TestHandler_Handle_CanceledContext(t *testing.T) {
+	t.Parallel()
+	logger := audit.New()
+	handler := auditslog.NewHandler(logger, auditslog.HandlerOptions{
+		KeyExtractor: auditslog.AttrExtractor("entity"),
+	})
+
+	ctx, cancel := context.WithCancel(t.Context())
+	cancel()
+
+	record := slog.Record{
+		Message: "User created",
+	}
+	record.AddAttrs(slog.String("entity", "user:123"))
+
+	be.Err(t, handler.Handle(ctx, record), context.Canceled)
+	be.Equal(t, len(logger.Events("user:123")), 0)
+}
+
 func TestHandler_Handle_ShouldAudit(t *testing.T) {
 	t.Parallel()
 	logger := audit.New()
@@ -311,6 +355,15 @@ func TestDefaultAuthorExtractor(t *testing.T) {
 	}
 }
 
+func TestDefaultAuthorExtractor_Actor(t *testing.T) {
+	t.Parallel()
+	ctx := audit.WithActor(t.Context(), audit.Actor{ID: "u-42"})
+
+	be.Equal(t, auditslog.DefaultAuthorExtractor(ctx, nil), "u-42")
+	be.Equal(t, auditslog.DefaultAuthorExtractor(ctx, []slog.Attr{slog.String("author", "admin")}), "admin")
+	be.Equal(t, auditslog.DefaultAuthorExtractor(audit.WithActor(t.Context(), audit.Actor{}), nil), "system")
+}
+
 func TestDefaultPayloadExtractor(t *testing.T) {
 	t.Parallel()
 
@@ -0,0 +1,277 @@
+package audit
+
+import (
+	"cmp"
+	"context"
+	"maps"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Snapshot is the reconstructed state of an entity after one of its events.
+// StateAt and LogsRange start replaying from the nearest snapshot instead of
+// from the first event.
+type Snapshot struct {
+	Key string `json:"key"`
+	// Sequence, Hash and Timestamp identify the last event included in the snapshot.
+	Sequence  uint64    `json:"sequence"`
+	Hash      string    `json:"hash"`
+	Timestamp time.Time `json:"timestamp"`
+
+	// State is the entity as returned by StateAt after the event, nil if it was deleted.
+	State map[string]Value `json:"state"`
+	// Fields holds the latest visible data of every field ever set, which
+	// Logs compares the following events against.
+	Fields map[string]any `json:"fields"`
+}
+
+// SnapshotStore persists entity snapshots for a Logger configured with WithSnapshots.
+type SnapshotStore interface {
+	// SaveSnapshot stores snapshot, replacing a snapshot with the same key and sequence.
+	SaveSnapshot(ctx context.Context, snapshot Snapshot) error
+
+	// LoadSnapshot returns the snapshot of key with the highest Sequence whose
+	// Timestamp is at or before at. A zero at selects the latest snapshot.
+	LoadSnapshot(ctx context.Context, key string, at time.Time) (Snapshot, bool, error)
+}
+
+// SequenceReader is an optional interface for storages that can read the end
+// of an entity's stream without loading the events before it. A Logger with a
+// SnapshotStore uses it to read only the events that follow a snapshot.
+type SequenceReader interface {
+	// EventsAfter returns the events of key with a Sequence greater than
+	// sequence, in insertion order.
+	EventsAfter(ctx context.Context, key string, sequence uint64) ([]Event, error)
+}
+
+// WithSnapshots makes the logger save a snapshot of an entity to store every
+// time its sequence number reaches a multiple of every.
+//
+// Snapshots are an optimization: a snapshot that cannot be saved does not
+// fail the write, and one that no longer matches the stored events, for
+// example after Clear, is ignored.
+func WithSnapshots(store SnapshotStore, every int) Option {
+	return func(l *Logger) {
+		l.snapshots = store
+		l.snapshotEvery = 0
+		if every > 0 {
+			l.snapshotEvery = uint64(every)
+		}
+	}
+}
+
+// InMemorySnapshotStore is a thread-safe SnapshotStore backed by a map.
+type InMemorySnapshotStore struct {
+	mu        sync.RWMutex
+	snapshots map[string][]Snapshot // sorted by Sequence
+}
+
+// NewInMemorySnapshotStore creates an empty in-memory snapshot store.
+func NewInMemorySnapshotStore() *InMemorySnapshotStore {
+	return &InMemorySnapshotStore{snapshots: make(map[string][]Snapshot)}
+}
+
+// SaveSnapshot stores snapshot. It implements SnapshotStore.
+func (s *InMemorySnapshotStore) SaveSnapshot(ctx context.Context, snapshot Snapshot) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	list := s.snapshots[snapshot.Key]
+	i, found := slices.BinarySearchFunc(list, snapshot.Sequence, func(s Snapshot, seq uint64) int {
+		return cmp.Compare(s.Sequence, seq)
+	})
+	if found {
+		list[i] = snapshot
+	} else {
+		list = slices.Insert(list, i, snapshot)
+	}
+	s.snapshots[snapshot.Key] = list
+	return nil
+}
+
+// LoadSnapshot returns the newest snapshot of key taken at or before at.
+// It implements SnapshotStore.
+func (s *InMemorySnapshotStore) LoadSnapshot(ctx context.Context, key string, at time.Time) (Snapshot, bool, error) {
+	if err := ctx.Err(); err != nil {
+		return Snapshot{}, false, err
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	list := s.snapshots[key]
+	n := len(list)
+	if !at.IsZero() {
+		n = sort.Search(len(list), func(i int) bool { return list[i].Timestamp.After(at) })
+	}
+	if n == 0 {
+		return Snapshot{}, false, nil
+	}
+	return list[n-1], true, nil
+}
+
+// EventsAfter returns the events of key with a Sequence greater than sequence.
+// It implements SequenceReader.
+func (s *InMemoryStorage) EventsAfter(ctx context.Context, key string, sequence uint64) ([]Event, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	events := s.events[key]
+	i := len(events)
+	for i > 0 && events[i-1].Sequence > sequence {
+		i--
+	}
+	return slices.Clone(events[i:]), nil
+}
+
+// EventsAfter returns the events of key with a Sequence greater than sequence.
+// Records are decoded from the end of the stream, so only the returned events
+// and the one before them are read. It implements SequenceReader.
+func (s *FileStorage) EventsAfter(ctx context.Context, key string, sequence uint64) ([]Event, error) {
+	file, refs, err := s.refs(key)
+	if err != nil {
+		return nil, err
+	}
+	defer file.release()
+
+	var events []Event
+	for i := len(refs) - 1; i >= 0; i-- {
+		if err = ctx.Err(); err != nil {
+			return nil, err
+		}
+		event, readErr := s.read(file, refs[i])
+		if readErr != nil {
+			return nil, readErr
+		}
+		if event.Sequence <= sequence {
+			break
+		}
+		events = append(events, event)
+	}
+	slices.Reverse(events)
+	return events, nil
+}
+
+// LogsRange is like Logs but only returns the changes of events with
+// timestamps between from and to, inclusive. A zero from or to leaves that
+// end of the range open. Values are compared against the state before from,
+// which is replayed from the nearest snapshot when the logger has a SnapshotStore.
+//
+// Storage errors are discarded and yield a nil result; use LogsRangeContext to observe them.
+func (l *Logger) LogsRange(key string, from, to time.Time) []Change {
+	changes, _ := l.LogsRangeContext(context.Background(), key, from, to)
+	return changes
+}
+
+// LogsRangeContext is like LogsRange but honors ctx and returns the storage error, if any.
+func (l *Logger) LogsRangeContext(ctx context.Context, key string, from, to time.Time) ([]Change, error) {
+	var (
+		events []Event
+		snap   *Snapshot
+		err    error
+	)
+	if from.IsZero() {
+		events, err = l.storage.Get(ctx, key)
+	} else {
+		// The snapshot must precede from, since an event at from is part of the range.
+		events, snap, err = l.sinceSnapshot(ctx, key, from.Add(-time.Nanosecond))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	d := l.newDiffer()
+	if snap != nil {
+		d.state = maps.Clone(snap.Fields)
+	}
+	var result []Change
+	for _, e := range events {
+		change := d.change(e)
+		if !e.Timestamp.Before(from) && (to.IsZero() || !e.Timestamp.After(to)) {
+			result = append(result, change)
+		}
+	}
+	return result, nil
+}
+
+// sinceSnapshot returns the events of key that follow its newest snapshot
+// taken at or before at, and that snapshot. Without a usable snapshot it
+// returns every event and a nil snapshot. If the storage is a SequenceReader,
+// only the events from the snapshot on are read.
+func (l *Logger) sinceSnapshot(ctx context.Context, key string, at time.Time) ([]Event, *Snapshot, error) {
+	if l.snapshots == nil {
+		events, err := l.storage.Get(ctx, key)
+		return events, nil, err
+	}
+	snap, ok, err := l.snapshots.LoadSnapshot(ctx, key, at)
+	if err != nil {
+		return nil, nil, err
+	}
+	if reader, isReader := capability[SequenceReader](l.storage); ok && isReader && snap.Sequence > 0 {
+		// The snapshot's own event is read as well, to check that it is still there.
+		tail, tailErr := reader.EventsAfter(ctx, key, snap.Sequence-1)
+		if tailErr != nil {
+			return nil, nil, tailErr
+		}
+		if len(tail) > 0 && tail[0].Sequence == snap.Sequence && tail[0].Hash == snap.Hash {
+			return tail[1:], &snap, nil
+		}
+	}
+
+	events, err := l.storage.Get(ctx, key)
+	if err != nil || !ok || len(events) == 0 || snap.Sequence < events[0].Sequence {
+		return events, nil, err
+	}
+
+	// Sequences are contiguous, so the snapshot's event is found by offset.
+	// A snapshot of another stream, for example from before Clear, is ignored.
+	i := snap.Sequence - events[0].Sequence
+	if i >= uint64(len(events)) || events[i].Sequence != snap.Sequence || events[i].Hash != snap.Hash {
+		return events, nil, nil
+	}
+	return events[i+1:], &snap, nil
+}
+
+// maybeSnapshot saves a snapshot of key if its sequence reached a multiple of
+// the snapshot interval. Callers must hold the chain lock for key.
+func (l *Logger) maybeSnapshot(ctx context.Context, key string, sequence uint64) {
+	if l.snapshots == nil || l.snapshotEvery == 0 || sequence%l.snapshotEvery != 0 {
+		return
+	}
+	_ = l.snapshot(ctx, key)
+}
+
+// snapshot saves the current state of key, starting from its latest snapshot.
+func (l *Logger) snapshot(ctx context.Context, key string) error {
+	events, snap, err := l.sinceSnapshot(ctx, key, time.Time{})
+	if err != nil || len(events) == 0 {
+		return err
+	}
+
+	var state map[string]Value
+	d := l.newDiffer()
+	if snap != nil {
+		state = maps.Clone(snap.State)
+		d.state = maps.Clone(snap.Fields)
+	}
+	for _, e := range events {
+		state = applyEvent(state, e)
+		d.apply(e)
+	}
+
+	last := events[len(events)-1]
+	return l.snapshots.SaveSnapshot(ctx, Snapshot{
+		Key:       key,
+		Sequence:  last.Sequence,
+		Hash:      last.Hash,
+		Timestamp: last.Timestamp,
+		State:     state,
+		Fields:    d.state,
+	})
+}
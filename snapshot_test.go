@@ -0,0 +1,234 @@
+package audit_test
+
+import (
+	"context"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/w0rng/audit"
+	"github.com/w0rng/audit/internal/be"
+)
+
+// logHistory records a create, updates, a delete and a re-create for key.
+func logHistory(logger *audit.Logger, key string) {
+	logger.Create(key, "alice", "created", map[string]audit.Value{
+		"status": audit.PlainValue("new"),
+		"total":  audit.PlainValue(100),
+		"card":   audit.HiddenValueOf("4111"),
+	})
+	logger.Update(key, "bob", "paid", map[string]audit.Value{"status": audit.PlainValue("paid")})
+	logger.Update(key, "bob", "discount", map[string]audit.Value{"total": audit.PlainValue(90)})
+	logger.Delete(key, "carol", "deleted", map[string]audit.Value{})
+	logger.Create(key, "carol", "restored", map[string]audit.Value{"status": audit.PlainValue("restored")})
+	logger.Update(key, "dave", "shipped", map[string]audit.Value{"status": audit.PlainValue("shipped")})
+	logger.Update(key, "dave", "delivered", map[string]audit.Value{"status": audit.PlainValue("delivered")})
+}
+
+func TestLogger_WithSnapshots(t *testing.T) {
+	t.Parallel()
+	storage := audit.NewInMemoryStorage()
+	snapshots := audit.NewInMemorySnapshotStore()
+	logger := audit.New(audit.WithStorage(storage), audit.WithSnapshots(snapshots, 2))
+	replayer := audit.New(audit.WithStorage(storage))
+	logHistory(logger, "order:1")
+
+	snap, ok, err := snapshots.LoadSnapshot(t.Context(), "order:1", time.Time{})
+	be.Err(t, err, nil)
+	be.True(t, ok)
+	be.Equal(t, snap.Sequence, uint64(6))
+	be.Equal(t, snap.State["status"], audit.PlainValue("shipped"))
+
+	events := replayer.Events("order:1")
+	be.Equal(t, logger.CurrentState("order:1"), replayer.CurrentState("order:1"))
+	for _, e := range events {
+		be.Equal(t, logger.StateAt("order:1", e.Timestamp), replayer.StateAt("order:1", e.Timestamp))
+	}
+
+	all := replayer.Logs("order:1")
+	be.Equal(t, logger.LogsRange("order:1", time.Time{}, time.Time{}), all)
+	be.Equal(t, logger.LogsRange("order:1", events[4].Timestamp, events[5].Timestamp), all[4:6])
+	be.Equal(t, logger.LogsRange("order:1", events[6].Timestamp, time.Time{}), all[6:])
+	be.Equal(t, logger.LogsRange("order:1", time.Time{}, events[1].Timestamp), all[:2])
+}
+
+func TestLogger_WithSnapshots_StartsFromSnapshot(t *testing.T) {
+	t.Parallel()
+	storage := audit.NewInMemoryStorage()
+	snapshots := audit.NewInMemorySnapshotStore()
+	logger := audit.New(audit.WithStorage(storage), audit.WithSnapshots(snapshots, 2))
+	logHistory(logger, "order:1")
+
+	// Replace the state of the latest snapshot: a replay that starts from it shows the marker.
+	snap, _, err := snapshots.LoadSnapshot(t.Context(), "order:1", time.Time{})
+	be.Err(t, err, nil)
+	snap.State = map[string]audit.Value{"marker": audit.PlainValue(true)}
+	snap.Fields = map[string]any{"status": "marker"}
+	be.Err(t, snapshots.SaveSnapshot(t.Context(), snap), nil)
+
+	state := logger.CurrentState("order:1")
+	be.Equal(t, state["marker"], audit.PlainValue(true))
+	be.Equal(t, state["status"], audit.PlainValue("delivered"))
+
+	changes := logger.LogsRange("order:1", snap.Timestamp.Add(time.Nanosecond), time.Time{})
+	be.Equal(t, changes[0].Fields, []audit.ChangeField{{Field: "status", From: "marker", To: "delivered"}})
+
+	// A snapshot of a cleared stream no longer matches and is ignored.
+	storage.Clear("order:1")
+	logger = audit.New(audit.WithStorage(storage), audit.WithSnapshots(snapshots, 100))
+	logHistory(logger, "order:1")
+	be.Equal(t, logger.CurrentState("order:1")["marker"], audit.Value{})
+}
+
+func TestLogger_WithSnapshots_Batch(t *testing.T) {
+	t.Parallel()
+	snapshots := audit.NewInMemorySnapshotStore()
+	logger := audit.New(audit.WithSnapshots(snapshots, 3))
+
+	be.Err(t, logger.LogBatch(t.Context(), batchEntries()), nil)
+	be.Err(t, logger.LogBatch(t.Context(), batchEntries()), nil)
+
+	// order:1 reaches sequence 3 in the second batch; the snapshot covers the whole batch.
+	snap, ok, err := snapshots.LoadSnapshot(t.Context(), "order:1", time.Time{})
+	be.Err(t, err, nil)
+	be.True(t, ok)
+	be.Equal(t, snap.Sequence, uint64(4))
+	_, ok, err = snapshots.LoadSnapshot(t.Context(), "order:2", time.Time{})
+	be.Err(t, err, nil)
+	be.True(t, !ok)
+}
+
+// rangeStorage counts full reads and range reads of an InMemoryStorage.
+type rangeStorage struct {
+	audit.StorageV2
+
+	memory      *audit.InMemoryStorage
+	gets, reads atomic.Int32
+}
+
+func newRangeStorage() *rangeStorage {
+	memory := audit.NewInMemoryStorage()
+	return &rangeStorage{StorageV2: audit.AdaptStorage(memory), memory: memory}
+}
+
+func (s *rangeStorage) Get(ctx context.Context, key string) ([]audit.Event, error) {
+	s.gets.Add(1)
+	return s.StorageV2.Get(ctx, key)
+}
+
+func (s *rangeStorage) EventsAfter(ctx context.Context, key string, sequence uint64) ([]audit.Event, error) {
+	s.reads.Add(1)
+	return s.memory.EventsAfter(ctx, key, sequence)
+}
+
+func TestLogger_WithSnapshots_ReadsOnlyNewEvents(t *testing.T) {
+	t.Parallel()
+	storage := newRangeStorage()
+	logger := audit.New(audit.WithStorageV2(storage), audit.WithSnapshots(audit.NewInMemorySnapshotStore(), 2))
+	replayer := audit.New(audit.WithStorage(storage.memory))
+	logHistory(logger, "order:1")
+
+	// Only the first snapshot, which has no snapshot to start from, reads every event.
+	be.Equal(t, storage.gets.Load(), int32(1))
+	be.Equal(t, storage.reads.Load(), int32(2))
+
+	be.Equal(t, logger.CurrentState("order:1"), replayer.CurrentState("order:1"))
+	be.Equal(t, storage.gets.Load(), int32(1))
+
+	// A snapshot whose event is gone is ignored.
+	be.Err(t, logger.Clear(t.Context(), "order:1"), nil)
+	logger.Create("order:1", "erin", "recreated", map[string]audit.Value{"status": audit.PlainValue("new")})
+	be.Equal(t, logger.CurrentState("order:1"), map[string]audit.Value{"status": audit.PlainValue("new")})
+}
+
+func TestSequenceReader(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name    string
+		storage func(t *testing.T) (audit.StorageV2, audit.SequenceReader)
+	}{
+		{"memory", func(*testing.T) (audit.StorageV2, audit.SequenceReader) {
+			memory := audit.NewInMemoryStorage()
+			return audit.AdaptStorage(memory), memory
+		}},
+		{"file", func(t *testing.T) (audit.StorageV2, audit.SequenceReader) {
+			file := openFileStorage(t, filepath.Join(t.TempDir(), "audit.jsonl"), audit.FileStorageOptions{})
+			return file, file
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			storage, reader := tt.storage(t)
+			logger := audit.New(audit.WithStorageV2(storage))
+			logN(logger, "order:1", 5)
+			logN(logger, "order:2", 1)
+
+			for _, tc := range []struct {
+				after uint64
+				want  int
+			}{{0, 5}, {3, 2}, {5, 0}, {9, 0}} {
+				events, err := reader.EventsAfter(t.Context(), "order:1", tc.after)
+				be.Err(t, err, nil)
+				be.Equal(t, len(events), tc.want)
+				for i, e := range events {
+					be.Equal(t, e.Sequence, tc.after+uint64(i)+1)
+				}
+			}
+			events, err := reader.EventsAfter(t.Context(), "missing", 0)
+			be.Err(t, err, nil)
+			be.Equal(t, len(events), 0)
+		})
+	}
+}
+
+func TestInMemorySnapshotStore(t *testing.T) {
+	t.Parallel()
+	store := audit.NewInMemorySnapshotStore()
+	ctx := t.Context()
+	base := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	for _, seq := range []uint64{20, 10, 30} {
+		be.Err(t, store.SaveSnapshot(ctx, audit.Snapshot{
+			Key: "k", Sequence: seq, Timestamp: base.Add(time.Duration(seq) * time.Minute),
+		}), nil)
+	}
+	be.Err(t, store.SaveSnapshot(ctx, audit.Snapshot{Key: "k", Sequence: 20, Hash: "replaced", Timestamp: base.Add(20 * time.Minute)}), nil)
+
+	tests := []struct {
+		name string
+		at   time.Time
+		want uint64
+		ok   bool
+	}{
+		{"latest", time.Time{}, 30, true},
+		{"before all", base, 0, false},
+		{"exact", base.Add(20 * time.Minute), 20, true},
+		{"between", base.Add(25 * time.Minute), 20, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			snap, ok, err := store.LoadSnapshot(ctx, "k", tt.at)
+			be.Err(t, err, nil)
+			be.Equal(t, ok, tt.ok)
+			be.Equal(t, snap.Sequence, tt.want)
+		})
+	}
+
+	snap, _, _ := store.LoadSnapshot(ctx, "k", base.Add(20*time.Minute))
+	be.Equal(t, snap.Hash, "replaced")
+
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	_, _, err := store.LoadSnapshot(canceled, "k", time.Time{})
+	be.Err(t, err, context.Canceled)
+}
+
+func TestSnapshotStoreInterface(t *testing.T) {
+	t.Parallel()
+	var _ audit.SnapshotStore = (*audit.InMemorySnapshotStore)(nil)
+	var _ audit.SequenceReader = (*audit.InMemoryStorage)(nil)
+	var _ audit.SequenceReader = (*audit.FileStorage)(nil)
+}
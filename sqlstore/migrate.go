@@ -0,0 +1,118 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//go:embed migrations
+var migrationsFS embed.FS
+
+// migration is a single schema change loaded from the embedded migrations directory.
+type migration struct {
+	version int
+	name    string
+	sql     string
+}
+
+// migrations returns the dialect's migrations ordered by version.
+// Files are named NNNN_description.sql.
+func (d Dialect) migrations() ([]migration, error) {
+	dir := path.Join("migrations", d.String())
+	entries, err := fs.ReadDir(migrationsFS, dir)
+	if err != nil {
+		return nil, fmt.Errorf("sqlstore: read migrations: %w", err)
+	}
+
+	result := make([]migration, 0, len(entries))
+	for _, entry := range entries {
+		name := entry.Name()
+		prefix, _, ok := strings.Cut(name, "_")
+		if !ok || !strings.HasSuffix(name, ".sql") {
+			continue
+		}
+		version, err := strconv.Atoi(prefix)
+		if err != nil {
+			return nil, fmt.Errorf("sqlstore: bad migration name %q: %w", name, err)
+		}
+		data, err := fs.ReadFile(migrationsFS, path.Join(dir, name))
+		if err != nil {
+			return nil, fmt.Errorf("sqlstore: read migration %q: %w", name, err)
+		}
+		result = append(result, migration{version: version, name: name, sql: string(data)})
+	}
+
+	slices.SortFunc(result, func(a, b migration) int { return a.version - b.version })
+	return result, nil
+}
+
+// Migrate brings the schema up to date. Each pending migration runs in its own
+// transaction and is recorded in the audit_schema_migrations table.
+// It is safe to call Migrate on an already migrated database.
+func (s *Store) Migrate(ctx context.Context) error {
+	_, err := s.db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS audit_schema_migrations (
+    version    INTEGER PRIMARY KEY,
+    applied_at BIGINT  NOT NULL
+)`)
+	if err != nil {
+		return fmt.Errorf("sqlstore: create migrations table: %w", err)
+	}
+
+	current, err := s.schemaVersion(ctx)
+	if err != nil {
+		return err
+	}
+
+	pending, err := s.dialect.migrations()
+	if err != nil {
+		return err
+	}
+	for _, m := range pending {
+		if m.version <= current {
+			continue
+		}
+		if err := s.apply(ctx, m); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// schemaVersion returns the highest applied migration version, or 0.
+func (s *Store) schemaVersion(ctx context.Context) (int, error) {
+	var version sql.NullInt64
+	err := s.db.QueryRowContext(ctx, `SELECT MAX(version) FROM audit_schema_migrations`).Scan(&version)
+	if err != nil {
+		return 0, fmt.Errorf("sqlstore: read schema version: %w", err)
+	}
+	return int(version.Int64), nil
+}
+
+// apply runs a single migration and records it.
+func (s *Store) apply(ctx context.Context, m migration) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("sqlstore: begin migration %s: %w", m.name, err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if _, err := tx.ExecContext(ctx, m.sql); err != nil {
+		return fmt.Errorf("sqlstore: apply migration %s: %w", m.name, err)
+	}
+	query := s.rebind(`INSERT INTO audit_schema_migrations (version, applied_at) VALUES (?, ?)`)
+	if _, err := tx.ExecContext(ctx, query, m.version, time.Now().UnixNano()); err != nil {
+		return fmt.Errorf("sqlstore: record migration %s: %w", m.name, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("sqlstore: commit migration %s: %w", m.name, err)
+	}
+	return nil
+}
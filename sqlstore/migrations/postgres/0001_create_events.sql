@@ -0,0 +1,11 @@
+CREATE TABLE audit_events (
+    id          BIGSERIAL PRIMARY KEY,
+    entity_key  TEXT   NOT NULL,
+    occurred_at BIGINT NOT NULL,
+    action      TEXT   NOT NULL,
+    author      TEXT   NOT NULL,
+    description TEXT   NOT NULL,
+    payload     JSONB  NOT NULL
+);
+
+CREATE INDEX audit_events_entity_key_idx ON audit_events (entity_key, id);
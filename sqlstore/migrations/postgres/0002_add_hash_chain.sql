@@ -0,0 +1,3 @@
+ALTER TABLE audit_events ADD COLUMN sequence BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE audit_events ADD COLUMN prev_hash TEXT NOT NULL DEFAULT '';
+ALTER TABLE audit_events ADD COLUMN hash TEXT NOT NULL DEFAULT '';
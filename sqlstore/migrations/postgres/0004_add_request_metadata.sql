@@ -0,0 +1,2 @@
+ALTER TABLE audit_events ADD COLUMN actor TEXT NOT NULL DEFAULT '';
+ALTER TABLE audit_events ADD COLUMN request_id TEXT NOT NULL DEFAULT '';
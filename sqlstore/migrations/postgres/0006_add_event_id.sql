@@ -0,0 +1 @@
+ALTER TABLE audit_events ADD COLUMN event_id TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,2 @@
+ALTER TABLE audit_events ADD COLUMN idempotency_key TEXT NOT NULL DEFAULT '';
+CREATE INDEX audit_events_idempotency_key_idx ON audit_events (entity_key, idempotency_key) WHERE idempotency_key <> '';
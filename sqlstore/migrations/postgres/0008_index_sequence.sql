@@ -0,0 +1 @@
+CREATE INDEX audit_events_sequence_idx ON audit_events (entity_key, sequence);
@@ -0,0 +1,3 @@
+-- JSONB rewrites numbers such as 1e+21, which breaks the hash chain. JSON keeps
+-- the text as written. Payloads already stored as JSONB cannot be restored.
+ALTER TABLE audit_events ALTER COLUMN payload TYPE JSON USING payload::text::json;
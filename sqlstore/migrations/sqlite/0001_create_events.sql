@@ -0,0 +1,11 @@
+CREATE TABLE audit_events (
+    id          INTEGER PRIMARY KEY AUTOINCREMENT,
+    entity_key  TEXT    NOT NULL,
+    occurred_at INTEGER NOT NULL,
+    action      TEXT    NOT NULL,
+    author      TEXT    NOT NULL,
+    description TEXT    NOT NULL,
+    payload     TEXT    NOT NULL
+);
+
+CREATE INDEX audit_events_entity_key_idx ON audit_events (entity_key, id);
@@ -0,0 +1 @@
+CREATE INDEX audit_events_occurred_at_idx ON audit_events (occurred_at);
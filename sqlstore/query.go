@@ -0,0 +1,214 @@
+package sqlstore
+
+import (
+	"context"
+	"fmt"
+	"iter"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/w0rng/audit"
+)
+
+// Query returns the events selected by q. Key, time, author and action filters,
+// ordering and pagination run in the database; field filtering runs in Go.
+// It implements audit.Querier.
+func (s *Store) Query(ctx context.Context, q audit.Query) ([]audit.KeyedEvent, error) {
+	if err := q.Validate(); err != nil {
+		return nil, err
+	}
+
+	query, args := s.buildQuery(q)
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("sqlstore: query events: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	result := []audit.KeyedEvent{}
+	for rows.Next() {
+		var key string
+		event, err := scanEvent(rows, &key)
+		if err != nil {
+			return nil, err
+		}
+		if event, ok := q.Match(key, event); ok {
+			result = append(result, audit.KeyedEvent{Key: key, Event: event})
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("sqlstore: read events: %w", err)
+	}
+
+	if len(q.Fields) > 0 {
+		result = paginate(result, q.Offset, q.Limit)
+	}
+	return result, nil
+}
+
+// buildQuery translates q into SQL. Pagination is pushed down unless field
+// filtering has to drop rows in Go first.
+func (s *Store) buildQuery(q audit.Query) (string, []any) {
+	var (
+		where []string
+		args  []any
+	)
+	switch {
+	case q.Key != "":
+		where = append(where, "entity_key = ?")
+		args = append(args, q.Key)
+	case q.KeyPrefix != "":
+		// LIKE is case-insensitive in SQLite, so compare the prefix exactly.
+		where = append(where, "substr(entity_key, 1, ?) = ?")
+		args = append(args, utf8.RuneCountInString(q.KeyPrefix), q.KeyPrefix)
+	}
+	if !q.From.IsZero() {
+		where = append(where, "occurred_at >= ?")
+		args = append(args, q.From.UnixNano())
+	}
+	if !q.To.IsZero() {
+		where = append(where, "occurred_at < ?")
+		args = append(args, q.To.UnixNano())
+	}
+	if len(q.Authors) > 0 {
+		where = append(where, "author IN ("+placeholders(len(q.Authors))+")")
+		for _, author := range q.Authors {
+			args = append(args, author)
+		}
+	}
+	if len(q.Actions) > 0 {
+		where = append(where, "action IN ("+placeholders(len(q.Actions))+")")
+		for _, action := range q.Actions {
+			args = append(args, string(action))
+		}
+	}
+
+	var b strings.Builder
+	b.WriteString("SELECT entity_key, " + eventColumns + " FROM audit_events")
+	if len(where) > 0 {
+		b.WriteString(" WHERE " + strings.Join(where, " AND "))
+	}
+	if q.Order == audit.OrderDesc {
+		b.WriteString(" ORDER BY occurred_at DESC, entity_key DESC, id DESC")
+	} else {
+		b.WriteString(" ORDER BY occurred_at, entity_key, id")
+	}
+
+	if len(q.Fields) == 0 {
+		switch {
+		case q.Limit > 0:
+			b.WriteString(" LIMIT ?")
+			args = append(args, q.Limit)
+		case q.Offset > 0 && s.dialect == SQLite:
+			// SQLite only accepts OFFSET after a LIMIT clause.
+			b.WriteString(" LIMIT -1")
+		}
+		if q.Offset > 0 {
+			b.WriteString(" OFFSET ?")
+			args = append(args, q.Offset)
+		}
+	}
+
+	return s.rebind(b.String()), args
+}
+
+// placeholders returns n comma-separated "?" placeholders.
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
+
+// paginate applies offset and limit to events.
+func paginate(events []audit.KeyedEvent, offset, limit int) []audit.KeyedEvent {
+	if offset >= len(events) {
+		return []audit.KeyedEvent{}
+	}
+	events = events[offset:]
+	if limit > 0 && limit < len(events) {
+		events = events[:limit]
+	}
+	return events
+}
+
+// keysPageSize is the number of keys Keys reads per query.
+const keysPageSize = 1000
+
+// Keys iterates over the keys starting with prefix, reading them in pages.
+// It implements audit.KeyLister. Iteration stops early on a database error.
+func (s *Store) Keys(prefix string) iter.Seq[string] {
+	return func(yield func(string) bool) {
+		cursor := ""
+		for {
+			keys, next, err := s.KeysPage(context.Background(), prefix, cursor, keysPageSize)
+			if err != nil {
+				return
+			}
+			for _, key := range keys {
+				if !yield(key) {
+					return
+				}
+			}
+			if next == "" {
+				return
+			}
+			cursor = next
+		}
+	}
+}
+
+// KeysPage returns a page of keys starting with prefix. It implements
+// audit.KeyLister. Keys are compared byte by byte in both dialects.
+func (s *Store) KeysPage(ctx context.Context, prefix, cursor string, limit int) ([]string, string, error) {
+	column := "entity_key"
+	if s.dialect == Postgres {
+		column = `entity_key COLLATE "C"`
+	}
+
+	var (
+		where []string
+		args  []any
+	)
+	if prefix != "" {
+		where = append(where, "substr(entity_key, 1, ?) = ?")
+		args = append(args, utf8.RuneCountInString(prefix), prefix)
+	}
+	if cursor != "" {
+		where = append(where, column+" > ?")
+		args = append(args, cursor)
+	}
+
+	var b strings.Builder
+	b.WriteString("SELECT DISTINCT " + column + " FROM audit_events")
+	if len(where) > 0 {
+		b.WriteString(" WHERE " + strings.Join(where, " AND "))
+	}
+	b.WriteString(" ORDER BY 1")
+	if limit > 0 {
+		// One extra key tells whether there is a next page.
+		b.WriteString(" LIMIT ?")
+		args = append(args, limit+1)
+	}
+
+	rows, err := s.db.QueryContext(ctx, s.rebind(b.String()), args...)
+	if err != nil {
+		return nil, "", fmt.Errorf("sqlstore: query keys: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	keys := []string{}
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			return nil, "", fmt.Errorf("sqlstore: scan key: %w", err)
+		}
+		keys = append(keys, key)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, "", fmt.Errorf("sqlstore: read keys: %w", err)
+	}
+
+	if limit <= 0 || len(keys) <= limit {
+		return keys, "", nil
+	}
+	keys = keys[:limit]
+	return keys, keys[limit-1], nil
+}
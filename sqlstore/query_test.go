@@ -0,0 +1,145 @@
+package sqlstore_test
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/w0rng/audit"
+	"github.com/w0rng/audit/internal/be"
+	"github.com/w0rng/audit/sqlstore"
+)
+
+func TestStore_Query(t *testing.T) {
+	t.Parallel()
+	store := newStore(t)
+	ctx := t.Context()
+	base := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	seed := []struct {
+		key    string
+		action audit.Action
+		author string
+		field  string
+	}{
+		{"order:1", audit.ActionCreate, "alice", "status"},
+		{"order:2", audit.ActionCreate, "bob", "status"},
+		{"order:1", audit.ActionUpdate, "bob", "total"},
+		{"Order:3", audit.ActionCreate, "alice", "status"},
+		{"order_4", audit.ActionCreate, "alice", "status"},
+		{"order:2", audit.ActionDelete, "alice", "status"},
+	}
+	for i, e := range seed {
+		be.Err(t, store.Store(ctx, e.key, audit.Event{
+			Timestamp: base.Add(time.Duration(i) * time.Hour),
+			Action:    e.action,
+			Author:    e.author,
+			Payload:   map[string]audit.Value{e.field: audit.PlainValue(i)},
+		}), nil)
+	}
+
+	type hit struct {
+		key    string
+		action audit.Action
+	}
+	tests := []struct {
+		name  string
+		query audit.Query
+		want  []hit
+	}{
+		{
+			name:  "prefix is case sensitive and literal",
+			query: audit.Query{KeyPrefix: "order:"},
+			want: []hit{
+				{"order:1", audit.ActionCreate}, {"order:2", audit.ActionCreate},
+				{"order:1", audit.ActionUpdate}, {"order:2", audit.ActionDelete},
+			},
+		},
+		{
+			name:  "authors and actions",
+			query: audit.Query{Authors: []string{"alice"}, Actions: []audit.Action{audit.ActionDelete, audit.ActionUpdate}},
+			want:  []hit{{"order:2", audit.ActionDelete}},
+		},
+		{
+			name:  "time window descending",
+			query: audit.Query{From: base.Add(time.Hour), To: base.Add(3 * time.Hour), Order: audit.OrderDesc},
+			want:  []hit{{"order:1", audit.ActionUpdate}, {"order:2", audit.ActionCreate}},
+		},
+		{
+			name:  "offset without limit",
+			query: audit.Query{Key: "order:2", Offset: 1},
+			want:  []hit{{"order:2", audit.ActionDelete}},
+		},
+		{
+			name:  "fields with pagination",
+			query: audit.Query{Fields: []string{"status"}, Offset: 1, Limit: 2},
+			want:  []hit{{"order:2", audit.ActionCreate}, {"Order:3", audit.ActionCreate}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			events, err := store.Query(ctx, tt.query)
+			be.Err(t, err, nil)
+
+			got := make([]hit, 0, len(events))
+			for _, e := range events {
+				got = append(got, hit{e.Key, e.Action})
+			}
+			be.Equal(t, got, tt.want)
+		})
+	}
+}
+
+func TestStore_Query_ThroughLogger(t *testing.T) {
+	t.Parallel()
+	logger := audit.New(audit.WithStorageV2(newStore(t)))
+	ctx := t.Context()
+
+	be.Err(t, logger.CreateContext(ctx, "order:1", "alice", "Created", map[string]audit.Value{}), nil)
+	be.Err(t, logger.DeleteContext(ctx, "order:1", "bob", "Deleted", map[string]audit.Value{}), nil)
+
+	events, err := logger.Query(ctx, audit.Query{KeyPrefix: "order:", Authors: []string{"bob"}})
+	be.Err(t, err, nil)
+	be.Equal(t, len(events), 1)
+	be.Equal(t, events[0].Action, audit.ActionDelete)
+	be.Equal(t, events[0].Sequence, uint64(2))
+
+	_, err = logger.Query(ctx, audit.Query{Limit: -1})
+	be.Err(t, err, audit.ErrInvalidQuery)
+}
+
+func TestStore_QuerierInterface(t *testing.T) {
+	var _ audit.Querier = (*sqlstore.Store)(nil)
+}
+
+func TestStore_KeysPage(t *testing.T) {
+	t.Parallel()
+	var _ audit.KeyLister = (*sqlstore.Store)(nil)
+	store := newStore(t)
+	logger := audit.New(audit.WithStorageV2(store))
+	ctx := t.Context()
+	for _, key := range []string{"order:3", "user:1", "order:1", "order:2", "Order:9", "order:10", "orders", "order:1"} {
+		be.Err(t, store.Store(ctx, key, audit.Event{}), nil)
+	}
+	be.Err(t, store.Clear(ctx, "order:3"), nil)
+
+	be.Equal(t, slices.Collect(store.Keys("user")), []string{"user:1"})
+	be.Equal(t, slices.Collect(store.Keys("missing")), []string(nil))
+
+	page, next, err := logger.KeysPage(ctx, "order:", "", 2)
+	be.Err(t, err, nil)
+	be.Equal(t, page, []string{"order:1", "order:10"})
+	be.Equal(t, next, "order:10")
+
+	page, next, err = logger.KeysPage(ctx, "order:", next, 2)
+	be.Err(t, err, nil)
+	be.Equal(t, page, []string{"order:2"})
+	be.Equal(t, next, "")
+
+	all, next, err := logger.KeysPage(ctx, "", "", 0)
+	be.Err(t, err, nil)
+	be.Equal(t, all, []string{"Order:9", "order:1", "order:10", "order:2", "orders", "user:1"})
+	be.Equal(t, next, "")
+}
@@ -0,0 +1,307 @@
+// Package sqlstore provides an audit.StorageV2 implementation on top of database/sql.
+//
+// Events are stored in the audit_events table, one row per event, keyed by
+// entity key with the payload encoded as JSON. The schema is created and
+// upgraded by embedded migrations, so no manual setup is required.
+//
+// The package does not import any database driver. Register one in your
+// program and pass the opened *sql.DB to New:
+//
+//	import _ "modernc.org/sqlite"
+//
+//	db, err := sql.Open("sqlite", "audit.db")
+//	if err != nil {
+//	    return err
+//	}
+//	store, err := sqlstore.New(ctx, db, sqlstore.Options{Dialect: sqlstore.SQLite})
+//	if err != nil {
+//	    return err
+//	}
+//	logger := audit.New(audit.WithStorageV2(store))
+package sqlstore
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"iter"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/w0rng/audit"
+)
+
+// Dialect selects the SQL flavor used for placeholders and migrations.
+type Dialect int
+
+const (
+	// SQLite uses "?" placeholders.
+	SQLite Dialect = iota
+
+	// Postgres uses "$1", "$2", ... placeholders.
+	Postgres
+)
+
+// String returns the dialect name.
+func (d Dialect) String() string {
+	switch d {
+	case SQLite:
+		return "sqlite"
+	case Postgres:
+		return "postgres"
+	default:
+		return "dialect(" + strconv.Itoa(int(d)) + ")"
+	}
+}
+
+// Placeholder returns the bind parameter for the n-th (1-based) argument.
+func (d Dialect) Placeholder(n int) string {
+	if d == Postgres {
+		return "$" + strconv.Itoa(n)
+	}
+	return "?"
+}
+
+// Options configures a Store.
+type Options struct {
+	// Dialect selects placeholder style and migrations. Defaults to SQLite.
+	Dialect Dialect
+
+	// SkipMigrations disables running migrations in New.
+	// Use it when the schema is managed externally; call Migrate explicitly otherwise.
+	SkipMigrations bool
+}
+
+// Store is an audit.StorageV2 backed by a *sql.DB.
+// It is safe for concurrent use as long as the underlying driver is.
+type Store struct {
+	db      *sql.DB
+	dialect Dialect
+}
+
+// New creates a Store over db and, unless opts.SkipMigrations is set,
+// runs pending migrations.
+func New(ctx context.Context, db *sql.DB, opts Options) (*Store, error) {
+	if opts.Dialect != SQLite && opts.Dialect != Postgres {
+		return nil, fmt.Errorf("sqlstore: unsupported dialect %s", opts.Dialect)
+	}
+
+	s := &Store{db: db, dialect: opts.Dialect}
+	if !opts.SkipMigrations {
+		if err := s.Migrate(ctx); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// rebind rewrites "?" placeholders in query for the store's dialect.
+func (s *Store) rebind(query string) string {
+	if s.dialect == SQLite {
+		return query
+	}
+
+	var b strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			b.WriteString(s.dialect.Placeholder(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// insertEvent is the statement that stores one event.
+const insertEvent = `INSERT INTO audit_events
+    (entity_key, occurred_at, action, author, description, payload, sequence, prev_hash, hash,
+     actor, request_id, tx_id, event_id, idempotency_key)
+    VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+
+// insertArgs returns the insertEvent arguments for event.
+func insertArgs(key string, event audit.Event) ([]any, error) {
+	payload, err := json.Marshal(event.Payload)
+	if err != nil {
+		return nil, fmt.Errorf("sqlstore: encode payload: %w", err)
+	}
+
+	var actor []byte
+	if event.Actor != nil {
+		if actor, err = json.Marshal(event.Actor); err != nil {
+			return nil, fmt.Errorf("sqlstore: encode actor: %w", err)
+		}
+	}
+
+	return []any{
+		key, event.Timestamp.UnixNano(), string(event.Action), event.Author, event.Description, string(payload),
+		int64(event.Sequence), event.PrevHash, event.Hash, //nolint:gosec // sequences never exceed MaxInt64.
+		string(actor), event.RequestID, event.TxID, event.ID, event.IdempotencyKey,
+	}, nil
+}
+
+// Store inserts an event for the given key.
+func (s *Store) Store(ctx context.Context, key string, event audit.Event) error {
+	args, err := insertArgs(key, event)
+	if err != nil {
+		return err
+	}
+	if _, err := s.db.ExecContext(ctx, s.rebind(insertEvent), args...); err != nil {
+		return fmt.Errorf("sqlstore: insert event: %w", err)
+	}
+	return nil
+}
+
+// StoreBatch inserts events in a single transaction with a prepared statement,
+// so either all of them are stored or none. It implements audit.BatchStorer.
+func (s *Store) StoreBatch(ctx context.Context, events []audit.KeyedEvent) error {
+	tx, err := s.BeginTx(ctx)
+	if err != nil {
+		return err
+	}
+	for _, e := range events {
+		if err := tx.Store(ctx, e.Key, e.Event); err != nil {
+			_ = tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// Get retrieves all events for a given key in insertion order.
+// Returns an empty slice if the key doesn't exist.
+func (s *Store) Get(ctx context.Context, key string) ([]audit.Event, error) {
+	return collectEvents(s.Stream(ctx, key))
+}
+
+// EventsAfter returns the events of key with a sequence greater than sequence,
+// in insertion order. It implements audit.SequenceReader.
+func (s *Store) EventsAfter(ctx context.Context, key string, sequence uint64) ([]audit.Event, error) {
+	return collectEvents(s.stream(ctx, `SELECT `+eventColumns+`
+    FROM audit_events WHERE entity_key = ? AND sequence > ? ORDER BY id`,
+		key, int64(sequence))) //nolint:gosec // sequences never exceed MaxInt64.
+}
+
+// Stream iterates over the events for key in insertion order, decoding each
+// row as it is read. The query's connection is held until the iteration ends.
+// It implements audit.StreamingStorage.
+func (s *Store) Stream(ctx context.Context, key string) iter.Seq2[audit.Event, error] {
+	return s.stream(ctx, `SELECT `+eventColumns+`
+    FROM audit_events WHERE entity_key = ? ORDER BY id`, key)
+}
+
+// stream iterates over the events selected by query.
+func (s *Store) stream(ctx context.Context, query string, args ...any) iter.Seq2[audit.Event, error] {
+	return func(yield func(audit.Event, error) bool) {
+		rows, err := s.db.QueryContext(ctx, s.rebind(query), args...)
+		if err != nil {
+			yield(audit.Event{}, fmt.Errorf("sqlstore: query events: %w", err))
+			return
+		}
+		defer func() { _ = rows.Close() }()
+
+		for rows.Next() {
+			event, err := scanEvent(rows)
+			if err != nil {
+				yield(audit.Event{}, err)
+				return
+			}
+			if !yield(event, nil) {
+				return
+			}
+		}
+		if err := rows.Err(); err != nil {
+			yield(audit.Event{}, fmt.Errorf("sqlstore: read events: %w", err))
+		}
+	}
+}
+
+// collectEvents reads every event from events into a slice.
+func collectEvents(events iter.Seq2[audit.Event, error]) ([]audit.Event, error) {
+	result := []audit.Event{}
+	for event, err := range events {
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, event)
+	}
+	return result, nil
+}
+
+const eventColumns = `occurred_at, action, author, description, payload, sequence, prev_hash, hash, ` +
+	`actor, request_id, tx_id, event_id, idempotency_key`
+
+// scanEvent decodes the current row into an Event. Extra destinations for
+// columns selected before eventColumns are scanned first.
+func scanEvent(rows *sql.Rows, extra ...any) (audit.Event, error) {
+	var (
+		occurredAt int64
+		action     string
+		event      audit.Event
+		payload    []byte
+		sequence   int64
+		actor      []byte
+	)
+	dest := append(extra, &occurredAt, &action, &event.Author, &event.Description, &payload,
+		&sequence, &event.PrevHash, &event.Hash, &actor, &event.RequestID, &event.TxID, &event.ID, &event.IdempotencyKey)
+	err := rows.Scan(dest...)
+	if err != nil {
+		return audit.Event{}, fmt.Errorf("sqlstore: scan event: %w", err)
+	}
+	// Numbers keep their exact text, so hashes of large integers still match.
+	dec := json.NewDecoder(bytes.NewReader(payload))
+	dec.UseNumber()
+	if err := dec.Decode(&event.Payload); err != nil {
+		return audit.Event{}, fmt.Errorf("sqlstore: decode payload: %w", err)
+	}
+	if len(actor) > 0 {
+		event.Actor = new(audit.Actor)
+		if err := json.Unmarshal(actor, event.Actor); err != nil {
+			return audit.Event{}, fmt.Errorf("sqlstore: decode actor: %w", err)
+		}
+	}
+	event.Timestamp = time.Unix(0, occurredAt).UTC()
+	event.Action = audit.Action(action)
+	event.Sequence = uint64(sequence) //nolint:gosec // stored from a uint64.
+	return event, nil
+}
+
+// Has checks if any events exist for a given key.
+func (s *Store) Has(ctx context.Context, key string) (bool, error) {
+	return s.exists(ctx, `SELECT 1 FROM audit_events WHERE entity_key = ? LIMIT 1`, key)
+}
+
+// HasIdempotencyKey reports whether an event with idempotencyKey is stored
+// under key. It implements audit.Deduplicator.
+func (s *Store) HasIdempotencyKey(ctx context.Context, key, idempotencyKey string) (bool, error) {
+	return s.exists(ctx, `SELECT 1 FROM audit_events WHERE entity_key = ? AND idempotency_key = ? LIMIT 1`,
+		key, idempotencyKey)
+}
+
+// exists reports whether query, which selects a single 1, returns a row.
+func (s *Store) exists(ctx context.Context, query string, args ...any) (bool, error) {
+	var one int
+	err := s.db.QueryRowContext(ctx, s.rebind(query), args...).Scan(&one)
+	switch {
+	case err == nil:
+		return true, nil
+	case errors.Is(err, sql.ErrNoRows):
+		return false, nil
+	default:
+		return false, fmt.Errorf("sqlstore: check key: %w", err)
+	}
+}
+
+// Clear removes all events for a given key.
+func (s *Store) Clear(ctx context.Context, key string) error {
+	query := s.rebind(`DELETE FROM audit_events WHERE entity_key = ?`)
+	if _, err := s.db.ExecContext(ctx, query, key); err != nil {
+		return fmt.Errorf("sqlstore: delete events: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,371 @@
+package sqlstore_test
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	_ "modernc.org/sqlite"
+
+	"github.com/w0rng/audit"
+	"github.com/w0rng/audit/internal/be"
+	"github.com/w0rng/audit/sqlstore"
+)
+
+func openDB(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "audit.db")+"?_pragma=busy_timeout(5000)")
+	be.Err(t, err, nil)
+	t.Cleanup(func() { _ = db.Close() })
+	return db
+}
+
+func newStore(t *testing.T) *sqlstore.Store {
+	t.Helper()
+	store, err := sqlstore.New(t.Context(), openDB(t), sqlstore.Options{Dialect: sqlstore.SQLite})
+	be.Err(t, err, nil)
+	return store
+}
+
+func TestStore_StoreGet(t *testing.T) {
+	t.Parallel()
+	store := newStore(t)
+	ctx := t.Context()
+	now := time.Now()
+
+	be.Err(t, store.Store(ctx, "user:1", audit.Event{
+		Timestamp:   now,
+		Action:      audit.ActionCreate,
+		Author:      "admin",
+		Description: "User created",
+		Payload: map[string]audit.Value{
+			"email":    audit.PlainValue("user@example.com"),
+			"password": audit.HiddenValue(),
+		},
+	}), nil)
+	be.Err(t, store.Store(ctx, "user:2", audit.Event{Timestamp: now, Action: audit.ActionCreate}), nil)
+	be.Err(t, store.Store(ctx, "user:1", audit.Event{Timestamp: now, Action: audit.ActionUpdate, Author: "bob"}), nil)
+
+	events, err := store.Get(ctx, "user:1")
+	be.Err(t, err, nil)
+	be.Equal(t, len(events), 2)
+	be.Equal(t, events[0].Timestamp, now)
+	be.Equal(t, events[0].Action, audit.ActionCreate)
+	be.Equal(t, events[0].Author, "admin")
+	be.Equal(t, events[0].Description, "User created")
+	be.Equal(t, events[0].Payload["email"].Data, any("user@example.com"))
+	be.True(t, events[0].Payload["password"].Hidden)
+	be.Equal(t, events[1].Author, "bob")
+
+	events, err = store.Get(ctx, "missing")
+	be.Err(t, err, nil)
+	be.Equal(t, len(events), 0)
+}
+
+func TestStore_Stream(t *testing.T) {
+	t.Parallel()
+	store := newStore(t)
+	ctx := t.Context()
+	for _, author := range []string{"alice", "bob", "carol"} {
+		be.Err(t, store.Store(ctx, "user:1", audit.Event{Timestamp: time.Now(), Action: audit.ActionUpdate, Author: author}), nil)
+	}
+
+	var authors []string
+	for event, err := range store.Stream(ctx, "user:1") {
+		be.Err(t, err, nil)
+		authors = append(authors, event.Author)
+		if len(authors) == 2 {
+			break
+		}
+	}
+	be.Equal(t, authors, []string{"alice", "bob"})
+
+	// The connection is released after an early stop.
+	events, err := store.Get(ctx, "user:1")
+	be.Err(t, err, nil)
+	be.Equal(t, len(events), 3)
+
+	var _ audit.StreamingStorage = (*sqlstore.Store)(nil)
+}
+
+func TestStore_HasClear(t *testing.T) {
+	t.Parallel()
+	store := newStore(t)
+	ctx := t.Context()
+
+	be.Err(t, store.Store(ctx, "key1", audit.Event{}), nil)
+	be.Err(t, store.Store(ctx, "key2", audit.Event{}), nil)
+
+	has, err := store.Has(ctx, "key1")
+	be.Err(t, err, nil)
+	be.True(t, has)
+
+	be.Err(t, store.Clear(ctx, "key1"), nil)
+
+	has, err = store.Has(ctx, "key1")
+	be.Err(t, err, nil)
+	be.True(t, !has)
+
+	has, err = store.Has(ctx, "key2")
+	be.Err(t, err, nil)
+	be.True(t, has)
+}
+
+func TestStore_MigrateIdempotent(t *testing.T) {
+	t.Parallel()
+	db := openDB(t)
+	ctx := t.Context()
+
+	store, err := sqlstore.New(ctx, db, sqlstore.Options{})
+	be.Err(t, err, nil)
+	be.Err(t, store.Store(ctx, "key", audit.Event{Author: "kept"}), nil)
+
+	store, err = sqlstore.New(ctx, db, sqlstore.Options{})
+	be.Err(t, err, nil)
+	be.Err(t, store.Migrate(ctx), nil)
+
+	events, err := store.Get(ctx, "key")
+	be.Err(t, err, nil)
+	be.Equal(t, len(events), 1)
+	be.Equal(t, events[0].Author, "kept")
+}
+
+func TestStore_WithLogger(t *testing.T) {
+	t.Parallel()
+	logger := audit.New(audit.WithStorageV2(newStore(t)))
+	ctx := t.Context()
+
+	be.Err(t, logger.CreateContext(ctx, "order:1", "alice", "Created", map[string]audit.Value{
+		"status": audit.PlainValue("pending"),
+	}), nil)
+	be.Err(t, logger.UpdateContext(ctx, "order:1", "bob", "Approved", map[string]audit.Value{
+		"status": audit.PlainValue("approved"),
+	}), nil)
+
+	changes, err := logger.LogsContext(ctx, "order:1")
+	be.Err(t, err, nil)
+	be.Equal(t, len(changes), 2)
+	be.Equal(t, changes[1].Fields[0].From, any("pending"))
+	be.Equal(t, changes[1].Fields[0].To, any("approved"))
+}
+
+func TestStore_HashChainRoundTrip(t *testing.T) {
+	t.Parallel()
+	store := newStore(t)
+	logger := audit.New(audit.WithStorageV2(store))
+	ctx := t.Context()
+
+	be.Err(t, logger.CreateContext(ctx, "user:1", "admin", "Created", map[string]audit.Value{
+		"age":      audit.PlainValue(42),
+		"big":      audit.PlainValue(int64(9007199254740993)),
+		"password": audit.HiddenValueOf("hunter2"),
+	}), nil)
+	be.Err(t, logger.UpdateContext(ctx, "user:1", "admin", "Updated", map[string]audit.Value{
+		"age": audit.PlainValue(43),
+	}), nil)
+
+	events, err := store.Get(ctx, "user:1")
+	be.Err(t, err, nil)
+	be.Equal(t, events[1].Sequence, uint64(2))
+	be.Equal(t, events[1].PrevHash, events[0].Hash)
+	be.Equal(t, len(events[0].Payload["password"].Digest), 64)
+	be.Equal(t, events[0].Payload["big"].Data, any(json.Number("9007199254740993")))
+	be.Err(t, logger.VerifyContext(ctx, "user:1"), nil)
+}
+
+func TestStore_ContextMetadataRoundTrip(t *testing.T) {
+	t.Parallel()
+	store := newStore(t)
+	logger := audit.New(audit.WithStorageV2(store))
+	actor := audit.Actor{ID: "u-1", Type: "user", IP: "10.0.0.1"}
+	ctx := audit.WithRequestID(audit.WithActor(t.Context(), actor), "req-1")
+
+	be.Err(t, logger.CreateContext(ctx, "user:1", "", "Created", map[string]audit.Value{}), nil)
+	be.Err(t, logger.CreateContext(t.Context(), "user:2", "admin", "Created", map[string]audit.Value{}), nil)
+
+	events, err := store.Get(ctx, "user:1")
+	be.Err(t, err, nil)
+	be.Equal(t, events[0].Author, "u-1")
+	be.Equal(t, *events[0].Actor, actor)
+	be.Equal(t, events[0].RequestID, "req-1")
+	be.Equal(t, len(events[0].ID), 26)
+	be.Err(t, logger.VerifyContext(ctx, "user:1"), nil)
+
+	events, err = store.Get(ctx, "user:2")
+	be.Err(t, err, nil)
+	be.Equal(t, events[0].Actor, nil)
+}
+
+func TestStore_StoreBatch(t *testing.T) {
+	t.Parallel()
+	var _ audit.BatchStorer = (*sqlstore.Store)(nil)
+	store := newStore(t)
+	logger := audit.New(audit.WithStorageV2(store))
+	ctx := t.Context()
+
+	entries := make([]audit.LogEntry, 0, 10)
+	for i := range 10 {
+		entries = append(entries, audit.LogEntry{
+			Key:     fmt.Sprintf("order:%d", i%3),
+			Action:  audit.ActionUpdate,
+			Author:  "importer",
+			Payload: map[string]audit.Value{"n": audit.PlainValue(i)},
+		})
+	}
+	be.Err(t, logger.LogBatch(ctx, entries), nil)
+
+	events, err := store.Get(ctx, "order:0")
+	be.Err(t, err, nil)
+	be.Equal(t, len(events), 4)
+	be.Err(t, logger.VerifyContext(ctx, "order:0"), nil)
+
+	// A failing batch stores nothing.
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	be.Err(t, store.StoreBatch(canceled, []audit.KeyedEvent{{Key: "order:9"}}), context.Canceled)
+	has, err := store.Has(ctx, "order:9")
+	be.Err(t, err, nil)
+	be.True(t, !has)
+}
+
+func TestStore_Tx(t *testing.T) {
+	t.Parallel()
+	var _ audit.TxStorage = (*sqlstore.Store)(nil)
+	store := newStore(t)
+	logger := audit.New(audit.WithStorageV2(store))
+	ctx := t.Context()
+
+	tx := logger.Begin(ctx)
+	tx.Create("order:1", "alice", "Created", map[string]audit.Value{})
+	tx.Create("payment:1", "alice", "Captured", map[string]audit.Value{})
+	be.Err(t, tx.Commit(), nil)
+
+	events, err := store.Get(ctx, "payment:1")
+	be.Err(t, err, nil)
+	be.Equal(t, len(events), 1)
+	be.Equal(t, events[0].TxID, tx.ID())
+	be.Err(t, logger.VerifyContext(ctx, "payment:1"), nil)
+
+	// Rolled back storage transactions leave no trace.
+	stx, err := store.BeginTx(ctx)
+	be.Err(t, err, nil)
+	be.Err(t, stx.Store(ctx, "order:2", audit.Event{}), nil)
+	be.Err(t, stx.Rollback(), nil)
+	has, err := store.Has(ctx, "order:2")
+	be.Err(t, err, nil)
+	be.True(t, !has)
+}
+
+func TestStore_IdempotencyKey(t *testing.T) {
+	t.Parallel()
+	var _ audit.Deduplicator = (*sqlstore.Store)(nil)
+	store := newStore(t)
+	logger := audit.New(audit.WithStorageV2(store))
+	ctx := audit.WithIdempotencyKey(t.Context(), "msg-1")
+
+	for range 2 {
+		be.Err(t, logger.UpdateContext(ctx, "order:1", "alice", "Paid", map[string]audit.Value{}), nil)
+	}
+	be.Err(t, logger.UpdateContext(ctx, "order:2", "alice", "Paid", map[string]audit.Value{}), nil)
+
+	events, err := store.Get(ctx, "order:1")
+	be.Err(t, err, nil)
+	be.Equal(t, len(events), 1)
+	be.Equal(t, events[0].IdempotencyKey, "msg-1")
+	be.Err(t, logger.VerifyContext(ctx, "order:1"), nil)
+
+	has, err := store.HasIdempotencyKey(ctx, "order:2", "msg-2")
+	be.Err(t, err, nil)
+	be.True(t, !has)
+}
+
+func TestStore_EventsAfter(t *testing.T) {
+	t.Parallel()
+	var _ audit.SequenceReader = (*sqlstore.Store)(nil)
+	store := newStore(t)
+	snapshots := audit.NewInMemorySnapshotStore()
+	logger := audit.New(audit.WithStorageV2(store), audit.WithSnapshots(snapshots, 2))
+	ctx := t.Context()
+	for i := range 5 {
+		be.Err(t, logger.UpdateContext(ctx, "order:1", "alice", "Updated", map[string]audit.Value{
+			"n": audit.PlainValue(i),
+		}), nil)
+	}
+	be.Err(t, logger.UpdateContext(ctx, "order:2", "alice", "Updated", map[string]audit.Value{}), nil)
+
+	events, err := store.EventsAfter(ctx, "order:1", 3)
+	be.Err(t, err, nil)
+	be.Equal(t, len(events), 2)
+	be.Equal(t, events[0].Sequence, uint64(4))
+	be.Equal(t, events[1].Sequence, uint64(5))
+
+	snap, ok, err := snapshots.LoadSnapshot(ctx, "order:1", time.Time{})
+	be.Err(t, err, nil)
+	be.True(t, ok)
+	be.Equal(t, snap.Sequence, uint64(4))
+	state, err := logger.CurrentStateContext(ctx, "order:1")
+	be.Err(t, err, nil)
+	be.True(t, audit.Equal(state["n"].Data, 4))
+}
+
+func TestStore_Concurrency(t *testing.T) {
+	t.Parallel()
+	store := newStore(t)
+	ctx := t.Context()
+	const goroutines = 10
+	const eventsPerGoroutine = 5
+
+	var wg sync.WaitGroup
+	for i := range goroutines {
+		wg.Go(func() {
+			for range eventsPerGoroutine {
+				if err := store.Store(ctx, fmt.Sprintf("key:%d", i), audit.Event{}); err != nil {
+					t.Error(err)
+				}
+			}
+		})
+	}
+	wg.Wait()
+
+	for i := range goroutines {
+		events, err := store.Get(ctx, fmt.Sprintf("key:%d", i))
+		be.Err(t, err, nil)
+		be.Equal(t, len(events), eventsPerGoroutine)
+	}
+}
+
+func TestNew_UnsupportedDialect(t *testing.T) {
+	t.Parallel()
+	_, err := sqlstore.New(t.Context(), openDB(t), sqlstore.Options{Dialect: sqlstore.Dialect(42)})
+	be.Err(t, err, "unsupported dialect")
+}
+
+func TestDialect_Placeholder(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		dialect sqlstore.Dialect
+		n       int
+		want    string
+	}{
+		{sqlstore.SQLite, 1, "?"},
+		{sqlstore.SQLite, 3, "?"},
+		{sqlstore.Postgres, 1, "$1"},
+		{sqlstore.Postgres, 3, "$3"},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%s/%d", tt.dialect, tt.n), func(t *testing.T) {
+			t.Parallel()
+			be.Equal(t, tt.dialect.Placeholder(tt.n), tt.want)
+		})
+	}
+}
+
+func TestStoreInterface(t *testing.T) {
+	var _ audit.StorageV2 = (*sqlstore.Store)(nil)
+}
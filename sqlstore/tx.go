@@ -0,0 +1,60 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/w0rng/audit"
+)
+
+// Tx is a database transaction that stores audit events. It implements audit.StorageTx.
+type Tx struct {
+	tx   *sql.Tx
+	stmt *sql.Stmt
+}
+
+// BeginTx starts a database transaction for storing events atomically.
+// It implements audit.TxStorage.
+func (s *Store) BeginTx(ctx context.Context) (audit.StorageTx, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("sqlstore: begin: %w", err)
+	}
+	stmt, err := tx.PrepareContext(ctx, s.rebind(insertEvent))
+	if err != nil {
+		_ = tx.Rollback()
+		return nil, fmt.Errorf("sqlstore: prepare insert: %w", err)
+	}
+	return &Tx{tx: tx, stmt: stmt}, nil
+}
+
+// Store inserts an event for the given key within the transaction.
+func (t *Tx) Store(ctx context.Context, key string, event audit.Event) error {
+	args, err := insertArgs(key, event)
+	if err != nil {
+		return err
+	}
+	if _, err := t.stmt.ExecContext(ctx, args...); err != nil {
+		return fmt.Errorf("sqlstore: insert event: %w", err)
+	}
+	return nil
+}
+
+// Commit makes the stored events visible.
+func (t *Tx) Commit() error {
+	_ = t.stmt.Close()
+	if err := t.tx.Commit(); err != nil {
+		return fmt.Errorf("sqlstore: commit: %w", err)
+	}
+	return nil
+}
+
+// Rollback discards the stored events.
+func (t *Tx) Rollback() error {
+	_ = t.stmt.Close()
+	if err := t.tx.Rollback(); err != nil {
+		return fmt.Errorf("sqlstore: rollback: %w", err)
+	}
+	return nil
+}
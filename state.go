@@ -0,0 +1,88 @@
+package audit
+
+import (
+	"context"
+	"maps"
+	"time"
+)
+
+// StateAt reconstructs the fields of the entity stored under key as of time t
+// by replaying its events with timestamps at or before t. With WithSnapshots,
+// the replay starts from the newest snapshot taken at or before t.
+//
+// Update payloads overwrite individual fields. Hidden fields keep their Hidden
+// flag and digest but never their data. A delete event removes the entity, so
+// StateAt returns nil if the entity did not exist at t: before its first event
+// or after a delete that was not followed by a new create or update.
+//
+// Storage errors are discarded and yield a nil result; use StateAtContext to observe them.
+func (l *Logger) StateAt(key string, t time.Time) map[string]Value {
+	state, _ := l.StateAtContext(context.Background(), key, t)
+	return state
+}
+
+// StateAtContext is like StateAt but honors ctx and returns the storage error, if any.
+func (l *Logger) StateAtContext(ctx context.Context, key string, t time.Time) (map[string]Value, error) {
+	events, snap, err := l.sinceSnapshot(ctx, key, t)
+	if err != nil {
+		return nil, err
+	}
+
+	return replay(snapshotState(snap), events, t), nil
+}
+
+// CurrentState reconstructs the latest fields of the entity stored under key.
+// It follows the same rules as StateAt.
+//
+// Storage errors are discarded and yield a nil result; use CurrentStateContext to observe them.
+func (l *Logger) CurrentState(key string) map[string]Value {
+	state, _ := l.CurrentStateContext(context.Background(), key)
+	return state
+}
+
+// CurrentStateContext is like CurrentState but honors ctx and returns the storage error, if any.
+func (l *Logger) CurrentStateContext(ctx context.Context, key string) (map[string]Value, error) {
+	events, snap, err := l.sinceSnapshot(ctx, key, time.Time{})
+	if err != nil {
+		return nil, err
+	}
+
+	return replay(snapshotState(snap), events, time.Time{}), nil
+}
+
+// snapshotState returns a copy of the state recorded by snap, or nil without a snapshot.
+func snapshotState(snap *Snapshot) map[string]Value {
+	if snap == nil {
+		return nil
+	}
+	return maps.Clone(snap.State)
+}
+
+// replay folds events into state, skipping events after until.
+// A zero until replays every event.
+func replay(state map[string]Value, events []Event, until time.Time) map[string]Value {
+	for _, e := range events {
+		if !until.IsZero() && e.Timestamp.After(until) {
+			continue
+		}
+		state = applyEvent(state, e)
+	}
+	return state
+}
+
+// applyEvent returns state with e applied. A nil state means the entity does not exist.
+func applyEvent(state map[string]Value, e Event) map[string]Value {
+	if e.Action == ActionDelete {
+		return nil
+	}
+	if state == nil {
+		state = make(map[string]Value, len(e.Payload))
+	}
+	for field, val := range e.Payload {
+		if val.Hidden {
+			val = Value{Hidden: true, Digest: val.Digest}
+		}
+		state[field] = val
+	}
+	return state
+}
@@ -0,0 +1,129 @@
+package audit_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/w0rng/audit"
+	"github.com/w0rng/audit/internal/be"
+)
+
+func TestLogger_StateAt(t *testing.T) {
+	t.Parallel()
+	base := time.Date(2025, 3, 1, 14, 0, 0, 0, time.UTC)
+	storage := audit.NewInMemoryStorage()
+	logger := audit.New(audit.WithStorage(storage))
+
+	storage.Store("order:1", audit.Event{
+		Timestamp: base,
+		Action:    audit.ActionCreate,
+		Payload: map[string]audit.Value{
+			"status": audit.PlainValue("pending"),
+			"total":  audit.PlainValue(100),
+			"card":   {Hidden: true, Digest: "d1"},
+		},
+	})
+	storage.Store("order:1", audit.Event{
+		Timestamp: base.Add(time.Hour),
+		Action:    audit.ActionUpdate,
+		Payload: map[string]audit.Value{
+			"status": audit.PlainValue("paid"),
+			"card":   {Data: "leaked", Hidden: true, Digest: "d2"},
+		},
+	})
+	storage.Store("order:1", audit.Event{
+		Timestamp: base.Add(2 * time.Hour),
+		Action:    audit.ActionDelete,
+		Payload:   map[string]audit.Value{},
+	})
+	storage.Store("order:1", audit.Event{
+		Timestamp: base.Add(3 * time.Hour),
+		Action:    audit.ActionCreate,
+		Payload:   map[string]audit.Value{"status": audit.PlainValue("restored")},
+	})
+
+	tests := []struct {
+		name string
+		at   time.Time
+		want map[string]audit.Value
+	}{
+		{
+			name: "before creation",
+			at:   base.Add(-time.Second),
+			want: nil,
+		},
+		{
+			name: "at creation",
+			at:   base,
+			want: map[string]audit.Value{
+				"status": audit.PlainValue("pending"),
+				"total":  audit.PlainValue(100),
+				"card":   {Hidden: true, Digest: "d1"},
+			},
+		},
+		{
+			name: "after update",
+			at:   base.Add(90 * time.Minute),
+			want: map[string]audit.Value{
+				"status": audit.PlainValue("paid"),
+				"total":  audit.PlainValue(100),
+				"card":   {Hidden: true, Digest: "d2"},
+			},
+		},
+		{
+			name: "after delete",
+			at:   base.Add(2 * time.Hour),
+			want: nil,
+		},
+		{
+			name: "after re-create",
+			at:   base.Add(4 * time.Hour),
+			want: map[string]audit.Value{"status": audit.PlainValue("restored")},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			be.Equal(t, logger.StateAt("order:1", tt.at), tt.want)
+		})
+	}
+
+	be.Equal(t, logger.CurrentState("order:1"), map[string]audit.Value{
+		"status": audit.PlainValue("restored"),
+	})
+}
+
+func TestLogger_CurrentState(t *testing.T) {
+	t.Parallel()
+	logger := audit.New()
+
+	logger.Create("user:1", "admin", "Created", map[string]audit.Value{
+		"email":    audit.PlainValue("old@example.com"),
+		"password": audit.HiddenValueOf("secret"),
+	})
+	logger.Update("user:1", "admin", "Email changed", map[string]audit.Value{
+		"email": audit.PlainValue("new@example.com"),
+	})
+
+	state := logger.CurrentState("user:1")
+	be.Equal(t, state["email"].Data, any("new@example.com"))
+	be.True(t, state["password"].Hidden)
+	be.Equal(t, state["password"].Data, nil)
+	be.Equal(t, len(state["password"].Digest), 64)
+
+	be.Equal(t, logger.CurrentState("missing"), nil)
+}
+
+func TestLogger_StateAtContext_Error(t *testing.T) {
+	t.Parallel()
+	errBackend := errors.New("backend unavailable")
+	logger := audit.New(audit.WithStorageV2(failingStorage{err: errBackend}))
+
+	_, err := logger.StateAtContext(t.Context(), "key", time.Now())
+	be.Err(t, err, errBackend)
+	_, err = logger.CurrentStateContext(t.Context(), "key")
+	be.Err(t, err, errBackend)
+	be.Equal(t, logger.CurrentState("key"), nil)
+}
@@ -1,9 +1,17 @@
 package audit
 
-import "sync"
+import (
+	"context"
+	"slices"
+	"sync"
+)
 
 // Storage defines the interface for storing and retrieving audit events.
 // Implementations must be safe for concurrent access.
+//
+// Storage cannot report failures or observe cancellation. New backends should
+// implement StorageV2 instead; existing implementations keep working through
+// AdaptStorage, which WithStorage applies automatically.
 type Storage interface {
 	// Store appends an event to the storage for the given key
 	Store(key string, event Event)
@@ -19,25 +27,126 @@ type Storage interface {
 	Clear(key string)
 }
 
+// StorageV2 is the context-aware, error-returning storage contract.
+// Implementations must be safe for concurrent access and should return
+// ctx.Err() when the context is canceled before the operation completes.
+type StorageV2 interface {
+	// Store appends an event to the storage for the given key.
+	Store(ctx context.Context, key string, event Event) error
+
+	// Get retrieves all events for a given key in insertion order.
+	// Returns an empty slice and a nil error if the key doesn't exist.
+	Get(ctx context.Context, key string) ([]Event, error)
+
+	// Has checks if any events exist for a given key.
+	Has(ctx context.Context, key string) (bool, error)
+
+	// Clear removes all events for a given key.
+	Clear(ctx context.Context, key string) error
+}
+
+// AdaptStorage wraps a legacy Storage so it satisfies StorageV2.
+// The adapter checks the context before delegating and never returns
+// any other error, since Storage has no way to report one.
+func AdaptStorage(s Storage) StorageV2 {
+	return storageAdapter{s: s}
+}
+
+// storageAdapter implements StorageV2 on top of a legacy Storage.
+type storageAdapter struct {
+	s Storage
+}
+
+// Unwrap returns the adapted Storage.
+func (a storageAdapter) Unwrap() Storage {
+	return a.s
+}
+
+func (a storageAdapter) Store(ctx context.Context, key string, event Event) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	a.s.Store(key, event)
+	return nil
+}
+
+func (a storageAdapter) Get(ctx context.Context, key string) ([]Event, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return a.s.Get(key), nil
+}
+
+func (a storageAdapter) Has(ctx context.Context, key string) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	return a.s.Has(key), nil
+}
+
+func (a storageAdapter) Clear(ctx context.Context, key string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	a.s.Clear(key)
+	return nil
+}
+
+// capability returns s as the optional interface T, looking through the
+// legacy adapter so that Storage implementations can offer extensions too.
+func capability[T any](s StorageV2) (T, bool) {
+	if c, ok := s.(T); ok {
+		return c, true
+	}
+	if a, ok := s.(storageAdapter); ok {
+		c, ok := a.s.(T)
+		return c, ok
+	}
+	var zero T
+	return zero, false
+}
+
 // InMemoryStorage provides a thread-safe in-memory storage implementation
 // backed by a map. This is the default storage used by New().
 type InMemoryStorage struct {
 	mu     sync.RWMutex
 	events map[string][]Event
+	keys   []string // sorted keys of events, for KeyLister
+
+	pruneMu     sync.Mutex            // serializes Prune
+	checkpoints map[string]Checkpoint // last pruned event per key
+	generation  uint64                // incremented by Clear, so Prune can detect it
+
+	dedup *dedupWindow // recent idempotency keys, for Deduplicator
 }
 
 // NewInMemoryStorage creates a new in-memory storage instance.
-func NewInMemoryStorage() *InMemoryStorage {
-	return &InMemoryStorage{
+func NewInMemoryStorage(opts ...InMemoryOption) *InMemoryStorage {
+	s := &InMemoryStorage{
 		events: make(map[string][]Event),
+		dedup:  newDedupWindow(DefaultIdempotencyWindow),
+	}
+	for _, opt := range opts {
+		opt(s)
 	}
+	return s
 }
 
 // Store appends an event to the storage for the given key.
 func (s *InMemoryStorage) Store(key string, event Event) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
+	s.appendLocked(key, event)
+}
+
+// appendLocked appends event under key, indexing new keys. Callers must hold s.mu.
+func (s *InMemoryStorage) appendLocked(key string, event Event) {
+	if _, ok := s.events[key]; !ok {
+		i, _ := slices.BinarySearch(s.keys, key)
+		s.keys = slices.Insert(s.keys, i, key)
+	}
 	s.events[key] = append(s.events[key], event)
+	s.dedup.add(key, event)
 }
 
 // Get retrieves all events for a given key.
@@ -60,9 +169,45 @@ func (s *InMemoryStorage) Has(key string) bool {
 	return ok
 }
 
-// Clear removes all events for a given key.
+// Clear removes all events for a given key and its pruning checkpoint.
 func (s *InMemoryStorage) Clear(key string) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
+	delete(s.checkpoints, key)
+	s.dedup.forget(key)
+	if _, ok := s.events[key]; !ok {
+		return
+	}
 	delete(s.events, key)
+	s.generation++
+	if i, ok := slices.BinarySearch(s.keys, key); ok {
+		s.keys = slices.Delete(s.keys, i, i+1)
+	}
+}
+
+// Query returns the events selected by q, scanning keys in memory.
+// It implements Querier.
+func (s *InMemoryStorage) Query(ctx context.Context, q Query) ([]KeyedEvent, error) {
+	if err := q.Validate(); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var matched []KeyedEvent
+	for key, events := range s.events {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if !q.MatchKey(key) {
+			continue
+		}
+		for _, e := range events {
+			if e, ok := q.Match(key, e); ok {
+				matched = append(matched, KeyedEvent{Key: key, Event: e})
+			}
+		}
+	}
+	return q.apply(matched), nil
 }
@@ -1,6 +1,8 @@
 package audit_test
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"sync"
 	"testing"
@@ -311,3 +313,79 @@ func TestLogger_WithMockStorage(t *testing.T) {
 	be.Equal(t, len(events), 1)
 	be.Equal(t, mock.calls["Get"], 1)
 }
+
+func TestAdaptStorage(t *testing.T) {
+	t.Parallel()
+
+	inner := audit.NewInMemoryStorage()
+	storage := audit.AdaptStorage(inner)
+	ctx := t.Context()
+
+	be.Err(t, storage.Store(ctx, "key1", audit.Event{Author: "test"}), nil)
+	be.Equal(t, len(inner.Get("key1")), 1)
+
+	events, err := storage.Get(ctx, "key1")
+	be.Err(t, err, nil)
+	be.Equal(t, len(events), 1)
+
+	has, err := storage.Has(ctx, "key1")
+	be.Err(t, err, nil)
+	be.True(t, has)
+
+	be.Err(t, storage.Clear(ctx, "key1"), nil)
+	be.True(t, !inner.Has("key1"))
+}
+
+func TestAdaptStorage_CanceledContext(t *testing.T) {
+	t.Parallel()
+
+	inner := audit.NewInMemoryStorage()
+	storage := audit.AdaptStorage(inner)
+	ctx, cancel := context.WithCancel(t.Context())
+	cancel()
+
+	be.Err(t, storage.Store(ctx, "key1", audit.Event{}), context.Canceled)
+	be.True(t, !inner.Has("key1"))
+
+	_, err := storage.Get(ctx, "key1")
+	be.Err(t, err, context.Canceled)
+	_, err = storage.Has(ctx, "key1")
+	be.Err(t, err, context.Canceled)
+	be.Err(t, storage.Clear(ctx, "key1"), context.Canceled)
+}
+
+// failingStorage is a StorageV2 that fails every call with err.
+type failingStorage struct {
+	err error
+}
+
+func (f failingStorage) Store(context.Context, string, audit.Event) error { return f.err }
+
+func (f failingStorage) Get(context.Context, string) ([]audit.Event, error) { return nil, f.err }
+
+func (f failingStorage) Has(context.Context, string) (bool, error) { return false, f.err }
+
+func (f failingStorage) Clear(context.Context, string) error { return f.err }
+
+func TestLogger_WithStorageV2_PropagatesErrors(t *testing.T) {
+	t.Parallel()
+
+	errBackend := errors.New("backend unavailable")
+	logger := audit.New(audit.WithStorageV2(failingStorage{err: errBackend}))
+	ctx := t.Context()
+	payload := map[string]audit.Value{"field": audit.PlainValue("value")}
+
+	be.Err(t, logger.CreateContext(ctx, "test", "user", "Created", payload), errBackend)
+	be.Err(t, logger.UpdateContext(ctx, "test", "user", "Updated", payload), errBackend)
+	be.Err(t, logger.DeleteContext(ctx, "test", "user", "Deleted", payload), errBackend)
+
+	_, err := logger.EventsContext(ctx, "test")
+	be.Err(t, err, errBackend)
+	_, err = logger.LogsContext(ctx, "test")
+	be.Err(t, err, errBackend)
+
+	// Legacy methods swallow the error and return nothing.
+	logger.Create("test", "user", "Created", payload)
+	be.Equal(t, len(logger.Events("test")), 0)
+	be.Equal(t, len(logger.Logs("test")), 0)
+}
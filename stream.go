@@ -0,0 +1,139 @@
+package audit
+
+import (
+	"context"
+	"iter"
+)
+
+// StreamingStorage is an optional interface for storages that can yield the
+// events of a key one at a time instead of materializing them in a slice.
+// EventsSeq and LogsSeq use it when available.
+type StreamingStorage interface {
+	// Stream iterates over the events for key in insertion order. An error
+	// is yielded at most once, with a zero Event, and ends the iteration.
+	Stream(ctx context.Context, key string) iter.Seq2[Event, error]
+}
+
+// Stream iterates over the events stored for key when it is called.
+// Events logged during the iteration are not yielded. It implements StreamingStorage.
+func (s *InMemoryStorage) Stream(ctx context.Context, key string) iter.Seq2[Event, error] {
+	return func(yield func(Event, error) bool) {
+		// Events are only ever appended, so the prefix we capture stays valid.
+		events := s.Get(key)
+		for _, e := range events {
+			if err := ctx.Err(); err != nil {
+				yield(Event{}, err)
+				return
+			}
+			if !yield(e, nil) {
+				return
+			}
+		}
+	}
+}
+
+// Stream iterates over the events for key, reading each one from the file
+// as it is needed. It implements StreamingStorage.
+func (s *FileStorage) Stream(ctx context.Context, key string) iter.Seq2[Event, error] {
+	return func(yield func(Event, error) bool) {
+		file, refs, err := s.refs(key)
+		if err != nil {
+			yield(Event{}, err)
+			return
+		}
+		defer file.release()
+		for _, ref := range refs {
+			if err := ctx.Err(); err != nil {
+				yield(Event{}, err)
+				return
+			}
+			event, err := s.read(file, ref)
+			if err != nil {
+				yield(Event{}, err)
+				return
+			}
+			if !yield(event, nil) {
+				return
+			}
+		}
+	}
+}
+
+// stream returns an iterator over the events for key, using the storage's
+// StreamingStorage implementation if it has one.
+func (l *Logger) stream(ctx context.Context, key string) iter.Seq2[Event, error] {
+	if s, ok := capability[StreamingStorage](l.storage); ok {
+		return s.Stream(ctx, key)
+	}
+	return func(yield func(Event, error) bool) {
+		events, err := l.storage.Get(ctx, key)
+		if err != nil {
+			yield(Event{}, err)
+			return
+		}
+		for _, e := range events {
+			if !yield(e, nil) {
+				return
+			}
+		}
+	}
+}
+
+// EventsSeq is like EventsContext but yields the events one at a time, so
+// large histories can be processed incrementally and the caller can stop early.
+// Storages implementing StreamingStorage never hold the whole history in memory.
+//
+// A storage error is yielded with a zero Event and ends the iteration.
+//
+// Example:
+//
+//	for event, err := range logger.EventsSeq(ctx, "order:123") {
+//	    if err != nil {
+//	        return err
+//	    }
+//	    if event.Action == audit.ActionDelete {
+//	        break
+//	    }
+//	}
+func (l *Logger) EventsSeq(ctx context.Context, key string, fields ...string) iter.Seq2[Event, error] {
+	var fieldSet map[string]struct{}
+	if len(fields) > 0 {
+		fieldSet = newFieldSet(fields)
+	}
+	return func(yield func(Event, error) bool) {
+		for e, err := range l.stream(ctx, key) {
+			if err != nil {
+				yield(Event{}, err)
+				return
+			}
+			if fieldSet != nil {
+				var ok bool
+				if e, ok = filterFields(e, fieldSet); !ok {
+					continue
+				}
+			}
+			if !yield(e, nil) {
+				return
+			}
+		}
+	}
+}
+
+// LogsSeq is like LogsContext but yields the changes one at a time.
+// Only the reconstructed field state is kept between events.
+//
+// A storage error is yielded with a zero Change and ends the iteration.
+func (l *Logger) LogsSeq(ctx context.Context, key string) iter.Seq2[Change, error] {
+	return func(yield func(Change, error) bool) {
+		d := l.newDiffer()
+		for e, err := range l.stream(ctx, key) {
+			if err != nil {
+				yield(Change{}, err)
+				return
+			}
+			if !yield(d.change(e), nil) {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package audit_test
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/w0rng/audit"
+	"github.com/w0rng/audit/internal/be"
+)
+
+func TestLogger_EventsSeq(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name    string
+		storage func(t *testing.T) audit.StorageV2
+	}{
+		{"in memory", func(*testing.T) audit.StorageV2 { return audit.AdaptStorage(audit.NewInMemoryStorage()) }},
+		{"file", func(t *testing.T) audit.StorageV2 {
+			return openFileStorage(t, filepath.Join(t.TempDir(), "audit.jsonl"), audit.FileStorageOptions{})
+		}},
+		{"not streaming", func(*testing.T) audit.StorageV2 { return audit.AdaptStorage(newMockStorage()) }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			logger := audit.New(audit.WithStorageV2(tt.storage(t)))
+			ctx := t.Context()
+			logger.Create("order:1", "alice", "created", map[string]audit.Value{"status": audit.PlainValue("new")})
+			logger.Update("order:1", "bob", "noted", map[string]audit.Value{"note": audit.PlainValue("rush")})
+			logger.Update("order:1", "bob", "paid", map[string]audit.Value{"status": audit.PlainValue("paid")})
+
+			var authors []string
+			for e, err := range logger.EventsSeq(ctx, "order:1") {
+				be.Err(t, err, nil)
+				authors = append(authors, e.Author)
+			}
+			be.Equal(t, authors, []string{"alice", "bob", "bob"})
+
+			var statuses []any
+			for e, err := range logger.EventsSeq(ctx, "order:1", "status") {
+				be.Err(t, err, nil)
+				be.Equal(t, len(e.Payload), 1)
+				statuses = append(statuses, e.Payload["status"].Data)
+			}
+			be.Equal(t, statuses, []any{"new", "paid"})
+
+			var first []string
+			for e := range logger.EventsSeq(ctx, "order:1") {
+				first = append(first, e.Description)
+				break
+			}
+			be.Equal(t, first, []string{"created"})
+
+			var changes []audit.Change
+			for c, err := range logger.LogsSeq(ctx, "order:1") {
+				be.Err(t, err, nil)
+				changes = append(changes, c)
+			}
+			be.Equal(t, changes, logger.Logs("order:1"))
+
+			for range logger.EventsSeq(ctx, "missing") {
+				t.Fatal("unexpected event for a missing key")
+			}
+		})
+	}
+}
+
+func TestLogger_EventsSeq_Errors(t *testing.T) {
+	t.Parallel()
+	errBackend := errors.New("backend unavailable")
+	logger := audit.New(audit.WithStorageV2(failingStorage{err: errBackend}))
+
+	var errs []error
+	for e, err := range logger.EventsSeq(t.Context(), "order:1") {
+		be.Equal(t, e.Author, "")
+		errs = append(errs, err)
+	}
+	be.Equal(t, len(errs), 1)
+	be.Err(t, errs[0], errBackend)
+
+	errs = nil
+	for _, err := range logger.LogsSeq(t.Context(), "order:1") {
+		errs = append(errs, err)
+	}
+	be.Equal(t, len(errs), 1)
+	be.Err(t, errs[0], errBackend)
+}
+
+func TestLogger_EventsSeq_Canceled(t *testing.T) {
+	t.Parallel()
+	logger := audit.New()
+	for range 3 {
+		logger.Update("order:1", "alice", "updated", map[string]audit.Value{})
+	}
+	ctx, cancel := context.WithCancel(t.Context())
+	defer cancel()
+
+	var seen int
+	var last error
+	for _, err := range logger.EventsSeq(ctx, "order:1") {
+		if err != nil {
+			last = err
+			continue
+		}
+		seen++
+		cancel()
+	}
+	be.Equal(t, seen, 1)
+	be.Err(t, last, context.Canceled)
+}
+
+func TestStreamingStorageInterface(t *testing.T) {
+	t.Parallel()
+	var _ audit.StreamingStorage = (*audit.InMemoryStorage)(nil)
+	var _ audit.StreamingStorage = (*audit.FileStorage)(nil)
+}
@@ -0,0 +1,164 @@
+package audit
+
+import (
+	"context"
+	"reflect"
+	"strings"
+)
+
+// Diff compares two values of the same struct type and returns a payload
+// containing only the fields that changed, with their new values.
+//
+// Fields are named and masked with the audit struct tag:
+//
+//	Email    string `audit:"email"`           // logged as "email"
+//	Password string `audit:"password,hidden"` // logged as a hidden value
+//	Internal string `audit:"-"`               // never logged
+//
+// Untagged exported fields use their Go name, and unexported fields are
+// skipped. Nested structs are compared field by field and reported with
+// dotted paths such as "address.city"; embedded structs without a tag are
+// flattened into their parent. time.Time is compared as a single value.
+//
+// before or after may be nil (or a nil pointer), for example when an entity
+// is created or deleted; every field of the other value is then reported.
+// Values are compared with Equal. Diff returns an empty payload if neither
+// argument is a struct.
+func Diff(before, after any) map[string]Value {
+	payload := make(map[string]Value)
+	diffStruct(payload, "", indirect(reflect.ValueOf(before)), indirect(reflect.ValueOf(after)), false, 0)
+	return payload
+}
+
+// UpdateStruct records an update event with the fields that changed between
+// before and after, as computed by Diff. Nothing is recorded when no field changed.
+//
+// Storage errors are discarded; use UpdateStructContext to observe them.
+func (l *Logger) UpdateStruct(key, author, description string, before, after any) {
+	_ = l.UpdateStructContext(context.Background(), key, author, description, before, after)
+}
+
+// UpdateStructContext is like UpdateStruct but honors ctx and returns the storage error, if any.
+func (l *Logger) UpdateStructContext(ctx context.Context, key, author, description string, before, after any) error {
+	payload := Diff(before, after)
+	if len(payload) == 0 {
+		return nil
+	}
+	return l.UpdateContext(ctx, key, author, description, payload)
+}
+
+// diffStruct adds the changed fields of before and after to payload, naming
+// them relative to prefix. Either value may be invalid, meaning absent.
+func diffStruct(payload map[string]Value, prefix string, before, after reflect.Value, hidden bool, depth int) {
+	typ := structType(before, after)
+	if typ == nil || depth > maxCompareDepth {
+		return
+	}
+
+	for i := range typ.NumField() {
+		f := typ.Field(i)
+		name, opts, ok := auditTag(f)
+		if !ok {
+			continue
+		}
+		old, current := fieldOf(before, i), fieldOf(after, i)
+		isHidden := hidden || opts.hidden
+
+		if f.Anonymous && !opts.named && isStruct(f.Type) {
+			diffStruct(payload, prefix, indirect(old), indirect(current), isHidden, depth+1)
+			continue
+		}
+
+		path := prefix + name
+		if isStruct(f.Type) {
+			diffStruct(payload, path+".", indirect(old), indirect(current), isHidden, depth+1)
+			continue
+		}
+
+		oldData, newData := interfaceOf(old), interfaceOf(current)
+		if old.IsValid() && current.IsValid() && Equal(oldData, newData) {
+			continue
+		}
+		if isHidden {
+			payload[path] = HiddenValueOf(newData)
+		} else {
+			payload[path] = PlainValue(newData)
+		}
+	}
+}
+
+// tagOptions holds the parsed options of an audit struct tag.
+type tagOptions struct {
+	named  bool
+	hidden bool
+}
+
+// auditTag returns the payload name and options of a struct field.
+// It reports false for unexported fields and fields tagged "-".
+func auditTag(f reflect.StructField) (string, tagOptions, bool) {
+	if !f.IsExported() && !f.Anonymous {
+		return "", tagOptions{}, false
+	}
+	tag := f.Tag.Get("audit")
+	if tag == "-" {
+		return "", tagOptions{}, false
+	}
+
+	name, rest, _ := strings.Cut(tag, ",")
+	opts := tagOptions{named: name != ""}
+	for opt := range strings.SplitSeq(rest, ",") {
+		if opt == "hidden" {
+			opts.hidden = true
+		}
+	}
+	if !f.IsExported() && (opts.named || !isStruct(f.Type)) {
+		return "", tagOptions{}, false
+	}
+	if name == "" {
+		name = f.Name
+	}
+	return name, opts, true
+}
+
+// structType returns the struct type shared by the valid values among a and b.
+// It returns nil if neither is a struct or if their types differ.
+func structType(a, b reflect.Value) reflect.Type {
+	switch {
+	case a.IsValid() && b.IsValid():
+		if a.Type() != b.Type() || !isStruct(a.Type()) {
+			return nil
+		}
+		return a.Type()
+	case a.IsValid() && isStruct(a.Type()):
+		return a.Type()
+	case b.IsValid() && isStruct(b.Type()):
+		return b.Type()
+	default:
+		return nil
+	}
+}
+
+// isStruct reports whether t, or the type it points to, is a struct that
+// Diff descends into. time.Time is treated as a single value.
+func isStruct(t reflect.Type) bool {
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return t.Kind() == reflect.Struct && t != timeType
+}
+
+// fieldOf returns field i of v, or the invalid Value if v is absent.
+func fieldOf(v reflect.Value, i int) reflect.Value {
+	if !v.IsValid() {
+		return reflect.Value{}
+	}
+	return v.Field(i)
+}
+
+// interfaceOf returns the data held by v, or nil if v is absent.
+func interfaceOf(v reflect.Value) any {
+	if !v.IsValid() || !v.CanInterface() {
+		return nil
+	}
+	return v.Interface()
+}
@@ -0,0 +1,141 @@
+package audit_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/w0rng/audit"
+	"github.com/w0rng/audit/internal/be"
+)
+
+type auditAddress struct {
+	City   string `audit:"city"`
+	Street string `audit:"street"`
+}
+
+type auditBase struct {
+	ID int `audit:"id"`
+}
+
+type auditUser struct {
+	auditBase
+
+	Email    string        `audit:"email"`
+	Password string        `audit:"password,hidden"`
+	Session  string        `audit:"-"`
+	Address  auditAddress  `audit:"address"`
+	Billing  *auditAddress `audit:"billing"`
+	Tags     []string      `audit:"tags"`
+	Joined   time.Time     `audit:"joined"`
+	Nickname string
+
+	internal string
+}
+
+func TestDiff(t *testing.T) {
+	t.Parallel()
+	joined := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	base := auditUser{
+		auditBase: auditBase{ID: 1},
+		Email:     "a@example.com",
+		Password:  "secret",
+		Address:   auditAddress{City: "Paris", Street: "Rue A"},
+		Tags:      []string{"a"},
+		Joined:    joined,
+	}
+
+	tests := []struct {
+		name   string
+		change func(u *auditUser)
+		want   map[string]audit.Value
+	}{
+		{
+			name:   "no change",
+			change: func(u *auditUser) { u.Tags = []string{"a"}; u.Joined = joined.In(time.Local) },
+			want:   map[string]audit.Value{},
+		},
+		{
+			name:   "plain field",
+			change: func(u *auditUser) { u.Email = "b@example.com" },
+			want:   map[string]audit.Value{"email": audit.PlainValue("b@example.com")},
+		},
+		{
+			name:   "hidden field",
+			change: func(u *auditUser) { u.Password = "new" },
+			want:   map[string]audit.Value{"password": audit.HiddenValueOf("new")},
+		},
+		{
+			name:   "ignored and unexported fields",
+			change: func(u *auditUser) { u.Session = "s"; u.internal = "x" },
+			want:   map[string]audit.Value{},
+		},
+		{
+			name:   "untagged field uses Go name",
+			change: func(u *auditUser) { u.Nickname = "al" },
+			want:   map[string]audit.Value{"Nickname": audit.PlainValue("al")},
+		},
+		{
+			name:   "nested struct",
+			change: func(u *auditUser) { u.Address.City = "Lyon" },
+			want:   map[string]audit.Value{"address.city": audit.PlainValue("Lyon")},
+		},
+		{
+			name:   "nil pointer to struct",
+			change: func(u *auditUser) { u.Billing = &auditAddress{City: "Nice"} },
+			want: map[string]audit.Value{
+				"billing.city":   audit.PlainValue("Nice"),
+				"billing.street": audit.PlainValue(""),
+			},
+		},
+		{
+			name:   "embedded struct is flattened",
+			change: func(u *auditUser) { u.ID = 2 },
+			want:   map[string]audit.Value{"id": audit.PlainValue(2)},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			after := base
+			tt.change(&after)
+			be.Equal(t, audit.Diff(base, &after), tt.want)
+		})
+	}
+}
+
+func TestDiff_NilSide(t *testing.T) {
+	t.Parallel()
+	user := auditAddress{City: "Paris", Street: "Rue A"}
+
+	be.Equal(t, audit.Diff(nil, user), map[string]audit.Value{
+		"city":   audit.PlainValue("Paris"),
+		"street": audit.PlainValue("Rue A"),
+	})
+	be.Equal(t, audit.Diff(&user, (*auditAddress)(nil)), map[string]audit.Value{
+		"city":   audit.PlainValue(nil),
+		"street": audit.PlainValue(nil),
+	})
+	be.Equal(t, audit.Diff("a", "b"), map[string]audit.Value{})
+	be.Equal(t, audit.Diff(user, auditBase{}), map[string]audit.Value{})
+}
+
+func TestLogger_UpdateStruct(t *testing.T) {
+	t.Parallel()
+	logger := audit.New()
+	before := auditUser{Email: "a@example.com", Password: "old"}
+	after := before
+	after.Email = "b@example.com"
+	after.Password = "new"
+
+	logger.UpdateStruct("user:1", "alice", "Profile edited", before, after)
+	be.Err(t, logger.UpdateStructContext(t.Context(), "user:1", "alice", "No-op", after, after), nil)
+
+	events := logger.Events("user:1")
+	be.Equal(t, len(events), 1)
+	be.Equal(t, events[0].Action, audit.ActionUpdate)
+	be.Equal(t, events[0].Payload["email"], audit.PlainValue("b@example.com"))
+	be.True(t, events[0].Payload["password"].Hidden)
+	be.Equal(t, events[0].Payload["password"].Data, nil)
+	be.Equal(t, len(events[0].Payload), 2)
+}
@@ -0,0 +1,192 @@
+package audit
+
+import (
+	"slices"
+	"strings"
+	"sync"
+)
+
+// SlowPolicy selects what happens to an event when a subscriber's buffer is full.
+type SlowPolicy int
+
+const (
+	// SlowDropNewest discards the new event. This is the default.
+	SlowDropNewest SlowPolicy = iota
+
+	// SlowDropOldest discards the oldest buffered event to make room for the new one.
+	SlowDropOldest
+
+	// SlowBlock waits until the subscriber has room or is canceled. Writes to
+	// the logger are delayed meanwhile, so the subscriber must keep up.
+	SlowBlock
+
+	// SlowUnsubscribe cancels the subscription, closing its channel.
+	SlowUnsubscribe
+)
+
+// defaultSubscriberBuffer is used when Filter.Buffer is not set.
+const defaultSubscriberBuffer = 64
+
+// Filter selects the events delivered to a subscriber and how they are buffered.
+// Zero values mean "no restriction" for every filter.
+type Filter struct {
+	// Key restricts events to a single entity. It takes precedence over KeyPrefix.
+	Key string
+
+	// KeyPrefix restricts events to entities whose key starts with the prefix.
+	KeyPrefix string
+
+	// Actions and Authors keep events matching any of the listed values.
+	Actions []Action
+	Authors []string
+
+	// Buffer is the capacity of the subscriber's channel. Defaults to 64.
+	Buffer int
+
+	// Slow selects what happens when the buffer is full. Defaults to SlowDropNewest.
+	Slow SlowPolicy
+}
+
+// match reports whether the event stored under key is selected by f.
+func (f Filter) match(key string, e Event) bool {
+	switch {
+	case f.Key != "" && key != f.Key:
+		return false
+	case f.Key == "" && !strings.HasPrefix(key, f.KeyPrefix):
+		return false
+	case len(f.Actions) > 0 && !slices.Contains(f.Actions, e.Action):
+		return false
+	case len(f.Authors) > 0 && !slices.Contains(f.Authors, e.Author):
+		return false
+	}
+	return true
+}
+
+// subscriber is a registered Subscribe call.
+type subscriber struct {
+	filter Filter
+	done   chan struct{}
+	once   sync.Once
+
+	mu     sync.Mutex // guards sends on ch and its closing
+	ch     chan KeyedEvent
+	closed bool
+}
+
+// subscribers is the registry of a Logger's subscribers.
+type subscribers struct {
+	mu   sync.RWMutex
+	subs map[*subscriber]struct{}
+}
+
+// Subscribe registers a subscriber for the events selected by filter and
+// returns its channel and a function that cancels the subscription.
+//
+// Events are delivered after the storage accepted them, in the order they
+// were written for each key. Each subscriber has its own buffer; filter.Slow
+// decides what happens when it is full. Cancel closes the channel and may be
+// called more than once.
+//
+// Example:
+//
+//	events, cancel := logger.Subscribe(audit.Filter{KeyPrefix: "order:"})
+//	defer cancel()
+//	for e := range events {
+//	    cache.Invalidate(e.Key)
+//	}
+func (l *Logger) Subscribe(filter Filter) (<-chan KeyedEvent, func()) {
+	if filter.Buffer <= 0 {
+		filter.Buffer = defaultSubscriberBuffer
+	}
+	sub := &subscriber{
+		filter: filter,
+		done:   make(chan struct{}),
+		ch:     make(chan KeyedEvent, filter.Buffer),
+	}
+
+	l.subs.mu.Lock()
+	if l.subs.subs == nil {
+		l.subs.subs = make(map[*subscriber]struct{})
+	}
+	l.subs.subs[sub] = struct{}{}
+	l.subs.mu.Unlock()
+
+	return sub.ch, func() { l.subs.cancel(sub) }
+}
+
+// cancel removes sub and closes its channel.
+func (r *subscribers) cancel(sub *subscriber) {
+	sub.once.Do(func() {
+		r.mu.Lock()
+		delete(r.subs, sub)
+		r.mu.Unlock()
+
+		// Wake up a blocked send before taking the send lock.
+		close(sub.done)
+		sub.mu.Lock()
+		defer sub.mu.Unlock()
+		sub.closed = true
+		close(sub.ch)
+	})
+}
+
+// publish delivers the event stored under key to the matching subscribers.
+// Callers must hold the chain lock for key, which keeps per-key order.
+func (r *subscribers) publish(key string, event Event) {
+	r.mu.RLock()
+	var matched []*subscriber
+	for sub := range r.subs {
+		if sub.filter.match(key, event) {
+			matched = append(matched, sub)
+		}
+	}
+	r.mu.RUnlock()
+
+	e := KeyedEvent{Key: key, Event: event}
+	for _, sub := range matched {
+		if !sub.send(e) {
+			r.cancel(sub)
+		}
+	}
+}
+
+// send delivers e according to the subscriber's policy. It reports false
+// if the subscriber must be canceled.
+func (sub *subscriber) send(e KeyedEvent) bool {
+	sub.mu.Lock()
+	defer sub.mu.Unlock()
+	if sub.closed {
+		return true
+	}
+
+	select {
+	case sub.ch <- e:
+		return true
+	default:
+	}
+
+	switch sub.filter.Slow {
+	case SlowDropOldest:
+		for {
+			select {
+			case sub.ch <- e:
+				return true
+			default:
+			}
+			select {
+			case <-sub.ch:
+			default:
+			}
+		}
+	case SlowBlock:
+		select {
+		case sub.ch <- e:
+		case <-sub.done:
+		}
+		return true
+	case SlowUnsubscribe:
+		return false
+	default:
+		return true
+	}
+}
@@ -0,0 +1,151 @@
+package audit_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/w0rng/audit"
+	"github.com/w0rng/audit/internal/be"
+)
+
+// received drains the events currently buffered in ch and reports whether ch is closed.
+func received(ch <-chan audit.KeyedEvent) ([]string, bool) {
+	var descs []string
+	for {
+		select {
+		case e, ok := <-ch:
+			if !ok {
+				return descs, true
+			}
+			descs = append(descs, e.Key+" "+e.Description)
+		default:
+			return descs, false
+		}
+	}
+}
+
+func TestLogger_Subscribe_Filter(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name   string
+		filter audit.Filter
+		want   []string
+	}{
+		{"all", audit.Filter{}, []string{"order:1 created", "user:1 created", "order:2 deleted", "order:1 paid"}},
+		{"key", audit.Filter{Key: "order:1", KeyPrefix: "user:"}, []string{"order:1 created", "order:1 paid"}},
+		{"key prefix", audit.Filter{KeyPrefix: "order:"}, []string{"order:1 created", "order:2 deleted", "order:1 paid"}},
+		{"actions", audit.Filter{Actions: []audit.Action{audit.ActionDelete}}, []string{"order:2 deleted"}},
+		{"authors", audit.Filter{Authors: []string{"bob"}}, []string{"user:1 created", "order:1 paid"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			logger := audit.New()
+			events, cancel := logger.Subscribe(tt.filter)
+			defer cancel()
+
+			logger.Create("order:1", "alice", "created", map[string]audit.Value{})
+			logger.Create("user:1", "bob", "created", map[string]audit.Value{})
+			logger.Delete("order:2", "alice", "deleted", map[string]audit.Value{})
+			logger.Update("order:1", "bob", "paid", map[string]audit.Value{})
+
+			got, closed := received(events)
+			be.Equal(t, got, tt.want)
+			be.True(t, !closed)
+		})
+	}
+}
+
+func TestLogger_Subscribe_SlowPolicy(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name   string
+		slow   audit.SlowPolicy
+		want   []string
+		closed bool
+	}{
+		{"drop newest", audit.SlowDropNewest, []string{"k e1", "k e2"}, false},
+		{"drop oldest", audit.SlowDropOldest, []string{"k e3", "k e4"}, false},
+		{"unsubscribe", audit.SlowUnsubscribe, []string{"k e1", "k e2"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			logger := audit.New()
+			events, cancel := logger.Subscribe(audit.Filter{Buffer: 2, Slow: tt.slow})
+			defer cancel()
+
+			for _, desc := range []string{"e1", "e2", "e3", "e4"} {
+				logger.Update("k", "alice", desc, map[string]audit.Value{})
+			}
+			got, closed := received(events)
+			be.Equal(t, got, tt.want)
+			be.Equal(t, closed, tt.closed)
+		})
+	}
+}
+
+func TestLogger_Subscribe_Block(t *testing.T) {
+	t.Parallel()
+	logger := audit.New()
+	events, cancel := logger.Subscribe(audit.Filter{Buffer: 1, Slow: audit.SlowBlock})
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for _, desc := range []string{"e1", "e2", "e3"} {
+			logger.Update("k", "alice", desc, map[string]audit.Value{})
+		}
+	}()
+	var got []string
+	for range 3 {
+		e := <-events
+		got = append(got, e.Description)
+	}
+	<-done
+	be.Equal(t, got, []string{"e1", "e2", "e3"})
+
+	// Cancel releases a writer blocked on a full buffer.
+	logger.Update("k", "alice", "e4", map[string]audit.Value{})
+	done = make(chan struct{})
+	go func() {
+		defer close(done)
+		logger.Update("k", "alice", "e5", map[string]audit.Value{})
+	}()
+	cancel()
+	<-done
+	cancel()
+}
+
+func TestLogger_Subscribe_Delivery(t *testing.T) {
+	t.Parallel()
+	logger := audit.New()
+	events, cancel := logger.Subscribe(audit.Filter{})
+
+	be.Err(t, logger.LogBatch(t.Context(), batchEntries()), nil)
+	tx := logger.Begin(t.Context())
+	tx.Update("order:3", "alice", "Paid", map[string]audit.Value{})
+	be.Err(t, tx.Commit(), nil)
+
+	got, _ := received(events)
+	be.Equal(t, got, []string{"order:1 Imported", "order:2 Imported", "order:1 Imported", "order:3 Paid"})
+
+	cancel()
+	logger.Update("order:1", "alice", "after cancel", map[string]audit.Value{})
+	got, closed := received(events)
+	be.Equal(t, len(got), 0)
+	be.True(t, closed)
+}
+
+func TestLogger_Subscribe_StorageError(t *testing.T) {
+	t.Parallel()
+	logger := audit.New(audit.WithStorageV2(failingStorage{err: errors.New("backend unavailable")}))
+	events, cancel := logger.Subscribe(audit.Filter{})
+	defer cancel()
+
+	logger.Create("order:1", "alice", "created", map[string]audit.Value{})
+	got, _ := received(events)
+	be.Equal(t, len(got), 0)
+}
@@ -0,0 +1,177 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"strings"
+)
+
+// TypedChange is a Change of a tracked entity together with the entity's
+// reconstructed state after the event.
+type TypedChange[T any] struct {
+	Change
+
+	Action Action `json:"action"`
+	// Snapshot is the entity after the event. It is the zero value of T after a
+	// delete. Hidden fields are never restored and keep their previous value.
+	Snapshot T `json:"snapshot"`
+}
+
+// Tracker records changes of entities of type T. Payloads are computed with
+// Diff, so T is described with audit struct tags.
+type Tracker[T any] struct {
+	logger *Logger
+	key    func(T) string
+}
+
+// NewTracker returns a Tracker that logs to logger and stores each entity
+// under the key returned by key.
+//
+// Example:
+//
+//	users := audit.NewTracker(logger, func(u User) string { return "user:" + u.ID })
+//	err := users.Update(ctx, "admin", "Email changed", before, after)
+func NewTracker[T any](logger *Logger, key func(T) string) *Tracker[T] {
+	return &Tracker[T]{logger: logger, key: key}
+}
+
+// Create records the creation of v with all of its fields.
+func (t *Tracker[T]) Create(ctx context.Context, author, description string, v T) error {
+	return t.logger.CreateContext(ctx, t.key(v), author, description, Diff(nil, v))
+}
+
+// Update records the fields that changed from old to updated under the key of updated.
+// Nothing is recorded when no field changed.
+func (t *Tracker[T]) Update(ctx context.Context, author, description string, old, updated T) error {
+	return t.logger.UpdateStructContext(ctx, t.key(updated), author, description, old, updated)
+}
+
+// Delete records the deletion of v.
+func (t *Tracker[T]) Delete(ctx context.Context, author, description string, v T) error {
+	return t.logger.DeleteContext(ctx, t.key(v), author, description, map[string]Value{})
+}
+
+// History returns the typed change history of the entity stored under key.
+//
+// Storage errors are discarded and yield a nil result; use HistoryContext to observe them.
+func (t *Tracker[T]) History(key string) []TypedChange[T] {
+	changes, _ := t.HistoryContext(context.Background(), key)
+	return changes
+}
+
+// HistoryContext is like History but honors ctx and returns the storage error, if any.
+func (t *Tracker[T]) HistoryContext(ctx context.Context, key string) ([]TypedChange[T], error) {
+	events, err := t.logger.storage.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := t.logger.changes(events)
+	result := make([]TypedChange[T], 0, len(events))
+	var snapshot T
+	for i, e := range events {
+		if e.Action == ActionDelete {
+			var zero T
+			snapshot = zero
+		}
+		for field, val := range e.Payload {
+			if !val.Hidden {
+				setPath(reflect.ValueOf(&snapshot).Elem(), field, val.Data, 0)
+			}
+		}
+		result = append(result, TypedChange[T]{Change: changes[i], Action: e.Action, Snapshot: snapshot})
+	}
+	return result, nil
+}
+
+// setPath stores data in the field of v named by path, using the same naming
+// rules as Diff. Pointers on the way are copied before they are written
+// through, so earlier snapshots are left untouched. Unknown paths are ignored.
+func setPath(v reflect.Value, path string, data any, depth int) bool {
+	if depth > maxCompareDepth {
+		return false
+	}
+	if v.Kind() == reflect.Pointer {
+		if !isStruct(v.Type()) || !v.CanSet() {
+			return false
+		}
+		clone := reflect.New(v.Type().Elem())
+		if !v.IsNil() {
+			clone.Elem().Set(v.Elem())
+		}
+		if !setPath(clone.Elem(), path, data, depth+1) {
+			return false
+		}
+		v.Set(clone)
+		return true
+	}
+	if v.Kind() != reflect.Struct {
+		return false
+	}
+
+	for i := range v.NumField() {
+		f := v.Type().Field(i)
+		name, opts, ok := auditTag(f)
+		if !ok {
+			continue
+		}
+		field := v.Field(i)
+		switch {
+		case f.Anonymous && !opts.named && isStruct(f.Type):
+			if setPath(field, path, data, depth+1) {
+				return true
+			}
+		case path == name && field.CanSet():
+			return assign(field, data)
+		case isStruct(f.Type) && strings.HasPrefix(path, name+"."):
+			return setPath(field, strings.TrimPrefix(path, name+"."), data, depth+1)
+		}
+	}
+	return false
+}
+
+// assign stores data in v. Data that went through a storage round trip may
+// have a different type, such as json.Number for an int field; it is converted
+// through its JSON encoding. So is data holding slices, maps or pointers, which
+// would otherwise be shared between the snapshot and the stored payload.
+func assign(v reflect.Value, data any) bool {
+	if data == nil {
+		v.SetZero()
+		return true
+	}
+	if d := reflect.ValueOf(data); d.Type().AssignableTo(v.Type()) && isFlat(d.Type()) {
+		v.Set(d)
+		return true
+	}
+
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return false
+	}
+	target := reflect.New(v.Type())
+	if err := json.Unmarshal(encoded, target.Interface()); err != nil {
+		return false
+	}
+	v.Set(target.Elem())
+	return true
+}
+
+// isFlat reports whether a copy of a value of type t shares no data reachable
+// through exported fields with the original.
+func isFlat(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Slice, reflect.Map, reflect.Pointer, reflect.Interface,
+		reflect.Chan, reflect.Func, reflect.UnsafePointer:
+		return false
+	case reflect.Array:
+		return isFlat(t.Elem())
+	case reflect.Struct:
+		for i := range t.NumField() {
+			if f := t.Field(i); f.IsExported() && !isFlat(f.Type) {
+				return false
+			}
+		}
+	}
+	return true
+}
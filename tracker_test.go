@@ -0,0 +1,108 @@
+package audit_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/w0rng/audit"
+	"github.com/w0rng/audit/internal/be"
+)
+
+type trackedOrder struct {
+	ID       string        `audit:"-"`
+	Status   string        `audit:"status"`
+	Total    int           `audit:"total"`
+	Items    []string      `audit:"items"`
+	Shipping *auditAddress `audit:"shipping"`
+	Coupon   string        `audit:"coupon,hidden"`
+}
+
+func orderKey(o trackedOrder) string { return "order:" + o.ID }
+
+func TestTracker_History(t *testing.T) {
+	t.Parallel()
+	file, err := audit.OpenFileStorage(filepath.Join(t.TempDir(), "audit.jsonl"), audit.FileStorageOptions{})
+	be.Err(t, err, nil)
+	t.Cleanup(func() { _ = file.Close() })
+
+	tests := []struct {
+		name   string
+		logger *audit.Logger
+	}{
+		{"in memory", audit.New()},
+		{"file round trip", audit.New(audit.WithStorageV2(file))},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			ctx := t.Context()
+			orders := audit.NewTracker(tt.logger, orderKey)
+
+			created := trackedOrder{ID: "1", Status: "new", Total: 10, Items: []string{"a"}, Coupon: "SAVE"}
+			paid := created
+			paid.Status = "paid"
+			paid.Shipping = &auditAddress{City: "Paris"}
+			moved := paid
+			moved.Shipping = &auditAddress{City: "Lyon"}
+
+			be.Err(t, orders.Create(ctx, "alice", "Created", created), nil)
+			be.Err(t, orders.Update(ctx, "bob", "Paid", created, paid), nil)
+			be.Err(t, orders.Update(ctx, "bob", "Unchanged", paid, paid), nil)
+			be.Err(t, orders.Update(ctx, "bob", "Moved", paid, moved), nil)
+			be.Err(t, orders.Delete(ctx, "alice", "Deleted", moved), nil)
+
+			history, err := orders.HistoryContext(ctx, "order:1")
+			be.Err(t, err, nil)
+			be.Equal(t, len(history), 4)
+
+			first := history[0]
+			be.Equal(t, first.Action, audit.ActionCreate)
+			be.Equal(t, first.Author, "alice")
+			be.Equal(t, first.Snapshot.Status, "new")
+			be.Equal(t, first.Snapshot.Total, 10)
+			be.Equal(t, first.Snapshot.Items, []string{"a"})
+			be.Equal(t, first.Snapshot.Coupon, "")
+			be.Equal(t, first.Snapshot.ID, "")
+
+			be.Equal(t, history[1].Snapshot.Status, "paid")
+			be.Equal(t, history[1].Snapshot.Shipping.City, "Paris")
+			be.Equal(t, history[2].Snapshot.Shipping.City, "Lyon")
+			be.Equal(t, history[2].Fields, []audit.ChangeField{
+				{Field: "shipping.city", From: "Paris", To: "Lyon"},
+			})
+
+			be.Equal(t, history[3].Action, audit.ActionDelete)
+			be.Equal(t, history[3].Snapshot.Status, "")
+			be.Equal(t, history[3].Snapshot.Shipping, nil)
+		})
+	}
+}
+
+func TestTracker_PointerType(t *testing.T) {
+	t.Parallel()
+	orders := audit.NewTracker(audit.New(), func(o *trackedOrder) string { return "order:" + o.ID })
+
+	be.Err(t, orders.Create(t.Context(), "alice", "Created", &trackedOrder{ID: "1", Status: "new"}), nil)
+	be.Err(t, orders.Update(t.Context(), "alice", "Paid",
+		&trackedOrder{ID: "1", Status: "new"}, &trackedOrder{ID: "1", Status: "paid"}), nil)
+
+	history := orders.History("order:1")
+	be.Equal(t, len(history), 2)
+	be.Equal(t, history[0].Snapshot.Status, "new")
+	be.Equal(t, history[1].Snapshot.Status, "paid")
+}
+
+func TestTracker_SnapshotsDoNotShareStoredData(t *testing.T) {
+	t.Parallel()
+	logger := audit.New()
+	orders := audit.NewTracker(logger, orderKey)
+	created := trackedOrder{ID: "1", Status: "new", Items: []string{"a", "b"}}
+	be.Err(t, orders.Create(t.Context(), "alice", "Created", created), nil)
+
+	history := orders.History("order:1")
+	history[0].Snapshot.Items[0] = "forged"
+
+	be.Equal(t, orders.History("order:1")[0].Snapshot.Items, []string{"a", "b"})
+	be.Err(t, logger.Verify("order:1"), nil)
+}
@@ -0,0 +1,129 @@
+package audit
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// ErrTxDone is returned by Tx.Commit and Tx.Rollback after the transaction
+// has already been committed or rolled back.
+var ErrTxDone = errors.New("audit: transaction already committed or rolled back")
+
+// TxStorage is an optional interface for storages that can store several
+// events atomically. Logger.Begin uses it to commit audit transactions.
+type TxStorage interface {
+	BeginTx(ctx context.Context) (StorageTx, error)
+}
+
+// StorageTx is a storage transaction returned by TxStorage.BeginTx.
+// Events passed to Store become visible only after Commit.
+type StorageTx interface {
+	Store(ctx context.Context, key string, event Event) error
+	Commit() error
+	Rollback() error
+}
+
+// Tx groups audit events for several entities so that they are recorded
+// together on Commit, or not at all on Rollback. All events of a Tx share its ID
+// in Event.TxID. A Tx is safe for concurrent use.
+//
+// Atomicity depends on the storage: storages implementing TxStorage (such as
+// sqlstore.Store) and InMemoryStorage store all events or none. Other
+// BatchStorer implementations are used as is, and the remaining storages get
+// one Store call per event, so a failing Commit may leave some events stored.
+type Tx struct {
+	logger *Logger
+	ctx    context.Context
+	id     string
+
+	mu      sync.Mutex
+	entries []LogEntry
+	done    bool
+}
+
+// Begin starts an audit transaction. ctx is used by Commit and supplies the
+// actor and request ID recorded on the events.
+//
+// Example:
+//
+//	tx := logger.Begin(ctx)
+//	tx.Update("order:123", "alice", "Paid", orderPayload)
+//	tx.Create("payment:9", "alice", "Captured", paymentPayload)
+//	if err := tx.Commit(); err != nil {
+//	    return err
+//	}
+func (l *Logger) Begin(ctx context.Context) *Tx {
+	return &Tx{logger: l, ctx: ctx, id: l.newID(l.clock())}
+}
+
+// ID returns the transaction ID recorded on every event of the transaction.
+func (tx *Tx) ID() string {
+	return tx.id
+}
+
+// LogChange adds an event to the transaction. It is recorded on Commit.
+// Events added after Commit or Rollback are ignored.
+func (tx *Tx) LogChange(key string, action Action, author, description string, payload map[string]Value) {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+	if tx.done {
+		return
+	}
+	tx.entries = append(tx.entries, LogEntry{
+		Key:         key,
+		Action:      action,
+		Author:      author,
+		Description: description,
+		Payload:     payload,
+	})
+}
+
+func (tx *Tx) Create(key, author, description string, payload map[string]Value) {
+	tx.LogChange(key, ActionCreate, author, description, payload)
+}
+
+func (tx *Tx) Update(key, author, description string, payload map[string]Value) {
+	tx.LogChange(key, ActionUpdate, author, description, payload)
+}
+
+func (tx *Tx) Delete(key, author, description string, payload map[string]Value) {
+	tx.LogChange(key, ActionDelete, author, description, payload)
+}
+
+// Commit records all events of the transaction, in the order they were added.
+func (tx *Tx) Commit() error {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+	if tx.done {
+		return ErrTxDone
+	}
+	tx.done = true
+	return tx.logger.logEntries(tx.ctx, tx.entries, tx.id, true)
+}
+
+// Rollback discards the events of the transaction.
+func (tx *Tx) Rollback() error {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+	if tx.done {
+		return ErrTxDone
+	}
+	tx.done = true
+	tx.entries = nil
+	return nil
+}
+
+// storeTx stores events in a single storage transaction.
+func storeTx(ctx context.Context, storage TxStorage, events []KeyedEvent) error {
+	stx, err := storage.BeginTx(ctx)
+	if err != nil {
+		return err
+	}
+	for _, e := range events {
+		if err := stx.Store(ctx, e.Key, e.Event); err != nil {
+			return errors.Join(err, stx.Rollback())
+		}
+	}
+	return stx.Commit()
+}
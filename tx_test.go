@@ -0,0 +1,112 @@
+package audit_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/w0rng/audit"
+	"github.com/w0rng/audit/internal/be"
+)
+
+// flakyTxStorage is an in-memory TxStorage whose transactions fail on the
+// failAt-th Store call (1-based); zero never fails.
+type flakyTxStorage struct {
+	*audit.InMemoryStorage
+
+	failAt int
+}
+
+func (s *flakyTxStorage) BeginTx(context.Context) (audit.StorageTx, error) {
+	return &flakyTx{storage: s}, nil
+}
+
+type flakyTx struct {
+	storage *flakyTxStorage
+	pending []audit.KeyedEvent
+}
+
+func (tx *flakyTx) Store(_ context.Context, key string, event audit.Event) error {
+	tx.pending = append(tx.pending, audit.KeyedEvent{Key: key, Event: event})
+	if len(tx.pending) == tx.storage.failAt {
+		return errors.New("constraint violated")
+	}
+	return nil
+}
+
+func (tx *flakyTx) Commit() error {
+	for _, e := range tx.pending {
+		tx.storage.Store(e.Key, e.Event)
+	}
+	return nil
+}
+
+func (tx *flakyTx) Rollback() error {
+	tx.pending = nil
+	return nil
+}
+
+func TestTx_Commit(t *testing.T) {
+	t.Parallel()
+	logger := audit.New()
+	ctx := audit.WithActor(t.Context(), audit.Actor{ID: "u-1"})
+	logger.Create("order:123", "alice", "Created", map[string]audit.Value{})
+
+	tx := logger.Begin(ctx)
+	tx.Update("order:123", "", "Paid", map[string]audit.Value{"status": audit.PlainValue("paid")})
+	tx.Create("payment:9", "", "Captured", map[string]audit.Value{"amount": audit.PlainValue(100)})
+	tx.Update("inventory:42", "", "Reserved", map[string]audit.Value{"qty": audit.PlainValue(1)})
+
+	be.Equal(t, len(logger.Events("payment:9")), 0)
+	be.Err(t, tx.Commit(), nil)
+	be.Err(t, tx.Commit(), audit.ErrTxDone)
+
+	for _, key := range []string{"order:123", "payment:9", "inventory:42"} {
+		events := logger.Events(key)
+		last := events[len(events)-1]
+		be.Equal(t, last.TxID, tx.ID())
+		be.Equal(t, last.Author, "u-1")
+		be.Err(t, logger.Verify(key), nil)
+	}
+	be.Equal(t, logger.Events("order:123")[0].TxID, "")
+}
+
+func TestTx_Rollback(t *testing.T) {
+	t.Parallel()
+	logger := audit.New()
+
+	tx := logger.Begin(t.Context())
+	tx.Create("order:1", "alice", "Created", map[string]audit.Value{})
+	be.Err(t, tx.Rollback(), nil)
+	tx.Create("order:2", "alice", "Created", map[string]audit.Value{})
+
+	be.Err(t, tx.Commit(), audit.ErrTxDone)
+	be.Err(t, tx.Rollback(), audit.ErrTxDone)
+	be.Equal(t, len(logger.Events("order:1")), 0)
+	be.Equal(t, len(logger.Events("order:2")), 0)
+	be.True(t, logger.Begin(t.Context()).ID() != tx.ID())
+}
+
+func TestTx_TxStorage(t *testing.T) {
+	t.Parallel()
+	storage := &flakyTxStorage{InMemoryStorage: audit.NewInMemoryStorage(), failAt: 2}
+	logger := audit.New(audit.WithStorage(storage))
+	ctx := t.Context()
+
+	be.Err(t, logger.CreateContext(ctx, "order:1", "alice", "Created", map[string]audit.Value{}), nil)
+
+	tx := logger.Begin(ctx)
+	tx.Update("order:1", "alice", "Paid", map[string]audit.Value{})
+	tx.Create("payment:1", "alice", "Captured", map[string]audit.Value{})
+	be.Err(t, tx.Commit(), "constraint violated")
+	be.Equal(t, len(logger.Events("order:1")), 1)
+	be.Equal(t, len(logger.Events("payment:1")), 0)
+
+	// The failed commit does not disturb the chain.
+	storage.failAt = 0
+	tx = logger.Begin(ctx)
+	tx.Update("order:1", "alice", "Paid", map[string]audit.Value{})
+	be.Err(t, tx.Commit(), nil)
+	be.Equal(t, len(logger.Events("order:1")), 2)
+	be.Err(t, logger.Verify("order:1"), nil)
+}